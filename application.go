@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"go.einride.tech/balena/odata"
 )
@@ -42,10 +43,56 @@ func (s *ApplicationService) List(ctx context.Context) ([]*ApplicationsResponse,
 	return s.GetWithQuery(ctx, "")
 }
 
+// ForEach calls fn for each application matching the query, fetching applications page by page as they are needed.
+// The query should be a valid, escaped OData query as accepted by GetWithQuery. It must not contain $orderby,
+// $top or $skip, which are used for paginating by application ID.
+// Iteration stops at the first error returned by fn, which is then returned, or when ctx is done.
+func (s *ApplicationService) ForEach(ctx context.Context, query string, fn func(*ApplicationsResponse) error) error {
+	return s.ForEachPage(ctx, query, func(page []*ApplicationsResponse) error {
+		for _, app := range page {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := fn(app); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ForEachPage is like ForEach, but calls fn once per page of applications.
+func (s *ApplicationService) ForEachPage(
+	ctx context.Context,
+	query string,
+	fn func([]*ApplicationsResponse) error,
+) error {
+	p, err := s.client.newPager(applicationBasePath, query)
+	if err != nil {
+		return fmt.Errorf("unable to create application request: %w", err)
+	}
+	for {
+		type Response struct {
+			D []*ApplicationsResponse `json:"d,omitempty"`
+		}
+		resp := &Response{}
+		if err := p.next(ctx, resp); err != nil {
+			return fmt.Errorf("unable to query application: %w", err)
+		}
+		if err := fn(resp.D); err != nil {
+			return err
+		}
+		if !p.more(len(resp.D)) {
+			return nil
+		}
+	}
+}
+
 // GetWithQuery allows querying for devices using a custom open data protocol query.
 // The query should be a valid, escaped OData query such as `%24filter=uuid+eq+'12333422'`.
 //
 // Forward slash in filter keys should not be escaped (So `device/uuid` should not be escaped).
+// Use odata.Query to build a correctly escaped query.
 func (s *ApplicationService) GetWithQuery(ctx context.Context, query string) ([]*ApplicationsResponse, error) {
 	return s.getWithQueryAndPath(ctx, applicationBasePath, query)
 }
@@ -55,27 +102,37 @@ func (s *ApplicationService) GetWithQuery(ctx context.Context, query string) ([]
 func (s *ApplicationService) Get(ctx context.Context, applicationID int64) (*ApplicationsResponse, error) {
 	path := odata.EntityURL(applicationBasePath, strconv.FormatInt(applicationID, 10))
 	resp, err := s.getWithQueryAndPath(ctx, path, "")
+	if err != nil {
+		return nil, err
+	}
 	if len(resp) > 1 {
 		return nil, errors.New("received more than 1 application, expected 0 or 1")
 	}
 	if len(resp) == 0 {
 		return nil, nil
 	}
-	return resp[0], err
+	return resp[0], nil
 }
 
 // GetByName returns information on a single application given its Name
 // If the application does not exist, both the response and error are nil.
 func (s *ApplicationService) GetByName(ctx context.Context, applicationName string) (*ApplicationsResponse, error) {
-	query := "%24filter=app_name%20eq%20%27" + applicationName + "%27"
+	name, err := odata.QuoteString(applicationName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create get request: %w", err)
+	}
+	query := "%24filter=app_name%20eq%20" + name
 	resp, err := s.getWithQueryAndPath(ctx, applicationBasePath, query)
+	if err != nil {
+		return nil, err
+	}
 	if len(resp) > 1 {
 		return nil, errors.New("received more than 1 application, expected 0 or 1")
 	}
 	if len(resp) == 0 {
 		return nil, nil
 	}
-	return resp[0], err
+	return resp[0], nil
 }
 
 func (s *ApplicationService) getWithQueryAndPath(
@@ -85,7 +142,7 @@ func (s *ApplicationService) getWithQueryAndPath(
 ) ([]*ApplicationsResponse, error) {
 	req, err := s.client.NewRequest(ctx, http.MethodGet, path, query, nil)
 	if err != nil {
-		return nil, fmt.Errorf("unable to create get request: %v", err)
+		return nil, fmt.Errorf("unable to create get request: %w", err)
 	}
 	type Response struct {
 		D []ApplicationsResponse `json:"d,omitempty"`
@@ -93,7 +150,7 @@ func (s *ApplicationService) getWithQueryAndPath(
 	resp := &Response{}
 	err = s.client.Do(req, resp)
 	if err != nil {
-		return nil, fmt.Errorf("unable to get application list: %v", err)
+		return nil, fmt.Errorf("unable to get application list: %w", err)
 	}
 	apps := make([]*ApplicationsResponse, 0, len(resp.D))
 	for _, app := range resp.D {
@@ -112,12 +169,12 @@ func (s *ApplicationService) EnableTrackLatestRelease(ctx context.Context, appli
 	path := odata.EntityURL(applicationBasePath, strconv.FormatInt(applicationID, 10))
 	req, err := s.client.NewRequest(ctx, http.MethodPatch, path, query, &request{ShouldTrackLatestRelease: true})
 	if err != nil {
-		return nil, fmt.Errorf("unable to create setTrackLatestRelease request: %v", err)
+		return nil, fmt.Errorf("unable to create setTrackLatestRelease request: %w", err)
 	}
 	var buf bytes.Buffer
 	err = s.client.Do(req, &buf)
 	if err != nil {
-		return nil, fmt.Errorf("unable to path application: %v", err)
+		return nil, fmt.Errorf("unable to path application: %w", err)
 	}
 	return buf.Bytes(), nil
 }
@@ -131,12 +188,72 @@ func (s *ApplicationService) DisableTrackLatestRelease(ctx context.Context, appl
 	path := odata.EntityURL(applicationBasePath, strconv.FormatInt(applicationID, 10))
 	req, err := s.client.NewRequest(ctx, http.MethodPatch, path, query, &request{ShouldTrackLatestRelease: false})
 	if err != nil {
-		return nil, fmt.Errorf("unable to create setTrackLatestRelease request: %v", err)
+		return nil, fmt.Errorf("unable to create setTrackLatestRelease request: %w", err)
 	}
 	var buf bytes.Buffer
 	err = s.client.Do(req, &buf)
 	if err != nil {
-		return nil, fmt.Errorf("unable to path application: %v", err)
+		return nil, fmt.Errorf("unable to path application: %w", err)
 	}
 	return buf.Bytes(), nil
 }
+
+// ProvisioningKeyOptions configures a provisioning key created with ApplicationService.CreateProvisioningKey.
+type ProvisioningKeyOptions struct {
+	Name        string
+	Description string
+	// ExpiryDate is the time the key expires. The key does not expire if nil.
+	ExpiryDate *time.Time
+}
+
+// CreateProvisioningKey creates a provisioning key for the application with given ID/slug, which can register
+// devices with the application, see DeviceService.Register.
+func (s *ApplicationService) CreateProvisioningKey(
+	ctx context.Context,
+	applicationID IDOrSlug,
+	opts ProvisioningKeyOptions,
+) (string, error) {
+	id, err := s.resolveID(ctx, applicationID)
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve application: %w", err)
+	}
+	type request struct {
+		Name        string     `json:"name,omitempty"`
+		Description string     `json:"description,omitempty"`
+		ExpiryDate  *time.Time `json:"expiryDate,omitempty"`
+	}
+	body := &request{Name: opts.Name, Description: opts.Description}
+	if opts.ExpiryDate != nil {
+		expiryDate := opts.ExpiryDate.UTC()
+		body.ExpiryDate = &expiryDate
+	}
+	req, err := s.client.NewRequest(ctx, http.MethodPost, "api-key/application/"+id+"/provisioning", "", body)
+	if err != nil {
+		return "", fmt.Errorf("unable to create request: %w", err)
+	}
+	var key string
+	if err := s.client.Do(req, &key); err != nil {
+		return "", fmt.Errorf("unable to create provisioning key: %w", err)
+	}
+	return key, nil
+}
+
+// resolveID returns the ID of the given application, looking it up by slug if needed.
+// An error wrapping ErrNotFound is returned if no application has the slug.
+func (s *ApplicationService) resolveID(ctx context.Context, applicationID IDOrSlug) (string, error) {
+	if !applicationID.isSlug {
+		return applicationID.id, nil
+	}
+	query, err := odata.NewQuery().Filter(applicationID.filter("id", "slug")).Select("id").Encode()
+	if err != nil {
+		return "", fmt.Errorf("unable to create request: %w", err)
+	}
+	resp, err := s.getWithQueryAndPath(ctx, applicationBasePath, query)
+	if err != nil {
+		return "", err
+	}
+	if len(resp) == 0 {
+		return "", fmt.Errorf("application %s: %w", applicationID.id, ErrNotFound)
+	}
+	return strconv.FormatInt(resp[0].ID, 10), nil
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"strconv"
 	"testing"
+	"time"
 
 	"go.einride.tech/balena/odata"
 	"gotest.tools/v3/assert"
@@ -336,3 +337,49 @@ func TestApplicationService_DisableTrackLatestRelease(t *testing.T) {
 	assert.NilError(t, err)
 	assert.Equal(t, "OK", string(resp))
 }
+
+func TestApplicationService_GetByName_EscapesName(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc(
+		"/"+applicationBasePath,
+		func(w http.ResponseWriter, r *http.Request) {
+			testMethod(t, r, http.MethodGet)
+			expected := "%24filter=app_name%20eq%20%27Bob%27%27s+app+%26+co%27"
+			if r.URL.RawQuery != expected {
+				http.Error(w, fmt.Sprintf("query = %s ; expected %s", r.URL.RawQuery, expected), http.StatusInternalServerError)
+				return
+			}
+			fmt.Fprint(w, `{"d":[]}`)
+		},
+	)
+	// When
+	actual, err := client.Application.GetByName(context.Background(), "Bob's app & co")
+	// Then
+	assert.NilError(t, err)
+	assert.Assert(t, actual == nil)
+}
+
+func TestApplicationService_CreateProvisioningKey(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/api-key/application/123/provisioning", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodPost)
+		b, err := io.ReadAll(r.Body)
+		assert.NilError(t, err)
+		assert.Equal(t, string(b), `{"name":"factory","expiryDate":"2021-01-02T03:04:05Z"}`+"\n")
+		fmt.Fprint(w, `"key"`)
+	})
+	expiryDate := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	// When
+	key, err := client.Application.CreateProvisioningKey(
+		context.Background(),
+		ApplicationID(123),
+		ProvisioningKeyOptions{Name: "factory", ExpiryDate: &expiryDate},
+	)
+	// Then
+	assert.NilError(t, err)
+	assert.Equal(t, key, "key")
+}
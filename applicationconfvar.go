@@ -0,0 +1,199 @@
+package balena
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.einride.tech/balena/odata"
+)
+
+const applicationConfVarBasePath = "v6/application_config_variable"
+
+// configVarPrefixes are the prefixes that configuration variable names must start with.
+var configVarPrefixes = []string{"BALENA_", "RESIN_"}
+
+// ErrInvalidConfigVarName is returned when a configuration variable name does not start with BALENA_ or RESIN_.
+var ErrInvalidConfigVarName = errors.New("configuration variable name must start with BALENA_ or RESIN_")
+
+// ApplicationConfVarService handles configuration variables, such as `BALENA_HOST_CONFIG_gpu_mem`, applying to
+// all devices of an application. Device configuration variables with the same name take precedence, see
+// DeviceConfVarService.
+type ApplicationConfVarService service
+
+type ApplicationConfVarResponse struct {
+	ID          int64        `json:"id,omitempty"`
+	CreatedAt   string       `json:"created_at,omitempty"`
+	Application odata.Object `json:"application,omitempty"`
+	Name        string       `json:"name,omitempty"`
+	Value       string       `json:"value,omitempty"`
+}
+
+// List lists all configuration variables given a specific application ID/slug.
+func (s *ApplicationConfVarService) List(
+	ctx context.Context,
+	applicationID IDOrSlug,
+) ([]*ApplicationConfVarResponse, error) {
+	query, err := filterQuery(applicationID.filter("application", "application/slug"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+	return s.getWithQuery(ctx, query)
+}
+
+// Get retrieves the variable with the given name from the application with given ID/slug.
+// If no variable with such name exists, both the response and error are nil.
+func (s *ApplicationConfVarService) Get(
+	ctx context.Context,
+	applicationID IDOrSlug,
+	name string,
+) (*ApplicationConfVarResponse, error) {
+	query, err := filterQuery(odata.And(applicationID.filter("application", "application/slug"), odata.Eq("name", name)))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+	resp, err := s.getWithQuery(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) > 1 {
+		return nil, fmt.Errorf("expected 1 variable but got %d", len(resp))
+	}
+	if len(resp) == 0 {
+		return nil, nil
+	}
+	return resp[0], nil
+}
+
+// Create creates a configuration variable with name=value given an application ID/slug.
+// An error wrapping ErrConflict is returned if the variable already exists, and an error wrapping
+// ErrInvalidConfigVarName if the name does not have a configuration variable prefix.
+func (s *ApplicationConfVarService) Create(
+	ctx context.Context,
+	applicationID IDOrSlug,
+	name string,
+	value string,
+) (*ApplicationConfVarResponse, error) {
+	if err := validateConfigVarName(name); err != nil {
+		return nil, err
+	}
+	id, err := s.client.Application.resolveID(ctx, applicationID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve application: %w", err)
+	}
+	type request struct {
+		ApplicationID string `json:"application"`
+		Name          string `json:"name"`
+		Value         string `json:"value"`
+	}
+	req, err := s.client.NewRequest(ctx, http.MethodPost, applicationConfVarBasePath, "", &request{
+		ApplicationID: id,
+		Name:          name,
+		Value:         value,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+	resp := &ApplicationConfVarResponse{}
+	err = s.client.Do(req, resp)
+	if err != nil {
+		return nil, fmt.Errorf("unable to perform request: %w", err)
+	}
+	return resp, nil
+}
+
+// Update a variable with the given name from the application with given ID/slug to the specified new value.
+// No error is returned if no variable with such name exists, unless the client uses WithStrictMatching.
+// An error wrapping ErrInvalidConfigVarName is returned if the name does not have a configuration variable prefix.
+func (s *ApplicationConfVarService) Update(ctx context.Context, applicationID IDOrSlug, name, newValue string) error {
+	if err := validateConfigVarName(name); err != nil {
+		return err
+	}
+	body := struct {
+		Value string `json:"value"`
+	}{Value: newValue}
+	filter := odata.And(applicationID.filter("application", "application/slug"), odata.Eq("name", name))
+	_, err := s.client.doFilterRequest(ctx, http.MethodPatch, applicationConfVarBasePath, filter, body, false)
+	return err
+}
+
+// Upsert sets the variable with the given name of the application with given ID/slug to value, creating it if
+// it does not exist. The name must have a configuration variable prefix, as for Create.
+func (s *ApplicationConfVarService) Upsert(
+	ctx context.Context,
+	applicationID IDOrSlug,
+	name string,
+	value string,
+) (*ApplicationConfVarResponse, error) {
+	if err := validateConfigVarName(name); err != nil {
+		return nil, err
+	}
+	v, err := s.client.upsertVariable(
+		ctx,
+		applicationConfVarBasePath,
+		name,
+		value,
+		func() (variable, error) {
+			existing, err := s.Get(ctx, applicationID, name)
+			if existing == nil {
+				return nil, err
+			}
+			return existing, err
+		},
+		func() (variable, error) {
+			created, err := s.Create(ctx, applicationID, name, value)
+			if created == nil {
+				return nil, err
+			}
+			return created, err
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return v.(*ApplicationConfVarResponse), nil
+}
+
+// DeleteWithName deletes a variable with the given name from the application with given ID/slug.
+// No error is returned if no variable with such name exists, unless the client uses WithStrictMatching.
+func (s *ApplicationConfVarService) DeleteWithName(ctx context.Context, applicationID IDOrSlug, name string) error {
+	filter := odata.And(applicationID.filter("application", "application/slug"), odata.Eq("name", name))
+	_, err := s.client.doFilterRequest(ctx, http.MethodDelete, applicationConfVarBasePath, filter, nil, false)
+	return err
+}
+
+func (s *ApplicationConfVarService) getWithQuery(
+	ctx context.Context,
+	query string,
+) ([]*ApplicationConfVarResponse, error) {
+	req, err := s.client.NewRequest(ctx, http.MethodGet, applicationConfVarBasePath, query, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+	type Response struct {
+		D []*ApplicationConfVarResponse `json:"d,omitempty"`
+	}
+	resp := &Response{}
+	err = s.client.Do(req, resp)
+	if err != nil {
+		return nil, fmt.Errorf("unable to perform request: %w", err)
+	}
+	return resp.D, nil
+}
+
+func (v *ApplicationConfVarResponse) variableFields() (int64, *string) {
+	return v.ID, &v.Value
+}
+
+// validateConfigVarName returns an error wrapping ErrInvalidConfigVarName unless name starts with one of the
+// configuration variable prefixes.
+func validateConfigVarName(name string) error {
+	for _, prefix := range configVarPrefixes {
+		if len(name) > len(prefix) && strings.HasPrefix(name, prefix) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %q", ErrInvalidConfigVarName, name)
+}
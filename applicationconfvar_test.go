@@ -0,0 +1,74 @@
+package balena
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"go.einride.tech/balena/odata"
+	"gotest.tools/v3/assert"
+)
+
+func TestApplicationConfVarService_List(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/"+applicationConfVarBasePath, func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		assert.Equal(t, r.URL.RawQuery, "%24filter=application/slug+eq+%27myorg%2Fmyapp%27")
+		fmt.Fprint(w, `{"d":[{"id":1,"application":{"__id":123},"name":"BALENA_HOST_CONFIG_gpu_mem","value":"64"}]}`)
+	})
+	// When
+	actual, err := client.ApplicationConfVar.List(context.Background(), ApplicationSlug("myorg/myapp"))
+	// Then
+	assert.NilError(t, err)
+	assert.DeepEqual(t, actual, []*ApplicationConfVarResponse{
+		{ID: 1, Application: odata.Object{ID: 123}, Name: "BALENA_HOST_CONFIG_gpu_mem", Value: "64"},
+	})
+}
+
+func TestApplicationConfVarService_Create(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/"+applicationConfVarBasePath, func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodPost)
+		b, err := io.ReadAll(r.Body)
+		assert.NilError(t, err)
+		assert.Equal(t, string(b), `{"application":"123","name":"RESIN_SUPERVISOR_POLL_INTERVAL","value":"900000"}`+"\n")
+		fmt.Fprint(w, `{"id":1,"application":{"__id":123},"name":"RESIN_SUPERVISOR_POLL_INTERVAL","value":"900000"}`)
+	})
+	// When
+	actual, err := client.ApplicationConfVar.Create(
+		context.Background(),
+		ApplicationID(123),
+		"RESIN_SUPERVISOR_POLL_INTERVAL",
+		"900000",
+	)
+	// Then
+	assert.NilError(t, err)
+	assert.Equal(t, actual.ID, int64(1))
+}
+
+func TestApplicationConfVarService_InvalidName(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s %s", r.Method, r.URL)
+	})
+	ctx := context.Background()
+	for _, name := range []string{"", "BALENA_", "MY_VAR", "balena_HOST_CONFIG_gpu_mem", "XBALENA_VAR"} {
+		// When
+		_, createErr := client.ApplicationConfVar.Create(ctx, ApplicationID(123), name, "value")
+		updateErr := client.ApplicationConfVar.Update(ctx, ApplicationID(123), name, "value")
+		_, upsertErr := client.ApplicationConfVar.Upsert(ctx, ApplicationID(123), name, "value")
+		// Then
+		assert.Assert(t, errors.Is(createErr, ErrInvalidConfigVarName), name)
+		assert.Assert(t, errors.Is(updateErr, ErrInvalidConfigVarName), name)
+		assert.Assert(t, errors.Is(upsertErr, ErrInvalidConfigVarName), name)
+	}
+}
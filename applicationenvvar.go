@@ -0,0 +1,168 @@
+package balena
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.einride.tech/balena/odata"
+)
+
+const applicationEnvVarBasePath = "v6/application_environment_variable"
+
+// ApplicationEnvVarService handles environment variables applying to all devices of an application.
+// Device environment variables with the same name take precedence, see DeviceEnvVarService.
+type ApplicationEnvVarService service
+
+type ApplicationEnvVarResponse struct {
+	ID          int64        `json:"id,omitempty"`
+	CreatedAt   string       `json:"created_at,omitempty"`
+	Application odata.Object `json:"application,omitempty"`
+	Name        string       `json:"name,omitempty"`
+	Value       string       `json:"value,omitempty"`
+}
+
+// List lists all environment variables given a specific application ID/slug.
+func (s *ApplicationEnvVarService) List(
+	ctx context.Context,
+	applicationID IDOrSlug,
+) ([]*ApplicationEnvVarResponse, error) {
+	query, err := filterQuery(applicationID.filter("application", "application/slug"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+	return s.getWithQuery(ctx, query)
+}
+
+// Get retrieves the variable with the given name from the application with given ID/slug.
+// If no variable with such name exists, both the response and error are nil.
+func (s *ApplicationEnvVarService) Get(
+	ctx context.Context,
+	applicationID IDOrSlug,
+	name string,
+) (*ApplicationEnvVarResponse, error) {
+	query, err := filterQuery(odata.And(applicationID.filter("application", "application/slug"), odata.Eq("name", name)))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+	resp, err := s.getWithQuery(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) > 1 {
+		return nil, fmt.Errorf("expected 1 variable but got %d", len(resp))
+	}
+	if len(resp) == 0 {
+		return nil, nil
+	}
+	return resp[0], nil
+}
+
+// Create creates an environment variable with name=value given an application ID/slug.
+// An error wrapping ErrConflict is returned if the variable already exists.
+func (s *ApplicationEnvVarService) Create(
+	ctx context.Context,
+	applicationID IDOrSlug,
+	name string,
+	value string,
+) (*ApplicationEnvVarResponse, error) {
+	id, err := s.client.Application.resolveID(ctx, applicationID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve application: %w", err)
+	}
+	type request struct {
+		ApplicationID string `json:"application"`
+		Name          string `json:"name"`
+		Value         string `json:"value"`
+	}
+	req, err := s.client.NewRequest(ctx, http.MethodPost, applicationEnvVarBasePath, "", &request{
+		ApplicationID: id,
+		Name:          name,
+		Value:         value,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+	resp := &ApplicationEnvVarResponse{}
+	err = s.client.Do(req, resp)
+	if err != nil {
+		return nil, fmt.Errorf("unable to perform request: %w", err)
+	}
+	return resp, nil
+}
+
+// Update a variable with the given name from the application with given ID/slug to the specified new value.
+// No error is returned if no variable with such name exists, unless the client uses WithStrictMatching.
+func (s *ApplicationEnvVarService) Update(ctx context.Context, applicationID IDOrSlug, name, newValue string) error {
+	body := struct {
+		Value string `json:"value"`
+	}{Value: newValue}
+	filter := odata.And(applicationID.filter("application", "application/slug"), odata.Eq("name", name))
+	_, err := s.client.doFilterRequest(ctx, http.MethodPatch, applicationEnvVarBasePath, filter, body, false)
+	return err
+}
+
+// Upsert sets the variable with the given name of the application with given ID/slug to value, creating it if
+// it does not exist.
+func (s *ApplicationEnvVarService) Upsert(
+	ctx context.Context,
+	applicationID IDOrSlug,
+	name string,
+	value string,
+) (*ApplicationEnvVarResponse, error) {
+	v, err := s.client.upsertVariable(
+		ctx,
+		applicationEnvVarBasePath,
+		name,
+		value,
+		func() (variable, error) {
+			existing, err := s.Get(ctx, applicationID, name)
+			if existing == nil {
+				return nil, err
+			}
+			return existing, err
+		},
+		func() (variable, error) {
+			created, err := s.Create(ctx, applicationID, name, value)
+			if created == nil {
+				return nil, err
+			}
+			return created, err
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return v.(*ApplicationEnvVarResponse), nil
+}
+
+// DeleteWithName deletes a variable with the given name from the application with given ID/slug.
+// No error is returned if no variable with such name exists, unless the client uses WithStrictMatching.
+func (s *ApplicationEnvVarService) DeleteWithName(ctx context.Context, applicationID IDOrSlug, name string) error {
+	filter := odata.And(applicationID.filter("application", "application/slug"), odata.Eq("name", name))
+	_, err := s.client.doFilterRequest(ctx, http.MethodDelete, applicationEnvVarBasePath, filter, nil, false)
+	return err
+}
+
+func (s *ApplicationEnvVarService) getWithQuery(
+	ctx context.Context,
+	query string,
+) ([]*ApplicationEnvVarResponse, error) {
+	req, err := s.client.NewRequest(ctx, http.MethodGet, applicationEnvVarBasePath, query, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+	type Response struct {
+		D []*ApplicationEnvVarResponse `json:"d,omitempty"`
+	}
+	resp := &Response{}
+	err = s.client.Do(req, resp)
+	if err != nil {
+		return nil, fmt.Errorf("unable to perform request: %w", err)
+	}
+	return resp.D, nil
+}
+
+func (v *ApplicationEnvVarResponse) variableFields() (int64, *string) {
+	return v.ID, &v.Value
+}
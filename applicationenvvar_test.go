@@ -0,0 +1,173 @@
+package balena
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"go.einride.tech/balena/odata"
+	"gotest.tools/v3/assert"
+)
+
+func TestApplicationEnvVarService_List(t *testing.T) {
+	for _, tt := range []struct {
+		name          string
+		applicationID IDOrSlug
+		expected      string
+	}{
+		{
+			name:          "id",
+			applicationID: ApplicationID(123),
+			expected:      "%24filter=application+eq+%27123%27",
+		},
+		{
+			name:          "slug",
+			applicationID: ApplicationSlug("myorg/it's"),
+			expected:      "%24filter=application/slug+eq+%27myorg%2Fit%27%27s%27",
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			client, mux, cleanup := newFixture()
+			defer cleanup()
+			mux.HandleFunc("/"+applicationEnvVarBasePath, func(w http.ResponseWriter, r *http.Request) {
+				testMethod(t, r, http.MethodGet)
+				assert.Equal(t, r.URL.RawQuery, tt.expected)
+				fmt.Fprint(w, `{"d":[{"id":1,"application":{"__id":123},"name":"KEY","value":"value"}]}`)
+			})
+			// When
+			actual, err := client.ApplicationEnvVar.List(context.Background(), tt.applicationID)
+			// Then
+			assert.NilError(t, err)
+			assert.DeepEqual(t, actual, []*ApplicationEnvVarResponse{
+				{ID: 1, Application: odata.Object{ID: 123}, Name: "KEY", Value: "value"},
+			})
+		})
+	}
+}
+
+func TestApplicationEnvVarService_Create_Slug(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/"+applicationBasePath, func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		assert.Equal(t, r.URL.RawQuery, "%24select=id&%24filter=slug+eq+%27myorg%2Fmyapp%27")
+		fmt.Fprint(w, `{"d":[{"id":123}]}`)
+	})
+	mux.HandleFunc("/"+applicationEnvVarBasePath, func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodPost)
+		b, err := io.ReadAll(r.Body)
+		assert.NilError(t, err)
+		assert.Equal(t, string(b), `{"application":"123","name":"KEY","value":"value"}`+"\n")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"id":1,"application":{"__id":123},"name":"KEY","value":"value"}`)
+	})
+	// When
+	actual, err := client.ApplicationEnvVar.Create(context.Background(), ApplicationSlug("myorg/myapp"), "KEY", "value")
+	// Then
+	assert.NilError(t, err)
+	assert.Equal(t, actual.ID, int64(1))
+}
+
+func TestApplicationEnvVarService_Create_UnknownSlug(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/"+applicationBasePath, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"d":[]}`)
+	})
+	// When
+	_, err := client.ApplicationEnvVar.Create(context.Background(), ApplicationSlug("myorg/missing"), "KEY", "value")
+	// Then
+	assert.Assert(t, errors.Is(err, ErrNotFound))
+}
+
+func TestApplicationEnvVarService_Upsert(t *testing.T) {
+	for _, tt := range []struct {
+		name            string
+		existing        string
+		expectedMethods []string
+	}{
+		{name: "create", existing: `{"d":[]}`, expectedMethods: []string{"GET", "POST"}},
+		{
+			name:            "update",
+			existing:        `{"d":[{"id":1,"name":"KEY","value":"old"}]}`,
+			expectedMethods: []string{"GET", "PATCH"},
+		},
+		{name: "unchanged", existing: `{"d":[{"id":1,"name":"KEY","value":"new"}]}`, expectedMethods: []string{"GET"}},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			client, mux, cleanup := newFixture()
+			defer cleanup()
+			var methods []string
+			mux.HandleFunc("/"+applicationEnvVarBasePath, func(w http.ResponseWriter, r *http.Request) {
+				methods = append(methods, r.Method)
+				switch r.Method {
+				case http.MethodGet:
+					assert.Equal(t, r.URL.RawQuery, "%24filter=application+eq+%27123%27+and+name+eq+%27KEY%27")
+					fmt.Fprint(w, tt.existing)
+				case http.MethodPost:
+					var body map[string]string
+					assert.NilError(t, json.NewDecoder(r.Body).Decode(&body))
+					assert.DeepEqual(t, body, map[string]string{"application": "123", "name": "KEY", "value": "new"})
+					fmt.Fprint(w, `{"id":1,"name":"KEY","value":"new"}`)
+				}
+			})
+			mux.HandleFunc("/"+applicationEnvVarBasePath+"(1)", func(w http.ResponseWriter, r *http.Request) {
+				methods = append(methods, r.Method)
+				testMethod(t, r, http.MethodPatch)
+				fmt.Fprint(w, "OK")
+			})
+			// When
+			actual, err := client.ApplicationEnvVar.Upsert(context.Background(), ApplicationID(123), "KEY", "new")
+			// Then
+			assert.NilError(t, err)
+			assert.Equal(t, actual.Value, "new")
+			assert.DeepEqual(t, methods, tt.expectedMethods)
+		})
+	}
+}
+
+func TestApplicationEnvVarService_Upsert_DeletedConcurrently(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	var methods []string
+	mux.HandleFunc("/"+applicationEnvVarBasePath, func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		switch r.Method {
+		case http.MethodGet:
+			fmt.Fprint(w, `{"d":[]}`)
+		case http.MethodPost:
+			w.WriteHeader(http.StatusConflict)
+		}
+	})
+	// When
+	_, err := client.ApplicationEnvVar.Upsert(context.Background(), ApplicationID(123), "KEY", "new")
+	// Then
+	assert.Assert(t, errors.Is(err, ErrNotFound))
+	assert.DeepEqual(t, methods, []string{http.MethodGet, http.MethodPost, http.MethodGet})
+}
+
+func TestApplicationEnvVarService_DeleteWithName(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/"+applicationEnvVarBasePath, func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodDelete)
+		assert.Equal(t, r.URL.RawQuery, "%24filter=application/slug+eq+%27myorg%2Fmyapp%27+and+name+eq+%27A%26B%27")
+		fmt.Fprint(w, "OK")
+	})
+	// When
+	err := client.ApplicationEnvVar.DeleteWithName(context.Background(), ApplicationSlug("myorg/myapp"), "A&B")
+	// Then
+	assert.NilError(t, err)
+}
@@ -0,0 +1,186 @@
+package balena
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrTwoFactorRequired is returned by Client.Login when the user has two-factor authentication enabled but no
+// TOTP callback was given.
+var ErrTwoFactorRequired = errors.New("two-factor authentication required")
+
+// defaultRefreshBefore is how long before expiry a session token is refreshed by default.
+const defaultRefreshBefore = time.Hour
+
+// TokenSource provides the auth token of requests made by a Client.
+type TokenSource interface {
+	// Token returns a valid auth token.
+	Token(ctx context.Context) (string, error)
+}
+
+// StaticTokenSource returns a TokenSource that always returns the same token, such as an API key.
+func StaticTokenSource(token string) TokenSource {
+	return staticTokenSource(token)
+}
+
+type staticTokenSource string
+
+func (s staticTokenSource) Token(context.Context) (string, error) {
+	return string(s), nil
+}
+
+// WithTokenSource sets the source of auth tokens for requests, replacing the token given to the constructor.
+func WithTokenSource(source TokenSource) Option {
+	return func(c *Client) error {
+		if source == nil {
+			return errors.New("token source must not be nil")
+		}
+		c.tokenSource = source
+		return nil
+	}
+}
+
+// Credentials are used to log in with Client.Login.
+type Credentials struct {
+	// Username is the username or email of the user.
+	Username string
+	// Password is the password of the user.
+	Password string
+	// TOTP returns a time-based one-time password. It is only called, and then required, for users with
+	// two-factor authentication enabled.
+	TOTP func(ctx context.Context) (string, error)
+}
+
+// Login logs in with the given credentials and returns a SessionTokenSource for the resulting session token,
+// which can be passed to WithTokenSource. The client does not need to be authenticated.
+func (c *Client) Login(ctx context.Context, credentials Credentials) (*SessionTokenSource, error) {
+	type request struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	token, err := c.tokenRequest(ctx, http.MethodPost, "login_", "", &request{
+		Username: credentials.Username,
+		Password: credentials.Password,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to log in: %w", err)
+	}
+	if claims, err := parseTokenClaims(token); err == nil && claims.TwoFactorRequired {
+		if credentials.TOTP == nil {
+			return nil, fmt.Errorf("unable to log in: %w", ErrTwoFactorRequired)
+		}
+		code, err := credentials.TOTP(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get two-factor authentication code: %w", err)
+		}
+		type request struct {
+			Code string `json:"code"`
+		}
+		token, err = c.tokenRequest(ctx, http.MethodPost, "auth/totp/verify", token, &request{Code: code})
+		if err != nil {
+			return nil, fmt.Errorf("unable to verify two-factor authentication code: %w", err)
+		}
+	}
+	return c.SessionTokenSource(token), nil
+}
+
+// SessionTokenSource returns a SessionTokenSource for an existing session token,
+// such as one stored by the balena CLI. The client is used for refreshing the token.
+func (c *Client) SessionTokenSource(token string) *SessionTokenSource {
+	return &SessionTokenSource{client: c, token: token}
+}
+
+// SessionTokenSource is a TokenSource for session tokens, which are refreshed through the
+// `user/v1/refresh-token` endpoint before they expire. It is safe for concurrent use.
+type SessionTokenSource struct {
+	// RefreshBefore is how long before expiry the token is refreshed. Defaults to one hour.
+	RefreshBefore time.Duration
+
+	client *Client
+	mu     sync.Mutex
+	token  string
+	now    func() time.Time
+}
+
+// Token returns the session token, refreshing it first if it is about to expire. If refreshing fails, the
+// current token is returned as long as it has not expired.
+func (s *SessionTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	claims, err := parseTokenClaims(s.token)
+	if err != nil || claims.Expires == 0 {
+		// Tokens without a known expiry are used as is.
+		return s.token, nil
+	}
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	refreshBefore := s.RefreshBefore
+	if refreshBefore <= 0 {
+		refreshBefore = defaultRefreshBefore
+	}
+	expiry := time.Unix(claims.Expires, 0)
+	if now().Add(refreshBefore).Before(expiry) {
+		return s.token, nil
+	}
+	token, err := s.client.tokenRequest(ctx, http.MethodGet, "user/v1/refresh-token", s.token, nil)
+	if err != nil {
+		if now().Before(expiry) {
+			return s.token, nil
+		}
+		return "", fmt.Errorf("unable to refresh expired session token: %w", err)
+	}
+	s.token = token
+	return token, nil
+}
+
+// tokenRequest performs a request authenticated with the given token, which may be empty,
+// and returns the token in the response body.
+func (c *Client) tokenRequest(ctx context.Context, method, path, token string, body interface{}) (string, error) {
+	req, err := c.newRequest(ctx, method, path, "", body, token)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := c.Do(req, &buf); err != nil {
+		return "", err
+	}
+	newToken := strings.TrimSpace(buf.String())
+	if newToken == "" {
+		return "", errors.New("empty token in response")
+	}
+	return newToken, nil
+}
+
+// tokenClaims are the claims of a balena session token relevant to the client.
+type tokenClaims struct {
+	Expires           int64 `json:"exp"`
+	TwoFactorRequired bool  `json:"twoFactorRequired"`
+}
+
+// parseTokenClaims returns the claims of a JWT session token, without verifying its signature.
+// An error is returned if the token is not a JWT, as is the case for API keys.
+func parseTokenClaims(token string) (*tokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("token is not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT payload: %w", err)
+	}
+	claims := &tokenClaims{}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return nil, fmt.Errorf("invalid JWT claims: %w", err)
+	}
+	return claims, nil
+}
@@ -0,0 +1,185 @@
+package balena
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func testJWT(t *testing.T, claims map[string]interface{}) string {
+	t.Helper()
+	payload, err := json.Marshal(claims)
+	assert.NilError(t, err)
+	return "eyJhbGciOiJIUzI1NiJ9." + base64.RawURLEncoding.EncodeToString(payload) + ".signature"
+}
+
+func TestClient_Login(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	token := testJWT(t, map[string]interface{}{"id": 1, "exp": time.Now().Add(24 * time.Hour).Unix()})
+	mux.HandleFunc("/login_", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodPost)
+		b, err := io.ReadAll(r.Body)
+		assert.NilError(t, err)
+		assert.Equal(t, `{"username":"user@example.com","password":"secret"}`+"\n", string(b))
+		assert.Equal(t, "", r.Header.Get("Authorization"))
+		fmt.Fprint(w, token)
+	})
+	// When
+	source, err := client.Login(context.Background(), Credentials{Username: "user@example.com", Password: "secret"})
+	// Then
+	assert.NilError(t, err)
+	actual, err := source.Token(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, token, actual)
+}
+
+func TestClient_Login_TwoFactor(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	partialToken := testJWT(t, map[string]interface{}{"twoFactorRequired": true})
+	token := testJWT(t, map[string]interface{}{"id": 1})
+	mux.HandleFunc("/login_", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, partialToken)
+	})
+	mux.HandleFunc("/auth/totp/verify", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodPost)
+		assert.Equal(t, "Bearer "+partialToken, r.Header.Get("Authorization"))
+		b, err := io.ReadAll(r.Body)
+		assert.NilError(t, err)
+		assert.Equal(t, `{"code":"123456"}`+"\n", string(b))
+		fmt.Fprint(w, token)
+	})
+	// When
+	source, err := client.Login(context.Background(), Credentials{
+		Username: "user",
+		Password: "secret",
+		TOTP: func(context.Context) (string, error) {
+			return "123456", nil
+		},
+	})
+	// Then
+	assert.NilError(t, err)
+	actual, err := source.Token(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, token, actual)
+}
+
+func TestClient_Login_TwoFactorRequired(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/login_", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, testJWT(t, map[string]interface{}{"twoFactorRequired": true}))
+	})
+	// When
+	_, err := client.Login(context.Background(), Credentials{Username: "user", Password: "secret"})
+	// Then
+	assert.Assert(t, errors.Is(err, ErrTwoFactorRequired))
+}
+
+func TestClient_Login_Unauthorized(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/login_", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	})
+	// When
+	_, err := client.Login(context.Background(), Credentials{Username: "user", Password: "wrong"})
+	// Then
+	assert.Assert(t, errors.Is(err, ErrUnauthorized))
+}
+
+func TestSessionTokenSource_Refresh(t *testing.T) {
+	now := time.Now()
+	for _, tt := range []struct {
+		name          string
+		expiry        time.Time
+		refreshStatus int
+		expectedToken string
+		expectedErr   bool
+	}{
+		{name: "valid", expiry: now.Add(2 * time.Hour), expectedToken: "old"},
+		{name: "about to expire", expiry: now.Add(time.Minute), refreshStatus: http.StatusOK, expectedToken: "new"},
+		{
+			name:          "refresh failed before expiry",
+			expiry:        now.Add(time.Minute),
+			refreshStatus: http.StatusInternalServerError,
+			expectedToken: "old",
+		},
+		{
+			name:          "refresh failed after expiry",
+			expiry:        now.Add(-time.Minute),
+			refreshStatus: http.StatusUnauthorized,
+			expectedErr:   true,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			client, mux, cleanup := newFixture()
+			defer cleanup()
+			oldToken := testJWT(t, map[string]interface{}{"exp": tt.expiry.Unix()})
+			newToken := testJWT(t, map[string]interface{}{"exp": now.Add(24 * time.Hour).Unix()})
+			mux.HandleFunc("/user/v1/refresh-token", func(w http.ResponseWriter, r *http.Request) {
+				testMethod(t, r, http.MethodGet)
+				assert.Equal(t, "Bearer "+oldToken, r.Header.Get("Authorization"))
+				if tt.refreshStatus != http.StatusOK {
+					http.Error(w, "failed", tt.refreshStatus)
+					return
+				}
+				fmt.Fprint(w, newToken)
+			})
+			source := client.SessionTokenSource(oldToken)
+			source.now = func() time.Time { return now }
+			// When
+			actual, err := source.Token(context.Background())
+			// Then
+			if tt.expectedErr {
+				assert.Assert(t, errors.Is(err, ErrUnauthorized))
+				return
+			}
+			assert.NilError(t, err)
+			expected := map[string]string{"old": oldToken, "new": newToken}[tt.expectedToken]
+			assert.Equal(t, expected, actual)
+		})
+	}
+}
+
+func TestWithTokenSource(t *testing.T) {
+	// Given
+	client, err := NewClient("static", WithTokenSource(StaticTokenSource("from-source")))
+	assert.NilError(t, err)
+	// When
+	req, err := client.NewRequest(context.Background(), http.MethodGet, "foo", "", nil)
+	// Then
+	assert.NilError(t, err)
+	assert.Equal(t, "Bearer from-source", req.Header.Get("Authorization"))
+}
+
+type failingTokenSource struct{}
+
+func (failingTokenSource) Token(context.Context) (string, error) {
+	return "", errors.New("boom")
+}
+
+func TestWithTokenSource_Error(t *testing.T) {
+	// Given
+	client, err := NewClient("", WithTokenSource(failingTokenSource{}))
+	assert.NilError(t, err)
+	// When
+	_, err = client.NewRequest(context.Background(), http.MethodGet, "foo", "", nil)
+	// Then
+	assert.ErrorContains(t, err, "unable to get auth token: boom")
+}
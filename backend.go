@@ -0,0 +1,113 @@
+package balena
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrUnsupported is returned for requests to resources that are not available on the backend of the Client.
+var ErrUnsupported = errors.New("unsupported on this backend")
+
+// Backend identifies the kind of API server a Client communicates with.
+type Backend int
+
+const (
+	// BalenaCloud is the hosted balena cloud API.
+	BalenaCloud Backend = iota
+	// OpenBalena is a self-hosted openBalena instance.
+	OpenBalena
+)
+
+// String returns the name of the backend.
+func (b Backend) String() string {
+	switch b {
+	case BalenaCloud:
+		return "balena cloud"
+	case OpenBalena:
+		return "openBalena"
+	}
+	return fmt.Sprintf("Backend(%d)", int(b))
+}
+
+// openBalenaUnsupportedPaths are path prefixes of endpoints not provided by openBalena.
+var openBalenaUnsupportedPaths = []string{
+	// openBalena has no proxy to the device supervisor.
+	"supervisor/",
+	// openBalena has no device actions, such as restarting the application of a device.
+	deviceV2BasePath + "/",
+}
+
+// resourcePathPattern matches versioned resource paths such as `v6/device` or `v6/device(123)`,
+// capturing the version and the resource name.
+var resourcePathPattern = regexp.MustCompile(`^(v[0-9]+)/([A-Za-z_]+)`)
+
+var versionPattern = regexp.MustCompile(`^v[0-9]+$`)
+
+// WithOpenBalena configures the client to talk to the openBalena instance with the given API URL, such as
+// `https://api.openbalena.example.com/`. Requests for resources not provided by openBalena fail with
+// ErrUnsupported without being sent. Use WithAPIVersion and WithResourceVersion to match the resource
+// versions served by the instance.
+func WithOpenBalena(apiURL string) Option {
+	return func(c *Client) error {
+		if err := WithBaseURL(apiURL)(c); err != nil {
+			return err
+		}
+		c.backend = OpenBalena
+		return nil
+	}
+}
+
+// WithAPIVersion sets the API version, such as `v5`, used for all resources not configured by
+// WithResourceVersion.
+func WithAPIVersion(version string) Option {
+	return WithResourceVersion("", version)
+}
+
+// WithResourceVersion sets the API version, such as `v5`, used for a single resource such as `device_tag`.
+func WithResourceVersion(resource string, version string) Option {
+	return func(c *Client) error {
+		if !versionPattern.MatchString(version) {
+			return fmt.Errorf("invalid API version %q", version)
+		}
+		if c.versions == nil {
+			c.versions = map[string]string{}
+		}
+		c.versions[resource] = version
+		return nil
+	}
+}
+
+// Backend returns the kind of API server the client communicates with.
+func (c *Client) Backend() Backend {
+	return c.backend
+}
+
+// resolvePath applies the configured resource versions to a relative URL such as `v6/device`,
+// and returns an error wrapping ErrUnsupported if the endpoint is not provided by the backend.
+func (c *Client) resolvePath(urlStr string) (string, error) {
+	if c.backend == OpenBalena {
+		for _, prefix := range openBalenaUnsupportedPaths {
+			if strings.HasPrefix(urlStr, prefix) {
+				return "", fmt.Errorf("%w: %s is not available on %v", ErrUnsupported, urlStr, c.backend)
+			}
+		}
+	}
+	if len(c.versions) == 0 {
+		return urlStr, nil
+	}
+	m := resourcePathPattern.FindStringSubmatchIndex(urlStr)
+	if m == nil {
+		return urlStr, nil
+	}
+	resource := urlStr[m[4]:m[5]]
+	version, ok := c.versions[resource]
+	if !ok {
+		version, ok = c.versions[""]
+	}
+	if !ok {
+		return urlStr, nil
+	}
+	return version + urlStr[m[3]:], nil
+}
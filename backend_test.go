@@ -0,0 +1,110 @@
+package balena
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func openBalenaFixture(t *testing.T, opts ...Option) (*Client, *http.ServeMux) {
+	t.Helper()
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	client, err := NewClient("token", append([]Option{WithOpenBalena(server.URL + "/")}, opts...)...)
+	assert.NilError(t, err)
+	return client, mux
+}
+
+func TestWithOpenBalena(t *testing.T) {
+	// Given
+	client, mux := openBalenaFixture(t)
+	mux.HandleFunc("/"+deviceBasePath, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"d":[{"id":1}]}`)
+	})
+	// When
+	devices, err := client.Device.List(context.Background())
+	// Then
+	assert.NilError(t, err)
+	assert.Equal(t, OpenBalena, client.Backend())
+	assert.Equal(t, 1, len(devices))
+}
+
+func TestWithOpenBalena_ResourceVersions(t *testing.T) {
+	// Given
+	client, mux := openBalenaFixture(t, WithAPIVersion("v5"), WithResourceVersion("device_tag", "v4"))
+	mux.HandleFunc("/v5/device(123)", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"d":[{"id":123}]}`)
+	})
+	mux.HandleFunc("/v4/device_tag", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"d":[{"id":1,"tag_key":"key"}]}`)
+	})
+	// When
+	device, err := client.Device.Get(context.Background(), DeviceID(123))
+	assert.NilError(t, err)
+	tags, err := client.DeviceTag.List(context.Background(), DeviceID(123))
+	assert.NilError(t, err)
+	// Then
+	assert.Equal(t, int64(123), device.ID)
+	assert.Equal(t, "key", tags[0].TagKey)
+}
+
+func TestWithOpenBalena_Unsupported(t *testing.T) {
+	// Given
+	client, mux := openBalenaFixture(t)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request to %s", r.URL.Path)
+	})
+	// When
+	err := client.SupervisorV2(123, "uuid").RestartServiceByName(context.Background(), "main")
+	// Then
+	assert.Assert(t, errors.Is(err, ErrUnsupported))
+	assert.ErrorContains(t, err, "not available on openBalena")
+}
+
+func TestWithOpenBalena_UnsupportedDeviceActions(t *testing.T) {
+	// Given
+	client, mux := openBalenaFixture(t)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request to %s", r.URL.Path)
+	})
+	// When
+	restartErr := client.Device.RestartApplication(context.Background(), DeviceUUID("uuid"), false)
+	purgeErr := client.Device.PurgeData(context.Background(), DeviceUUID("uuid"), true)
+	// Then
+	assert.Assert(t, errors.Is(restartErr, ErrUnsupported))
+	assert.Assert(t, errors.Is(purgeErr, ErrUnsupported))
+}
+
+func TestWithResourceVersion_Invalid(t *testing.T) {
+	// When
+	_, err := NewClient("token", WithResourceVersion("device", "6"))
+	// Then
+	assert.ErrorContains(t, err, "invalid API version")
+}
+
+func TestClient_ResolvePath(t *testing.T) {
+	// Given
+	client, err := NewClient("token", WithResourceVersion("device", "v5"))
+	assert.NilError(t, err)
+	for _, tt := range []struct {
+		in       string
+		expected string
+	}{
+		{in: "v6/device", expected: "v5/device"},
+		{in: "v6/device(123)", expected: "v5/device(123)"},
+		{in: "v6/device_tag", expected: "v6/device_tag"},
+		{in: "supervisor/v2/applications/1/state", expected: "supervisor/v2/applications/1/state"},
+	} {
+		// When
+		actual, err := client.resolvePath(tt.in)
+		// Then
+		assert.NilError(t, err)
+		assert.Equal(t, tt.expected, actual)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -11,6 +12,9 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
+
+	"go.einride.tech/balena/odata"
 )
 
 const (
@@ -22,56 +26,155 @@ type Client struct {
 	client *http.Client // HTTP client used to communicate with the API.
 
 	// Base URL for API requests. Defaults to the public Balena Cloud API
-	// BaseURL should always be specified with a trailing slash.
+	// BaseURL should always be specified with a trailing slash. Prefer setting it with WithBaseURL.
 	BaseURL   *url.URL
 	UserAgent string
 	authToken string
+	// PageSize is the number of entities fetched per request by paginated methods such as
+	// DeviceService.ForEach. Defaults to 1000.
+	PageSize int
+	// RetryPolicy configures retries of failed requests. Requests are not retried if nil.
+	RetryPolicy *RetryPolicy
+	// RateLimiter limits the rate of requests sent by the client. Requests are not limited if nil.
+	RateLimiter *RateLimiter
+
+	backend     Backend
+	versions    map[string]string
+	tokenSource TokenSource
+	logger      Logger
+	timeout     time.Duration
+	middlewares []func(http.RoundTripper) http.RoundTripper
+	// strictMatching is set by WithStrictMatching.
+	strictMatching bool
+	// optionErr is the error of an invalid option given to New, returned when creating requests.
+	optionErr error
 
 	common service // Reuse a single struct instead of allocating one for each service on the heap.
 
 	// Services used for talking to different parts of the Balena API
-	Application    *ApplicationService
-	Device         *DeviceService
-	Release        *ReleaseService
-	ReleaseTag     *ReleaseTagService
-	DeviceEnvVar   *DeviceEnvVarService
-	DeviceServVar  *DeviceServVarService
-	DeviceConfVar  *DeviceConfVarService
-	DeviceTag      *DeviceTagService
-	ServiceInstall *ServiceInstallService
-	DeviceType     *DeviceTypeService
+	Application        *ApplicationService
+	ApplicationEnvVar  *ApplicationEnvVarService
+	ApplicationConfVar *ApplicationConfVarService
+	Device             *DeviceService
+	Release            *ReleaseService
+	ReleaseTag         *ReleaseTagService
+	DeviceEnvVar       *DeviceEnvVarService
+	DeviceServVar      *DeviceServVarService
+	DeviceConfVar      *DeviceConfVarService
+	DeviceTag          *DeviceTagService
+	ServiceInstall     *ServiceInstallService
+	ServiceEnvVar      *ServiceEnvVarService
+	DeviceType         *DeviceTypeService
 }
 
 type service struct {
 	client *Client
 }
 
+// maxErrorBodySize is the maximum number of bytes of an error response body retained in an ErrorResponse.
+const maxErrorBodySize = 1 << 20
+
+// Sentinel errors matched by an ErrorResponse with errors.Is, based on the status code of the response.
+var (
+	// ErrUnauthorized is matched by responses with status 401 Unauthorized.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrForbidden is matched by responses with status 403 Forbidden.
+	ErrForbidden = errors.New("forbidden")
+	// ErrNotFound is matched by responses with status 404 Not Found.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is matched by responses with status 409 Conflict, and by responses reporting a
+	// violated unique key constraint.
+	ErrConflict = errors.New("conflict")
+	// ErrRateLimited is matched by responses with status 429 Too Many Requests.
+	ErrRateLimited = errors.New("rate limited")
+	// ErrLocked is matched by responses with status 423 Locked, such as when rebooting a device holding an update
+	// lock without forcing it.
+	ErrLocked = errors.New("locked")
+)
+
 // An ErrorResponse reports the error caused by an API request.
 //
 //nolint:errname // TODO: Consider breaking change to follow XxxError naming convention.
 type ErrorResponse struct {
 	// HTTP response that caused this error
 	Response *http.Response
+	// Body is the raw response body, truncated to 1 MiB.
+	Body []byte
+	// Message is the error message reported by the API, if any.
+	Message string
+	// Code is the error code or name reported by the API, if any.
+	Code string
 }
 
 func (r *ErrorResponse) Error() string {
-	return fmt.Sprintf(
+	msg := fmt.Sprintf(
 		"%v %v: %d",
 		r.Response.Request.Method,
 		r.Response.Request.URL,
 		r.Response.StatusCode,
 	)
+	if r.Message != "" {
+		msg += " " + r.Message
+	}
+	return msg
 }
 
-// New returns a new Balena API client.
-func New(httpClient *http.Client, authToken string) *Client {
-	if httpClient == nil {
-		httpClient = &http.Client{}
+// Is reports whether the error matches one of the sentinel errors such as ErrNotFound.
+func (r *ErrorResponse) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return r.Response.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return r.Response.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return r.Response.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return r.Response.StatusCode == http.StatusConflict ||
+			strings.Contains(strings.ToLower(r.Message), "unique key constraint")
+	case ErrRateLimited:
+		return r.Response.StatusCode == http.StatusTooManyRequests
+	case ErrLocked:
+		return r.Response.StatusCode == http.StatusLocked
 	}
+	return false
+}
+
+// New returns a new Balena API client using the given HTTP client, or a default one if nil, configured by the given
+// options. As New does not return an error, an invalid option makes every request of the client fail with an
+// error describing it. Use NewClient to handle invalid options when creating the client.
+func New(httpClient *http.Client, authToken string, opts ...Option) *Client {
+	if httpClient != nil {
+		opts = append([]Option{WithHTTPClient(httpClient)}, opts...)
+	}
+	return newClient(authToken, opts)
+}
+
+// NewClient returns a new Balena API client configured by the given options.
+// An error is returned if an option is invalid, such as a base URL without a trailing slash.
+func NewClient(authToken string, opts ...Option) (*Client, error) {
+	c := newClient(authToken, opts)
+	if c.optionErr != nil {
+		return nil, c.optionErr
+	}
+	return c, nil
+}
+
+// newClient returns a new Balena API client configured by the given options. Options after an invalid one are not
+// applied, and the error is kept in the optionErr field of the client.
+func newClient(authToken string, opts []Option) *Client {
 	baseURL, _ := url.Parse(defaultBaseURL)
-	c := &Client{client: httpClient, BaseURL: baseURL, UserAgent: userAgent, authToken: authToken}
+	c := &Client{client: &http.Client{}, BaseURL: baseURL, UserAgent: userAgent, authToken: authToken}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			c.optionErr = fmt.Errorf("invalid client option: %w", err)
+			break
+		}
+	}
+	c.applyMiddlewares()
 	c.common.client = c
 	c.Application = (*ApplicationService)(&c.common)
+	c.ApplicationEnvVar = (*ApplicationEnvVarService)(&c.common)
+	c.ApplicationConfVar = (*ApplicationConfVarService)(&c.common)
 	c.Device = (*DeviceService)(&c.common)
 	c.Release = (*ReleaseService)(&c.common)
 	c.DeviceEnvVar = (*DeviceEnvVarService)(&c.common)
@@ -80,6 +183,7 @@ func New(httpClient *http.Client, authToken string) *Client {
 	c.DeviceTag = (*DeviceTagService)(&c.common)
 	c.ReleaseTag = (*ReleaseTagService)(&c.common)
 	c.ServiceInstall = (*ServiceInstallService)(&c.common)
+	c.ServiceEnvVar = (*ServiceEnvVarService)(&c.common)
 	c.DeviceType = (*DeviceTypeService)(&c.common)
 	return c
 }
@@ -105,7 +209,7 @@ func NewSupervisorV2(httpClient *http.Client) (*SupervisorV2Service, error) {
 	addr := os.Getenv("BALENA_SUPERVISOR_ADDRESS")
 	baseURL, err := url.Parse(addr + "/")
 	if err != nil {
-		return nil, fmt.Errorf("unable to find supervisor address: %v", err)
+		return nil, fmt.Errorf("unable to find supervisor address: %w", err)
 	}
 	key := os.Getenv("BALENA_SUPERVISOR_API_KEY")
 	if key == "" {
@@ -119,6 +223,55 @@ func NewSupervisorV2(httpClient *http.Client) (*SupervisorV2Service, error) {
 	if appID == "" {
 		return nil, fmt.Errorf("unable to retrieve balena application ID. BALENA_APP_ID not set")
 	}
+	return newLocalSupervisorV2(httpClient, baseURL, key, uuid, appID), nil
+}
+
+// SupervisorConfig configures a client of the local supervisor API. Inside balena, the values are given by
+// the BALENA_SUPERVISOR_ADDRESS, BALENA_SUPERVISOR_API_KEY, BALENA_DEVICE_UUID and BALENA_APP_ID
+// environment variables, which are read by NewSupervisorV2 and NewSupervisorV1.
+type SupervisorConfig struct {
+	// Address is the address of the supervisor, such as `http://127.0.0.1:48484`.
+	Address string
+	// APIKey is the supervisor API key.
+	APIKey string
+	// DeviceUUID is the UUID of the device.
+	DeviceUUID string
+	// AppID is the ID of the application running on the device.
+	AppID int64
+}
+
+// baseURL validates the config and returns the base URL of the supervisor.
+func (c SupervisorConfig) baseURL() (*url.URL, error) {
+	baseURL, err := url.Parse(c.Address + "/")
+	if err != nil || baseURL.Scheme == "" || baseURL.Host == "" {
+		return nil, fmt.Errorf("invalid supervisor address %q", c.Address)
+	}
+	switch {
+	case c.APIKey == "":
+		return nil, errors.New("supervisor API key must not be empty")
+	case c.DeviceUUID == "":
+		return nil, errors.New("device UUID must not be empty")
+	case c.AppID == 0:
+		return nil, errors.New("application ID must not be zero")
+	}
+	return baseURL, nil
+}
+
+// NewSupervisorV2FromConfig returns a new supervisor v2 API client for the local supervisor described by
+// config, such as a fake supervisor in tests.
+func NewSupervisorV2FromConfig(httpClient *http.Client, config SupervisorConfig) (*SupervisorV2Service, error) {
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	baseURL, err := config.baseURL()
+	if err != nil {
+		return nil, err
+	}
+	appID := strconv.FormatInt(config.AppID, 10)
+	return newLocalSupervisorV2(httpClient, baseURL, config.APIKey, config.DeviceUUID, appID), nil
+}
+
+func newLocalSupervisorV2(httpClient *http.Client, baseURL *url.URL, key, uuid, appID string) *SupervisorV2Service {
 	c := Client{client: httpClient, BaseURL: baseURL, UserAgent: userAgent}
 	svc := service{
 		client: &c,
@@ -130,7 +283,7 @@ func NewSupervisorV2(httpClient *http.Client) (*SupervisorV2Service, error) {
 		apiKey:     key,
 		appID:      appID,
 		local:      true,
-	}, nil
+	}
 }
 
 // SupervisorV1 returns a SupervisorV1Service to be used with balena cloud.Supervisor
@@ -154,7 +307,7 @@ func NewSupervisorV1(httpClient *http.Client) (*SupervisorV1Service, error) {
 	addr := os.Getenv("BALENA_SUPERVISOR_ADDRESS")
 	baseURL, err := url.Parse(addr + "/")
 	if err != nil {
-		return nil, fmt.Errorf("unable to find supervisor address: %v", err)
+		return nil, fmt.Errorf("unable to find supervisor address: %w", err)
 	}
 	key := os.Getenv("BALENA_SUPERVISOR_API_KEY")
 	if key == "" {
@@ -168,6 +321,24 @@ func NewSupervisorV1(httpClient *http.Client) (*SupervisorV1Service, error) {
 	if appID == "" {
 		return nil, fmt.Errorf("unable to retrieve balena application ID. BALENA_APP_ID not set")
 	}
+	return newLocalSupervisorV1(httpClient, baseURL, key, uuid, appID), nil
+}
+
+// NewSupervisorV1FromConfig returns a new supervisor v1 API client for the local supervisor described by
+// config, such as a fake supervisor in tests.
+func NewSupervisorV1FromConfig(httpClient *http.Client, config SupervisorConfig) (*SupervisorV1Service, error) {
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	baseURL, err := config.baseURL()
+	if err != nil {
+		return nil, err
+	}
+	appID := strconv.FormatInt(config.AppID, 10)
+	return newLocalSupervisorV1(httpClient, baseURL, config.APIKey, config.DeviceUUID, appID), nil
+}
+
+func newLocalSupervisorV1(httpClient *http.Client, baseURL *url.URL, key, uuid, appID string) *SupervisorV1Service {
 	c := Client{client: httpClient, BaseURL: baseURL, UserAgent: userAgent}
 	svc := service{
 		client: &c,
@@ -179,13 +350,18 @@ func NewSupervisorV1(httpClient *http.Client) (*SupervisorV1Service, error) {
 		apiKey:     key,
 		appID:      appID,
 		local:      true,
-	}, nil
+	}
 }
 
 // NewRequest creates an API request. A relative URL can be provided in urlStr, which will be resolved to the
 // BaseURL of the Client. Relative URLS should always be specified without a preceding slash. If specified, the
 // value pointed to by body is JSON encoded and included in as the request body.
-// A raw query string can be specified by rawQuery.
+// A raw query string can be specified by rawQuery. The request is authenticated with the token given to the
+// constructor, or with a token from the TokenSource set by WithTokenSource.
+//
+// The API version of resource paths such as `v6/device` is replaced according to WithAPIVersion and
+// WithResourceVersion. An error wrapping ErrUnsupported is returned if the endpoint is not provided by the
+// backend of the Client.
 func (c *Client) NewRequest(
 	ctx context.Context,
 	method string,
@@ -193,9 +369,36 @@ func (c *Client) NewRequest(
 	rawQuery string,
 	body interface{},
 ) (*http.Request, error) {
+	token := c.authToken
+	if c.tokenSource != nil {
+		var err error
+		token, err = c.tokenSource.Token(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get auth token: %w", err)
+		}
+	}
+	return c.newRequest(ctx, method, urlStr, rawQuery, body, token)
+}
+
+// newRequest creates an API request as described by NewRequest, authenticated with the given token.
+func (c *Client) newRequest(
+	ctx context.Context,
+	method string,
+	urlStr string,
+	rawQuery string,
+	body interface{},
+	token string,
+) (*http.Request, error) {
+	if c.optionErr != nil {
+		return nil, c.optionErr
+	}
 	if !strings.HasSuffix(c.BaseURL.Path, "/") {
 		return nil, fmt.Errorf("BaseURL must have a trailing slash, but %q does not", c.BaseURL)
 	}
+	urlStr, err := c.resolvePath(urlStr)
+	if err != nil {
+		return nil, err
+	}
 	u, err := c.BaseURL.Parse(urlStr)
 	if err != nil {
 		return nil, err
@@ -224,8 +427,8 @@ func (c *Client) NewRequest(
 	if c.UserAgent != "" {
 		req.Header.Set("User-Agent", c.UserAgent)
 	}
-	if c.authToken != "" {
-		req.Header.Add("Authorization", "Bearer "+c.authToken)
+	if token != "" {
+		req.Header.Add("Authorization", "Bearer "+token)
 	}
 	return req, nil
 }
@@ -233,8 +436,16 @@ func (c *Client) NewRequest(
 // Do sends an API request and returns the API response. The API response is JSON decoded and stored in the value
 // pointed to by v, or returned as an error if an API error has occurred. If v implements the io.Writer interface,
 // the raw response will be written to v, without attempting to decode it.
+//
+// Failed requests are retried according to the client's RetryPolicy, and every attempt waits on the
+// client's RateLimiter.
 func (c *Client) Do(req *http.Request, v interface{}) error {
-	resp, err := c.client.Do(req)
+	if c.timeout > 0 {
+		ctx, cancel := context.WithTimeout(req.Context(), c.timeout)
+		defer cancel()
+		req = req.WithContext(ctx)
+	}
+	resp, err := c.doWithRetry(req)
 	if err != nil {
 		return err
 	}
@@ -264,12 +475,228 @@ func (c *Client) Do(req *http.Request, v interface{}) error {
 	return err
 }
 
+// doEntityRequest performs a request on the entity with given ID of the resource at basePath, such as updating
+// or deleting a variable.
+func (c *Client) doEntityRequest(ctx context.Context, method, basePath string, id int64, body interface{}) error {
+	return c.doQueryRequest(ctx, method, odata.EntityURL(basePath, strconv.FormatInt(id, 10)), "", body)
+}
+
+// variable is implemented by the responses of the variable services, giving upsertVariable access to the ID and
+// value of a variable.
+type variable interface {
+	variableFields() (id int64, value *string)
+}
+
+// upsertVariable sets the variable with given name of the resource at basePath to value, creating it if it does
+// not exist. get returns the variable, or nil if it does not exist. create creates the variable with the value and
+// returns it, or returns nil if it has to be retrieved with get. An existing variable is updated by its ID. An error
+// wrapping ErrNotFound is returned if the variable is deleted concurrently.
+func (c *Client) upsertVariable(
+	ctx context.Context,
+	basePath string,
+	name string,
+	value string,
+	get func() (variable, error),
+	create func() (variable, error),
+) (variable, error) {
+	existing, err := get()
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		created, err := create()
+		if err != nil && !errors.Is(err, ErrConflict) {
+			return nil, err
+		}
+		if err == nil && created != nil {
+			return created, nil
+		}
+		// Created concurrently, or created without returning it. Get it and update it if needed.
+		if existing, err = get(); err != nil {
+			return nil, err
+		}
+		if existing == nil {
+			return nil, fmt.Errorf("variable %s disappeared during upsert: %w", name, ErrNotFound)
+		}
+	}
+	id, current := existing.variableFields()
+	if *current == value {
+		return existing, nil
+	}
+	body := struct {
+		Value string `json:"value"`
+	}{Value: value}
+	if err := c.doEntityRequest(ctx, http.MethodPatch, basePath, id, body); err != nil {
+		return nil, err
+	}
+	*current = value
+	return existing, nil
+}
+
+// entityBatchSize is the maximum number of entities addressed by ID in a single request, keeping the length of the
+// request URL bounded.
+const entityBatchSize = 50
+
+// doFilterRequest performs a PATCH or DELETE request on the entities of the resource at basePath matching filter.
+//
+// If count is true or the client uses WithStrictMatching, the IDs of the matching entities are listed first, the
+// request is performed on exactly those entities, and their number is returned. Entities that start matching
+// concurrently are left untouched. With count, no match is reported by returning zero. Otherwise, an error
+// wrapping ErrNotFound is returned when nothing matches with WithStrictMatching. If the entities are not counted,
+// the returned number is -1.
+func (c *Client) doFilterRequest(
+	ctx context.Context,
+	method string,
+	basePath string,
+	filter odata.Filter,
+	body interface{},
+	count bool,
+) (int, error) {
+	if !count && !c.strictMatching {
+		query, err := filterQuery(filter)
+		if err != nil {
+			return 0, fmt.Errorf("unable to create request: %w", err)
+		}
+		if err := c.doQueryRequest(ctx, method, basePath, query, body); err != nil {
+			return 0, err
+		}
+		return -1, nil
+	}
+	query, err := odata.NewQuery().Filter(filter).Select("id").Encode()
+	if err != nil {
+		return 0, fmt.Errorf("unable to create request: %w", err)
+	}
+	req, err := c.NewRequest(ctx, http.MethodGet, basePath, query, nil)
+	if err != nil {
+		return 0, fmt.Errorf("unable to create request: %w", err)
+	}
+	type Response struct {
+		D []struct {
+			ID int64 `json:"id"`
+		} `json:"d,omitempty"`
+	}
+	resp := &Response{}
+	if err := c.Do(req, resp); err != nil {
+		return 0, fmt.Errorf("unable to list matching entities: %w", err)
+	}
+	if len(resp.D) == 0 {
+		if count {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("no matching entity of %s: %w", basePath, ErrNotFound)
+	}
+	ids := make([]int64, 0, len(resp.D))
+	for _, entity := range resp.D {
+		ids = append(ids, entity.ID)
+	}
+	if _, err := c.doIDsRequest(ctx, method, basePath, ids, body); err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+// doIDsRequest performs a PATCH or DELETE request on the entities of the resource at basePath with given IDs, in
+// batches of entityBatchSize entities. If a request fails, the number of entities handled by the previous requests
+// is returned along with the error.
+func (c *Client) doIDsRequest(
+	ctx context.Context,
+	method string,
+	basePath string,
+	ids []int64,
+	body interface{},
+) (int, error) {
+	for start := 0; start < len(ids); start += entityBatchSize {
+		end := start + entityBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		filters := make([]odata.Filter, 0, end-start)
+		for _, id := range ids[start:end] {
+			filters = append(filters, odata.Eq("id", id))
+		}
+		query, err := filterQuery(odata.Or(filters...))
+		if err != nil {
+			return start, fmt.Errorf("unable to create request: %w", err)
+		}
+		if err := c.doQueryRequest(ctx, method, basePath, query, body); err != nil {
+			return start, err
+		}
+	}
+	return len(ids), nil
+}
+
+// doQueryRequest performs a request with the given raw query on the resource at path, discarding the response.
+func (c *Client) doQueryRequest(ctx context.Context, method, path, query string, body interface{}) error {
+	req, err := c.NewRequest(ctx, method, path, query, body)
+	if err != nil {
+		return fmt.Errorf("unable to create request: %w", err)
+	}
+	if err := c.Do(req, nil); err != nil {
+		return fmt.Errorf("unable to perform request: %w", err)
+	}
+	return nil
+}
+
 // checkResponse checks the API response for errors, and returns them if present. A response is considered an
-// error if it has a status code outside the 200 range.
+// error if it has a status code outside the 200 range. The body of an error response is read and retained in
+// the returned ErrorResponse.
 func checkResponse(r *http.Response) error {
 	if c := r.StatusCode; c >= 200 && c <= 299 {
 		return nil
 	}
 	errorResponse := &ErrorResponse{Response: r}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxErrorBodySize))
+	if err == nil {
+		errorResponse.Body = body
+		errorResponse.Message, errorResponse.Code = parseErrorBody(body)
+	}
 	return errorResponse
 }
+
+// parseErrorBody extracts the error message and code from an error response body. Balena reports errors
+// either as plain text, as a JSON string or as a JSON object such as `{"message": "...", "code": "..."}`,
+// where the message may also be found in an "error" field.
+func parseErrorBody(body []byte) (message string, code string) {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return "", ""
+	}
+	var s string
+	if err := json.Unmarshal(body, &s); err == nil {
+		return s, ""
+	}
+	var obj struct {
+		Message string          `json:"message"`
+		Error   json.RawMessage `json:"error"`
+		Code    json.RawMessage `json:"code"`
+		Name    string          `json:"name"`
+	}
+	if err := json.Unmarshal(body, &obj); err != nil {
+		return string(body), ""
+	}
+	message = obj.Message
+	if len(obj.Error) > 0 {
+		var nested struct {
+			Message string `json:"message"`
+		}
+		switch {
+		case json.Unmarshal(obj.Error, &s) == nil:
+			if message == "" {
+				message = s
+			}
+		case json.Unmarshal(obj.Error, &nested) == nil:
+			if message == "" {
+				message = nested.Message
+			}
+		}
+	}
+	code = obj.Name
+	if len(obj.Code) > 0 {
+		if json.Unmarshal(obj.Code, &s) == nil {
+			code = s
+		} else {
+			code = string(obj.Code)
+		}
+	}
+	return message, code
+}
@@ -2,11 +2,13 @@ package balena
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 
 	"gotest.tools/v3/assert"
@@ -144,3 +146,106 @@ func testMethod(t *testing.T, r *http.Request, expected string) {
 		t.Errorf("Request method = %v, expected %v", r.Method, expected)
 	}
 }
+
+func TestDo_ErrorResponse(t *testing.T) {
+	for _, tt := range []struct {
+		name            string
+		status          int
+		contentType     string
+		body            string
+		expectedMessage string
+		expectedCode    string
+		expectedErr     error
+	}{
+		{
+			name:            "plain text conflict",
+			status:          http.StatusConflict,
+			contentType:     "text/plain",
+			body:            "Unique key constraint violated",
+			expectedMessage: "Unique key constraint violated",
+			expectedErr:     ErrConflict,
+		},
+		{
+			name:            "unique key constraint reported as bad request",
+			status:          http.StatusBadRequest,
+			contentType:     "text/plain",
+			body:            "Unique key constraint violated\n",
+			expectedMessage: "Unique key constraint violated",
+			expectedErr:     ErrConflict,
+		},
+		{
+			name:            "json object",
+			status:          http.StatusUnauthorized,
+			contentType:     "application/json",
+			body:            `{"name":"BalenaNotLoggedIn","message":"You have to log in"}`,
+			expectedMessage: "You have to log in",
+			expectedCode:    "BalenaNotLoggedIn",
+			expectedErr:     ErrUnauthorized,
+		},
+		{
+			name:            "json error field",
+			status:          http.StatusNotFound,
+			contentType:     "application/json",
+			body:            `{"error":{"message":"Device not found"},"code":404}`,
+			expectedMessage: "Device not found",
+			expectedCode:    "404",
+			expectedErr:     ErrNotFound,
+		},
+		{
+			name:            "json string",
+			status:          http.StatusForbidden,
+			contentType:     "application/json",
+			body:            `"Forbidden"`,
+			expectedMessage: "Forbidden",
+			expectedErr:     ErrForbidden,
+		},
+		{
+			name:        "empty body",
+			status:      http.StatusTooManyRequests,
+			expectedErr: ErrRateLimited,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			client, mux, cleanup := newFixture()
+			defer cleanup()
+			mux.HandleFunc("/foo", func(w http.ResponseWriter, r *http.Request) {
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				}
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			})
+			req, err := client.NewRequest(context.Background(), http.MethodGet, "foo", "", nil)
+			assert.NilError(t, err)
+			// When
+			err = client.Do(req, nil)
+			// Then
+			var errorResponse *ErrorResponse
+			assert.Assert(t, errors.As(err, &errorResponse))
+			assert.Equal(t, tt.body, string(errorResponse.Body))
+			assert.Equal(t, tt.expectedMessage, errorResponse.Message)
+			assert.Equal(t, tt.expectedCode, errorResponse.Code)
+			assert.Assert(t, errors.Is(err, tt.expectedErr))
+			expectedSuffix := strings.TrimSpace(fmt.Sprintf("%d %s", tt.status, tt.expectedMessage))
+			assert.Assert(t, strings.HasSuffix(err.Error(), expectedSuffix))
+		})
+	}
+}
+
+func TestErrorResponse_Wrapped(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/"+deviceBasePath+"(123)", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	})
+	// When
+	_, err := client.Device.Get(context.Background(), DeviceID(123))
+	// Then
+	assert.Assert(t, errors.Is(err, ErrUnauthorized))
+	assert.Assert(t, !errors.Is(err, ErrNotFound))
+	assert.ErrorContains(t, err, "unable to get device")
+	assert.ErrorContains(t, err, "401 Unauthorized")
+}
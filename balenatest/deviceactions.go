@@ -0,0 +1,119 @@
+package balenatest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// supervisorProxyPrefix is the path prefix of the cloud proxy to the supervisor v1 API of devices.
+const supervisorProxyPrefix = "/supervisor/v1/"
+
+// deviceV2ActionPathPattern matches the paths of the device v2 actions of the API, such as
+// `/device/v2/<uuid>/restart-application`.
+var deviceV2ActionPathPattern = regexp.MustCompile(`^/device/v2/([^/]+)/(restart-application|purge)$`)
+
+// DeviceAction is an action performed on a device through the device v2 endpoints or the supervisor proxy of the
+// server.
+type DeviceAction struct {
+	UUID string
+	// Action is the device v2 action, "restart-application" or "purge", or the supervisor v1 action, one of
+	// "restart", "reboot", "shutdown", "purge" and "blink".
+	Action string
+	// AppID is the application ID given with a supervisor v1 action.
+	AppID int64
+	Force bool
+}
+
+// SetUpdateLock sets whether the device with the given UUID holds an update lock, which makes actions other
+// than blinking fail with 423 Locked unless forced.
+func (s *Server) SetUpdateLock(uuid string, locked bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.updateLocks[uuid] = locked
+}
+
+// DeviceActions returns the actions performed on devices, in order.
+func (s *Server) DeviceActions() []DeviceAction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]DeviceAction(nil), s.actions...)
+}
+
+func (s *Server) serveSupervisorProxy(w http.ResponseWriter, r *http.Request) {
+	action := strings.TrimPrefix(r.URL.Path, supervisorProxyPrefix)
+	switch action {
+	case "restart", "reboot", "shutdown", "purge", "blink":
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var request struct {
+		UUID string `json:"uuid"`
+		Data struct {
+			AppID int64 `json:"appId"`
+			Force bool  `json:"force"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	checkAppID := action == "restart" || action == "purge"
+	s.performAction(w, DeviceAction{
+		UUID:   request.UUID,
+		Action: action,
+		AppID:  request.Data.AppID,
+		Force:  request.Data.Force,
+	}, checkAppID)
+}
+
+func (s *Server) serveDeviceV2Action(w http.ResponseWriter, r *http.Request, uuid, action string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var request struct {
+		Force bool `json:"force"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && err != io.EOF {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.performAction(w, DeviceAction{UUID: uuid, Action: action, Force: request.Force}, false)
+}
+
+// performAction records the action if the device exists and does not hold an update lock, or the action is forced.
+// If checkAppID is set, the application ID of the action must match the device.
+func (s *Server) performAction(w http.ResponseWriter, action DeviceAction, checkAppID bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var device map[string]interface{}
+	for _, e := range s.entities["device"] {
+		if e["uuid"] == action.UUID {
+			device = e
+			break
+		}
+	}
+	if device == nil {
+		http.Error(w, "No device with uuid "+action.UUID, http.StatusNotFound)
+		return
+	}
+	if checkAppID && device["belongs_to__application"] != action.AppID {
+		http.Error(w, "Application ID does not match the device", http.StatusBadRequest)
+		return
+	}
+	if action.Action != "blink" && s.updateLocks[action.UUID] && !action.Force {
+		http.Error(w, "Updates are locked", http.StatusLocked)
+		return
+	}
+	s.actions = append(s.actions, action)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte("OK"))
+}
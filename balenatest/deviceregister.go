@@ -0,0 +1,118 @@
+package balenatest
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+)
+
+// provisioningKeyPathPattern matches the path for creating provisioning keys of an application.
+var provisioningKeyPathPattern = regexp.MustCompile(`^/api-key/application/([0-9]+)/provisioning$`)
+
+// AddProvisioningKey adds a provisioning key for registering devices with an application, and returns the key.
+func (s *Server) AddProvisioningKey(applicationID int64) string {
+	s.t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entities["application"][applicationID]; !ok {
+		s.t.Fatalf("balenatest: no application with ID %d", applicationID)
+	}
+	key := newUUID()
+	s.provisioningKeys[key] = applicationID
+	return key
+}
+
+func (s *Server) serveCreateProvisioningKey(w http.ResponseWriter, r *http.Request, applicationID string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := strconv.ParseInt(applicationID, 10, 64)
+	if err != nil {
+		http.Error(w, "invalid application ID", http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entities["application"][id]; !ok {
+		http.Error(w, "Application not found", http.StatusNotFound)
+		return
+	}
+	key := newUUID()
+	s.provisioningKeys[key] = id
+	writeJSON(w, http.StatusOK, key)
+}
+
+func (s *Server) serveRegisterDevice(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var request struct {
+		Application int64  `json:"application"`
+		UUID        string `json:"uuid"`
+		DeviceType  string `json:"device_type"`
+		APIKey      string `json:"api_key"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	const bearer = "Bearer "
+	auth := r.Header.Get("Authorization")
+	if len(auth) <= len(bearer) || s.provisioningKeys[auth[len(bearer):]] != request.Application {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var deviceTypeID int64
+	for id, deviceType := range s.entities["device_type"] {
+		if deviceType["slug"] == request.DeviceType {
+			deviceTypeID = id
+		}
+	}
+	if deviceTypeID == 0 {
+		http.Error(w, "Invalid device type", http.StatusBadRequest)
+		return
+	}
+	if request.UUID == "" || request.APIKey == "" {
+		http.Error(w, "uuid and api_key are required", http.StatusBadRequest)
+		return
+	}
+	name := request.UUID
+	if len(name) > 7 {
+		name = name[:7]
+	}
+	device, err := s.create("device", map[string]interface{}{
+		"uuid":                    request.UUID,
+		"device_name":             name,
+		"belongs_to__application": request.Application,
+		"device_type":             deviceTypeID,
+	})
+	if err != nil {
+		status := http.StatusBadRequest
+		if httpErr, ok := err.(*httpError); ok {
+			status = httpErr.status
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	for _, service := range s.sorted("service") {
+		if service["application"] == request.Application {
+			if _, err := s.create("service_install", map[string]interface{}{
+				"device":            device["id"],
+				"installs__service": service["id"],
+			}); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"id":      device["id"],
+		"uuid":    request.UUID,
+		"api_key": request.APIKey,
+	})
+}
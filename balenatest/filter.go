@@ -0,0 +1,375 @@
+package balenatest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// expr is a parsed OData filter expression.
+type expr interface {
+	// match reports whether the filter matches, given a function resolving field paths to values.
+	match(resolve func(path []string) interface{}) bool
+}
+
+type andExpr []expr
+
+func (e andExpr) match(resolve func([]string) interface{}) bool {
+	for _, operand := range e {
+		if !operand.match(resolve) {
+			return false
+		}
+	}
+	return true
+}
+
+type orExpr []expr
+
+func (e orExpr) match(resolve func([]string) interface{}) bool {
+	for _, operand := range e {
+		if operand.match(resolve) {
+			return true
+		}
+	}
+	return false
+}
+
+type notExpr struct {
+	operand expr
+}
+
+func (e notExpr) match(resolve func([]string) interface{}) bool {
+	return !e.operand.match(resolve)
+}
+
+// operand is a field path or a literal value in a comparison.
+type operand struct {
+	path    []string
+	literal interface{}
+}
+
+func (o operand) value(resolve func([]string) interface{}) interface{} {
+	if o.path != nil {
+		return resolve(o.path)
+	}
+	return o.literal
+}
+
+type compareExpr struct {
+	op          string
+	left, right operand
+}
+
+func (e compareExpr) match(resolve func([]string) interface{}) bool {
+	return compareValues(e.op, e.left.value(resolve), e.right.value(resolve))
+}
+
+// compareValues compares two values with an OData comparison operator. Numbers compare equal to strings
+// holding the same number, as the API accepts quoted IDs.
+func compareValues(op string, a, b interface{}) bool {
+	if a == nil || b == nil {
+		switch op {
+		case "eq":
+			return a == nil && b == nil
+		case "ne":
+			return a != nil || b != nil
+		}
+		return false
+	}
+	var c int
+	if x, y, ok := numbers(a, b); ok {
+		switch {
+		case x < y:
+			c = -1
+		case x > y:
+			c = 1
+		}
+	} else {
+		c = strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+	}
+	switch op {
+	case "eq":
+		return c == 0
+	case "ne":
+		return c != 0
+	case "gt":
+		return c > 0
+	case "ge":
+		return c >= 0
+	case "lt":
+		return c < 0
+	case "le":
+		return c <= 0
+	}
+	return false
+}
+
+// numbers returns a and b as numbers if at least one of them is a number and the other can be parsed as one.
+func numbers(a, b interface{}) (float64, float64, bool) {
+	x, xNumber := toNumber(a)
+	y, yNumber := toNumber(b)
+	if !xNumber && !yNumber {
+		return 0, 0, false
+	}
+	if !xNumber {
+		s, ok := a.(string)
+		if !ok {
+			return 0, 0, false
+		}
+		var err error
+		if x, err = strconv.ParseFloat(s, 64); err != nil {
+			return 0, 0, false
+		}
+	}
+	if !yNumber {
+		s, ok := b.(string)
+		if !ok {
+			return 0, 0, false
+		}
+		var err error
+		if y, err = strconv.ParseFloat(s, 64); err != nil {
+			return 0, 0, false
+		}
+	}
+	return x, y, true
+}
+
+func toNumber(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// token is a lexical token of a filter expression.
+type token struct {
+	kind  tokenKind
+	text  string
+	value interface{}
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenOpen
+	tokenClose
+	tokenWord
+	tokenLiteral
+)
+
+// tokenize splits an unescaped filter expression into tokens.
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenOpen, text: "("})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenClose, text: ")"})
+			i++
+		case c == '\'':
+			var b strings.Builder
+			j := i + 1
+			for {
+				if j >= len(s) {
+					return nil, fmt.Errorf("unterminated string literal at %d", i)
+				}
+				if s[j] == '\'' {
+					if j+1 < len(s) && s[j+1] == '\'' {
+						b.WriteByte('\'')
+						j += 2
+						continue
+					}
+					break
+				}
+				b.WriteByte(s[j])
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenLiteral, text: s[i : j+1], value: b.String()})
+			i = j + 1
+		case c == '-' || c >= '0' && c <= '9':
+			j := i + 1
+			for j < len(s) && strings.IndexByte("0123456789.eE+-", s[j]) >= 0 {
+				j++
+			}
+			text := s[i:j]
+			var value interface{}
+			if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+				value = n
+			} else if f, err := strconv.ParseFloat(text, 64); err == nil {
+				value = f
+			} else {
+				return nil, fmt.Errorf("invalid number %q", text)
+			}
+			tokens = append(tokens, token{kind: tokenLiteral, text: text, value: value})
+			i = j
+		case c == '_' || c == '$' || unicode.IsLetter(rune(c)):
+			j := i + 1
+			for j < len(s) && (s[j] == '_' || s[j] == '/' || s[j] == '$' ||
+				unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j]))) {
+				j++
+			}
+			text := s[i:j]
+			switch text {
+			case "true":
+				tokens = append(tokens, token{kind: tokenLiteral, text: text, value: true})
+			case "false":
+				tokens = append(tokens, token{kind: tokenLiteral, text: text, value: false})
+			case "null":
+				tokens = append(tokens, token{kind: tokenLiteral, text: text, value: nil})
+			default:
+				tokens = append(tokens, token{kind: tokenWord, text: text})
+			}
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected character %q at %d", c, i)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF}), nil
+}
+
+// filterParser is a recursive descent parser of filter expressions.
+type filterParser struct {
+	resource string
+	tokens   []token
+	pos      int
+}
+
+// parseFilter parses an unescaped $filter expression for the given resource.
+func parseFilter(resource, s string) (expr, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid $filter: %w", err)
+	}
+	p := &filterParser{resource: resource, tokens: tokens}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("invalid $filter: %w", err)
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, fmt.Errorf("invalid $filter: unexpected %q", t.text)
+	}
+	return e, nil
+}
+
+func (p *filterParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *filterParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *filterParser) isWord(text string) bool {
+	t := p.peek()
+	return t.kind == tokenWord && t.text == text
+}
+
+func (p *filterParser) parseOr() (expr, error) {
+	e, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	operands := orExpr{e}
+	for p.isWord("or") {
+		p.next()
+		e, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, e)
+	}
+	if len(operands) == 1 {
+		return operands[0], nil
+	}
+	return operands, nil
+}
+
+func (p *filterParser) parseAnd() (expr, error) {
+	e, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	operands := andExpr{e}
+	for p.isWord("and") {
+		p.next()
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, e)
+	}
+	if len(operands) == 1 {
+		return operands[0], nil
+	}
+	return operands, nil
+}
+
+func (p *filterParser) parseUnary() (expr, error) {
+	if p.isWord("not") {
+		p.next()
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{operand: e}, nil
+	}
+	if p.peek().kind == tokenOpen {
+		p.next()
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.kind != tokenClose {
+			return nil, fmt.Errorf("expected ) but got %q", t.text)
+		}
+		return e, nil
+	}
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	op := p.next()
+	switch op.text {
+	case "eq", "ne", "gt", "ge", "lt", "le":
+	default:
+		return nil, fmt.Errorf("expected comparison operator but got %q", op.text)
+	}
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return compareExpr{op: op.text, left: left, right: right}, nil
+}
+
+func (p *filterParser) parseOperand() (operand, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenLiteral:
+		return operand{literal: t.value}, nil
+	case tokenWord:
+		path := strings.Split(t.text, "/")
+		if _, err := resolveField(p.resource, path); err != nil {
+			return operand{}, err
+		}
+		return operand{path: path}, nil
+	case tokenEOF:
+		return operand{}, fmt.Errorf("unexpected end of expression")
+	}
+	return operand{}, fmt.Errorf("unexpected %q", t.text)
+}
@@ -0,0 +1,206 @@
+package balenatest
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// query is a parsed OData query.
+type query struct {
+	selects []string
+	expand  map[string]*query
+	filter  expr
+	orderBy []orderTerm
+	top     int
+	skip    int
+}
+
+type orderTerm struct {
+	path []string
+	desc bool
+}
+
+// parseQuery parses the raw query of a request for the given resource. Options not starting with `$`,
+// such as an API key, are ignored.
+func parseQuery(resource, rawQuery string) (*query, error) {
+	var options []string
+	for _, part := range strings.Split(rawQuery, "&") {
+		if part == "" {
+			continue
+		}
+		key, value := part, ""
+		if i := strings.IndexByte(part, '='); i >= 0 {
+			key, value = part[:i], part[i+1:]
+		}
+		key, err := url.QueryUnescape(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid query option %q: %w", part, err)
+		}
+		if !strings.HasPrefix(key, "$") {
+			continue
+		}
+		value, err = url.QueryUnescape(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid query option %q: %w", part, err)
+		}
+		options = append(options, key+"="+value)
+	}
+	return parseOptions(resource, options)
+}
+
+// parseOptions parses unescaped `$option=value` query options for the given resource.
+func parseOptions(resource string, options []string) (*query, error) {
+	q := &query{top: -1}
+	for _, option := range options {
+		i := strings.IndexByte(option, '=')
+		if i < 0 {
+			return nil, fmt.Errorf("query option %q has no value", option)
+		}
+		key, value := option[:i], option[i+1:]
+		var err error
+		switch key {
+		case "$select":
+			err = q.parseSelect(resource, value)
+		case "$expand":
+			err = q.parseExpand(resource, value)
+		case "$filter":
+			q.filter, err = parseFilter(resource, value)
+		case "$orderby":
+			err = q.parseOrderBy(resource, value)
+		case "$top":
+			q.top, err = strconv.Atoi(value)
+			if err == nil && q.top < 0 {
+				err = fmt.Errorf("negative $top %d", q.top)
+			}
+		case "$skip":
+			q.skip, err = strconv.Atoi(value)
+			if err == nil && q.skip < 0 {
+				err = fmt.Errorf("negative $skip %d", q.skip)
+			}
+		default:
+			err = fmt.Errorf("unsupported query option %s", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return q, nil
+}
+
+func (q *query) parseSelect(resource, value string) error {
+	for _, name := range splitTopLevel(value, ',') {
+		name = strings.TrimSpace(name)
+		if _, err := resolveField(resource, []string{name}); err != nil {
+			return err
+		}
+		q.selects = append(q.selects, name)
+	}
+	return nil
+}
+
+func (q *query) parseExpand(resource, value string) error {
+	if q.expand == nil {
+		q.expand = map[string]*query{}
+	}
+	for _, item := range splitTopLevel(value, ',') {
+		item = strings.TrimSpace(item)
+		name, nested := item, ""
+		if i := strings.IndexByte(item, '('); i >= 0 {
+			if !strings.HasSuffix(item, ")") {
+				return fmt.Errorf("unbalanced parentheses in $expand %q", item)
+			}
+			name, nested = item[:i], item[i+1:len(item)-1]
+		}
+		f, ok := schema[resource].fields[name]
+		if !ok || f.ref == "" {
+			return fmt.Errorf("cannot expand %q of %s", name, resource)
+		}
+		var options []string
+		if nested != "" {
+			options = splitTopLevel(nested, ';')
+		}
+		expanded, err := parseOptions(f.ref, options)
+		if err != nil {
+			return fmt.Errorf("$expand %s: %w", name, err)
+		}
+		q.expand[name] = expanded
+	}
+	return nil
+}
+
+func (q *query) parseOrderBy(resource, value string) error {
+	for _, item := range splitTopLevel(value, ',') {
+		fields := strings.Fields(item)
+		if len(fields) == 0 || len(fields) > 2 {
+			return fmt.Errorf("invalid $orderby %q", item)
+		}
+		term := orderTerm{path: strings.Split(fields[0], "/")}
+		if len(fields) == 2 {
+			switch fields[1] {
+			case "asc":
+			case "desc":
+				term.desc = true
+			default:
+				return fmt.Errorf("invalid order %q", fields[1])
+			}
+		}
+		if _, err := resolveField(resource, term.path); err != nil {
+			return err
+		}
+		q.orderBy = append(q.orderBy, term)
+	}
+	return nil
+}
+
+// splitTopLevel splits s at each sep that is outside of parentheses and string literals.
+func splitTopLevel(s string, sep byte) []string {
+	var parts []string
+	depth, start, quoted := 0, 0, false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\'':
+			quoted = !quoted
+		case quoted:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == sep && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// resolveField validates a navigation path such as `device/uuid` from the given resource, and returns the
+// field at the end of the path.
+func resolveField(resource string, path []string) (field, error) {
+	for i, name := range path {
+		if isImplicitField(name) {
+			if i != len(path)-1 {
+				return field{}, fmt.Errorf("cannot navigate through %q of %s", name, resource)
+			}
+			return field{}, nil
+		}
+		f, ok := schema[resource].fields[name]
+		if !ok {
+			return field{}, fmt.Errorf("unknown field %q of %s", name, resource)
+		}
+		if i == len(path)-1 {
+			return f, nil
+		}
+		if f.ref == "" {
+			return field{}, fmt.Errorf("cannot navigate through %q of %s", name, resource)
+		}
+		resource = f.ref
+	}
+	return field{}, fmt.Errorf("empty field path")
+}
+
+// isImplicitField reports whether name is a field present on all resources.
+func isImplicitField(name string) bool {
+	return name == "id" || name == "created_at" || name == "modified_at"
+}
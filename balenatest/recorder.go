@@ -0,0 +1,330 @@
+package balenatest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"unicode"
+)
+
+// redacted replaces credentials in recorded interactions.
+const redacted = "REDACTED"
+
+// tokenPaths are the paths of endpoints responding with a session token or key in the response body.
+var tokenPaths = map[string]bool{
+	"/login_":                true,
+	"/user/v1/refresh-token": true,
+	"/auth/totp/verify":      true,
+}
+
+// deviceRegisterPath is the path of the endpoint registering devices, which sends and responds with the API key
+// of the device.
+const deviceRegisterPath = "/device/register"
+
+// Mode is the mode of a Recorder.
+type Mode int
+
+const (
+	// ModeReplay replays recorded interactions without sending requests.
+	ModeReplay Mode = iota
+	// ModeRecord sends requests and records the interactions.
+	ModeRecord
+)
+
+// Interaction is a recorded request and its response.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is a recorded request, with credentials redacted.
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	Path   string      `json:"path"`
+	Query  string      `json:"query,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// RecordedResponse is a recorded response.
+type RecordedResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// Recorder is an HTTP transport that records interactions with the API to a fixture file, and replays them
+// in later test runs without network access.
+//
+// The Authorization header, `apikey` query parameters used by the supervisor API, login credentials, session
+// tokens, provisioning keys and device API keys are redacted when recording. When replaying, requests are
+// matched by method, path and query, where the query is normalized so that equivalent OData queries such as
+// `%24filter=a%20eq%201` and `$filter=a+eq+1` match.
+// Identical requests are replayed in the order they were recorded.
+type Recorder struct {
+	mode Mode
+	path string
+	next http.RoundTripper
+
+	mu           sync.Mutex
+	interactions []*Interaction
+	replayed     []bool
+}
+
+// NewRecorder returns a Recorder for the fixture file at path. In ModeReplay, the fixture file is read
+// immediately. In ModeRecord, it is written when the test finishes.
+func NewRecorder(t testing.TB, path string, mode Mode) *Recorder {
+	t.Helper()
+	r := &Recorder{mode: mode, path: path, next: http.DefaultTransport}
+	switch mode {
+	case ModeReplay:
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("balenatest: unable to read fixture: %v", err)
+		}
+		if err := json.Unmarshal(b, &r.interactions); err != nil {
+			t.Fatalf("balenatest: invalid fixture %s: %v", path, err)
+		}
+		r.replayed = make([]bool, len(r.interactions))
+	case ModeRecord:
+		t.Cleanup(func() {
+			if err := r.save(); err != nil {
+				t.Errorf("balenatest: unable to write fixture: %v", err)
+			}
+		})
+	default:
+		t.Fatalf("balenatest: invalid recorder mode %d", mode)
+	}
+	return r
+}
+
+// Middleware returns a transport recording or replaying requests sent through next, for use with
+// balena.WithMiddleware. In ModeReplay, next is never used.
+func (r *Recorder) Middleware(next http.RoundTripper) http.RoundTripper {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.next = next
+	return r
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	request, err := recordRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.mode == ModeReplay {
+		return r.replay(req, request)
+	}
+	r.mu.Lock()
+	next := r.next
+	r.mu.Unlock()
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.interactions = append(r.interactions, &Interaction{
+		Request: *request,
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header.Clone(),
+			Body:       redactResponseBody(req.URL.Path, body),
+		},
+	})
+	return resp, nil
+}
+
+// replay returns the response of the first interaction matching request that has not been replayed yet.
+func (r *Recorder) replay(req *http.Request, request *RecordedRequest) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	query := normalizeQuery(request.Query)
+	for i, interaction := range r.interactions {
+		if r.replayed[i] ||
+			interaction.Request.Method != request.Method ||
+			interaction.Request.Path != request.Path ||
+			normalizeQuery(interaction.Request.Query) != query {
+			continue
+		}
+		r.replayed[i] = true
+		status := interaction.Response.StatusCode
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+			StatusCode:    status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        interaction.Response.Header.Clone(),
+			Body:          io.NopCloser(strings.NewReader(interaction.Response.Body)),
+			ContentLength: int64(len(interaction.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("balenatest: no recorded interaction for %s %s?%s", request.Method, request.Path, query)
+}
+
+// save writes the recorded interactions to the fixture file.
+func (r *Recorder) save() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	interactions := r.interactions
+	if interactions == nil {
+		interactions = []*Interaction{}
+	}
+	b, err := json.MarshalIndent(interactions, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, append(b, '\n'), 0o600)
+}
+
+// recordRequest returns the recorded form of a request, with credentials redacted. The request body is
+// restored so that the request can still be sent.
+func recordRequest(req *http.Request) (*RecordedRequest, error) {
+	request := &RecordedRequest{
+		Method: req.Method,
+		Path:   req.URL.Path,
+		Query:  redactQuery(req.URL.RawQuery),
+		Header: req.Header.Clone(),
+	}
+	if request.Header.Get("Authorization") != "" {
+		request.Header.Set("Authorization", redacted)
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		request.Body = string(body)
+		switch {
+		case req.URL.Path == "/login_":
+			// The body holds the password of the user.
+			request.Body = redacted
+		case req.URL.Path == deviceRegisterPath:
+			request.Body = redactJSONField(body, "api_key")
+		}
+	}
+	return request, nil
+}
+
+// redactResponseBody returns the recorded form of the body of a response to a request with the given path, with
+// session tokens and keys redacted.
+func redactResponseBody(path string, body []byte) string {
+	switch {
+	case tokenPaths[path] && len(body) > 0:
+		return redacted
+	case strings.HasPrefix(path, "/api-key/") && len(body) > 0:
+		// Provisioning keys and other API keys are returned as a JSON string.
+		return strconv.Quote(redacted)
+	case path == deviceRegisterPath:
+		return redactJSONField(body, "api_key")
+	}
+	return string(body)
+}
+
+// redactJSONField replaces the value of the named field of a JSON object. Bodies that are not JSON objects are
+// redacted entirely.
+func redactJSONField(body []byte, name string) string {
+	if len(body) == 0 {
+		return ""
+	}
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(body, &object); err != nil {
+		return redacted
+	}
+	if _, ok := object[name]; !ok {
+		return string(body)
+	}
+	object[name] = json.RawMessage(strconv.Quote(redacted))
+	b, err := json.Marshal(object)
+	if err != nil {
+		return redacted
+	}
+	return string(b)
+}
+
+// redactQuery replaces the values of `apikey` parameters in a raw query.
+func redactQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	parts := strings.Split(rawQuery, "&")
+	for i, part := range parts {
+		if strings.HasPrefix(part, "apikey=") {
+			parts[i] = "apikey=" + redacted
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// normalizeQuery returns a canonical form of a raw query, where options are unescaped, sorted, and stripped
+// of redundant whitespace outside of quoted literals. Credentials are left out.
+func normalizeQuery(rawQuery string) string {
+	var options []string
+	for _, part := range strings.Split(rawQuery, "&") {
+		if part == "" {
+			continue
+		}
+		key, value := part, ""
+		if i := strings.IndexByte(part, '='); i >= 0 {
+			key, value = part[:i], part[i+1:]
+		}
+		if k, err := url.QueryUnescape(key); err == nil {
+			key = k
+		}
+		if key == "apikey" {
+			continue
+		}
+		if v, err := url.QueryUnescape(value); err == nil {
+			value = v
+		}
+		options = append(options, key+"="+collapseWhitespace(value))
+	}
+	sort.Strings(options)
+	return strings.Join(options, "&")
+}
+
+// collapseWhitespace trims value and replaces runs of whitespace with a single space, except inside quoted OData
+// literals such as `'a  b'`. A quote escaped by doubling it ends and reopens the literal, leaving it intact.
+func collapseWhitespace(value string) string {
+	var b strings.Builder
+	inLiteral, pendingSpace := false, false
+	for _, r := range value {
+		if !inLiteral && unicode.IsSpace(r) {
+			pendingSpace = b.Len() > 0
+			continue
+		}
+		if pendingSpace {
+			b.WriteByte(' ')
+			pendingSpace = false
+		}
+		if r == '\'' {
+			inLiteral = !inLiteral
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,188 @@
+package balenatest
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.einride.tech/balena"
+	"gotest.tools/v3/assert"
+)
+
+func TestRecorder_RecordAndReplay(t *testing.T) {
+	// Given
+	fixture := filepath.Join(t.TempDir(), "testdata", "device.json")
+	server := NewServer(t, "secret-token")
+	applicationID := server.AddApplication("app", server.AddDeviceType("raspberrypi4-64", "Raspberry Pi 4"))
+	server.AddDevice(applicationID, "uuid1", "first")
+	ctx := context.Background()
+	t.Run("record", func(t *testing.T) {
+		recorder := NewRecorder(t, fixture, ModeRecord)
+		client := server.Client(balena.WithMiddleware(recorder.Middleware))
+		tags, err := client.DeviceTag.List(ctx, balena.DeviceUUID("uuid1"))
+		assert.NilError(t, err)
+		assert.Equal(t, len(tags), 0)
+		_, err = client.DeviceTag.Create(ctx, balena.DeviceUUID("uuid1"), "key", "value")
+		assert.NilError(t, err)
+		tags, err = client.DeviceTag.List(ctx, balena.DeviceUUID("uuid1"))
+		assert.NilError(t, err)
+		assert.Equal(t, len(tags), 1)
+	})
+	b, err := os.ReadFile(fixture)
+	assert.NilError(t, err)
+	assert.Assert(t, !strings.Contains(string(b), "secret-token"))
+	// When
+	recorder := NewRecorder(t, fixture, ModeReplay)
+	client, err := balena.NewClient(
+		"other-token",
+		balena.WithBaseURL("https://api.example.com/"),
+		balena.WithHTTPClient(&http.Client{Transport: recorder}),
+	)
+	assert.NilError(t, err)
+	before, err := client.DeviceTag.List(ctx, balena.DeviceUUID("uuid1"))
+	assert.NilError(t, err)
+	_, err = client.DeviceTag.Create(ctx, balena.DeviceUUID("uuid1"), "key", "value")
+	assert.NilError(t, err)
+	after, err := client.DeviceTag.List(ctx, balena.DeviceUUID("uuid1"))
+	assert.NilError(t, err)
+	_, err = client.DeviceTag.List(ctx, balena.DeviceUUID("uuid1"))
+	// Then
+	assert.Equal(t, len(before), 0)
+	assert.Equal(t, len(after), 1)
+	assert.ErrorContains(t, err, "no recorded interaction")
+}
+
+func TestRecorder_RedactsSupervisorAPIKey(t *testing.T) {
+	// Given
+	fixture := filepath.Join(t.TempDir(), "supervisor.json")
+	supervisor := NewSupervisor(t, 1234, "uuid1")
+	supervisor.SetService("main", balena.ServiceState{Status: ServiceRunning})
+	ctx := context.Background()
+	t.Run("record", func(t *testing.T) {
+		recorder := NewRecorder(t, fixture, ModeRecord)
+		client, err := balena.NewSupervisorV2FromConfig(
+			&http.Client{Transport: recorder.Middleware(http.DefaultTransport)},
+			supervisor.Config,
+		)
+		assert.NilError(t, err)
+		assert.NilError(t, client.RestartServiceByName(ctx, "main"))
+	})
+	b, err := os.ReadFile(fixture)
+	assert.NilError(t, err)
+	assert.Assert(t, !strings.Contains(string(b), supervisor.Config.APIKey))
+	// When
+	recorder := NewRecorder(t, fixture, ModeReplay)
+	config := supervisor.Config
+	config.APIKey = "other-key"
+	client, err := balena.NewSupervisorV2FromConfig(&http.Client{Transport: recorder}, config)
+	assert.NilError(t, err)
+	err = client.RestartServiceByName(ctx, "main")
+	// Then
+	assert.NilError(t, err)
+}
+
+func TestRecorder_RedactsTokensAndKeys(t *testing.T) {
+	// Given
+	fixture := filepath.Join(t.TempDir(), "auth.json")
+	loginToken := newJWT(t, map[string]interface{}{"twoFactorRequired": true})
+	verifiedToken := newJWT(t, map[string]interface{}{"exp": time.Now().Add(time.Minute).Unix()})
+	refreshedToken := newJWT(t, map[string]interface{}{"exp": time.Now().Add(24 * time.Hour).Unix()})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login_", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, loginToken)
+	})
+	mux.HandleFunc("/auth/totp/verify", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, verifiedToken)
+	})
+	mux.HandleFunc("/user/v1/refresh-token", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, refreshedToken)
+	})
+	authServer := httptest.NewServer(mux)
+	defer authServer.Close()
+	server := NewServer(t, "secret-token")
+	applicationID := server.AddApplication("app", server.AddDeviceType("raspberrypi4-64", "Raspberry Pi 4"))
+	ctx := context.Background()
+	var secrets []string
+	t.Run("record", func(t *testing.T) {
+		recorder := NewRecorder(t, fixture, ModeRecord)
+		authClient, err := balena.NewClient(
+			"",
+			balena.WithBaseURL(authServer.URL+"/"),
+			balena.WithMiddleware(recorder.Middleware),
+		)
+		assert.NilError(t, err)
+		source, err := authClient.Login(ctx, balena.Credentials{
+			Username: "user",
+			Password: "password",
+			TOTP: func(context.Context) (string, error) {
+				return "123456", nil
+			},
+		})
+		assert.NilError(t, err)
+		token, err := source.Token(ctx)
+		assert.NilError(t, err)
+		assert.Equal(t, token, refreshedToken)
+		client := server.Client(balena.WithMiddleware(recorder.Middleware))
+		key, err := client.Application.CreateProvisioningKey(
+			ctx,
+			balena.ApplicationID(applicationID),
+			balena.ProvisioningKeyOptions{},
+		)
+		assert.NilError(t, err)
+		registered, err := client.Device.Register(ctx, balena.DeviceRegistration{
+			ApplicationID:   applicationID,
+			DeviceType:      "raspberrypi4-64",
+			ProvisioningKey: key,
+		})
+		assert.NilError(t, err)
+		secrets = []string{loginToken, verifiedToken, refreshedToken, key, registered.APIKey}
+	})
+	// When
+	b, err := os.ReadFile(fixture)
+	// Then
+	assert.NilError(t, err)
+	for _, secret := range secrets {
+		assert.Assert(t, !strings.Contains(string(b), secret), "fixture contains %s", secret)
+	}
+	assert.Assert(t, strings.Contains(string(b), "/device/register"))
+}
+
+// newJWT returns an unsigned JWT with the given claims.
+func newJWT(t *testing.T, claims map[string]interface{}) string {
+	t.Helper()
+	payload, err := json.Marshal(claims)
+	assert.NilError(t, err)
+	return "eyJhbGciOiJIUzI1NiJ9." + base64.RawURLEncoding.EncodeToString(payload) + ".signature"
+}
+
+func TestNormalizeQuery(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+	}{
+		{a: "%24filter=app_name%20eq%20%27x%27", b: "$filter=app_name+eq+%27x%27"},
+		{a: "%24select=id&%24filter=id+eq+1", b: "$filter=id  eq 1&$select=id"},
+		{a: "apikey=secret", b: "apikey=REDACTED"},
+		{a: "%24filter=name+eq+%27a++b%27+", b: "$filter= name  eq 'a  b'"},
+	} {
+		assert.Equal(t, normalizeQuery(tt.a), normalizeQuery(tt.b))
+	}
+}
+
+func TestNormalizeQuery_KeepsWhitespaceInLiterals(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+	}{
+		{a: "%24filter=name+eq+%27a++b%27", b: "%24filter=name+eq+%27a+b%27"},
+		{a: "%24filter=name+eq+%27it%27%27s++x%27", b: "%24filter=name+eq+%27it%27%27s+x%27"},
+	} {
+		assert.Assert(t, normalizeQuery(tt.a) != normalizeQuery(tt.b), "%s and %s", tt.a, tt.b)
+	}
+}
@@ -0,0 +1,226 @@
+package balenatest
+
+// field describes a field of a resource.
+type field struct {
+	// ref is the name of the referenced resource if the field is a navigation property.
+	ref string
+	// owner is set if the field references the owner of the entity, which is deleted along with its owner.
+	// Other references are cleared when the referenced entity is deleted.
+	owner bool
+	// def is the default value of the field.
+	def interface{}
+}
+
+// resource describes a resource served by the fake API.
+type resource struct {
+	fields map[string]field
+	// unique lists sets of fields that must be unique across all entities of the resource.
+	unique [][]string
+}
+
+func ref(resource string) field {
+	return field{ref: resource}
+}
+
+func owner(resource string) field {
+	return field{ref: resource, owner: true}
+}
+
+func value(def interface{}) field {
+	return field{def: def}
+}
+
+// schema describes the resources served by the fake API, keyed by name.
+//
+//nolint:gochecknoglobals
+var schema = map[string]resource{
+	"application": {
+		fields: map[string]field{
+			"app_name":                             value(""),
+			"slug":                                 value(""),
+			"uuid":                                 value(""),
+			"actor":                                value(0),
+			"is_of__class":                         value("fleet"),
+			"is_public":                            value(false),
+			"is_host":                              value(false),
+			"is_archived":                          value(false),
+			"is_discoverable":                      value(false),
+			"is_stored_at__repository_url":         value(""),
+			"should_track_latest_release":          value(true),
+			"organization":                         ref("organization"),
+			"is_for__device_type":                  ref("device_type"),
+			"should_be_running__release":           ref("release"),
+			"depends_on__application":              ref("application"),
+			"is_accessible_by_support_until__date": value(nil),
+		},
+		unique: [][]string{{"app_name"}, {"slug"}, {"uuid"}},
+	},
+	"application_environment_variable": {
+		fields: map[string]field{
+			"application": owner("application"),
+			"name":        value(""),
+			"value":       value(""),
+		},
+		unique: [][]string{{"application", "name"}},
+	},
+	"application_config_variable": {
+		fields: map[string]field{
+			"application": owner("application"),
+			"name":        value(""),
+			"value":       value(""),
+		},
+		unique: [][]string{{"application", "name"}},
+	},
+	"organization": {
+		fields: map[string]field{
+			"name":   value(""),
+			"handle": value(""),
+		},
+		unique: [][]string{{"handle"}},
+	},
+	"device": {
+		fields: map[string]field{
+			"uuid":                                 value(""),
+			"device_name":                          value(""),
+			"actor":                                value(0),
+			"note":                                 value(nil),
+			"local_id":                             value(nil),
+			"status":                               value("idle"),
+			"overall_status":                       value("idle"),
+			"api_heartbeat_state":                  value("unknown"),
+			"is_online":                            value(false),
+			"is_connected_to_vpn":                  value(false),
+			"is_web_accessible":                    value(false),
+			"is_active":                            value(true),
+			"is_undervolted":                       value(false),
+			"last_connectivity_event":              value(nil),
+			"last_vpn_event":                       value(nil),
+			"ip_address":                           value(nil),
+			"mac_address":                          value(nil),
+			"vpn_address":                          value(nil),
+			"public_address":                       value(""),
+			"os_version":                           value(nil),
+			"os_variant":                           value(nil),
+			"supervisor_version":                   value(nil),
+			"provisioning_state":                   value(""),
+			"provisioning_progress":                value(nil),
+			"download_progress":                    value(nil),
+			"longitude":                            value(""),
+			"latitude":                             value(""),
+			"location":                             value(""),
+			"custom_longitude":                     value(""),
+			"custom_latitude":                      value(""),
+			"logs_channel":                         value(nil),
+			"is_locked_until__date":                value(nil),
+			"is_accessible_by_support_until__date": value(nil),
+			"memory_usage":                         value(nil),
+			"memory_total":                         value(nil),
+			"storage_block_device":                 value(nil),
+			"storage_usage":                        value(nil),
+			"storage_total":                        value(nil),
+			"cpu_temp":                             value(nil),
+			"cpu_usage":                            value(nil),
+			"cpu_id":                               value(nil),
+			"belongs_to__application":              owner("application"),
+			"belongs_to__user":                     ref("user"),
+			"device_type":                          ref("device_type"),
+			"is_running__release":                  ref("release"),
+			"should_be_running__release":           ref("release"),
+			"is_managed_by__device":                ref("device"),
+			"is_managed_by__service_instance":      ref("service_instance"),
+			"should_be_managed_by__supervisor_release": ref("release"),
+		},
+		unique: [][]string{{"uuid"}},
+	},
+	"device_type": {
+		fields: map[string]field{
+			"slug":                    value(""),
+			"name":                    value(""),
+			"is_private":              value(false),
+			"logo":                    value(""),
+			"is_of__cpu_architecture": ref("cpu_architecture"),
+		},
+		unique: [][]string{{"slug"}},
+	},
+	"release": {
+		fields: map[string]field{
+			"commit":                  value(""),
+			"status":                  value("success"),
+			"source":                  value("cloud"),
+			"release_type":            value("final"),
+			"contract":                value(nil),
+			"composition":             value(nil),
+			"build_log":               value(""),
+			"is_invalidated":          value(false),
+			"is_passing_tests":        value(true),
+			"release_version":         value(nil),
+			"start_timestamp":         value(nil),
+			"end_timestamp":           value(nil),
+			"update_timestamp":        value(nil),
+			"belongs_to__application": owner("application"),
+			"is_created_by__user":     ref("user"),
+		},
+	},
+	"release_tag": {
+		fields: map[string]field{
+			"release": owner("release"),
+			"tag_key": value(""),
+			"value":   value(""),
+		},
+		unique: [][]string{{"release", "tag_key"}},
+	},
+	"device_tag": {
+		fields: map[string]field{
+			"device":  owner("device"),
+			"tag_key": value(""),
+			"value":   value(""),
+		},
+		unique: [][]string{{"device", "tag_key"}},
+	},
+	"device_environment_variable": {
+		fields: map[string]field{
+			"device": owner("device"),
+			"name":   value(""),
+			"value":  value(""),
+		},
+		unique: [][]string{{"device", "name"}},
+	},
+	"device_config_variable": {
+		fields: map[string]field{
+			"device": owner("device"),
+			"name":   value(""),
+			"value":  value(""),
+		},
+		unique: [][]string{{"device", "name"}},
+	},
+	"service": {
+		fields: map[string]field{
+			"application":  owner("application"),
+			"service_name": value(""),
+		},
+		unique: [][]string{{"application", "service_name"}},
+	},
+	"service_environment_variable": {
+		fields: map[string]field{
+			"service": owner("service"),
+			"name":    value(""),
+			"value":   value(""),
+		},
+		unique: [][]string{{"service", "name"}},
+	},
+	"service_install": {
+		fields: map[string]field{
+			"device":            owner("device"),
+			"installs__service": owner("service"),
+		},
+		unique: [][]string{{"device", "installs__service"}},
+	},
+	"device_service_environment_variable": {
+		fields: map[string]field{
+			"service_install": owner("service_install"),
+			"name":            value(""),
+			"value":           value(""),
+		},
+		unique: [][]string{{"service_install", "name"}},
+	},
+}
@@ -0,0 +1,678 @@
+// Package balenatest provides an in-memory fake of the balena cloud API for testing code using the balena
+// client.
+package balenatest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.einride.tech/balena"
+)
+
+// timeFormat is the format of timestamps returned by the API.
+const timeFormat = "2006-01-02T15:04:05.000Z"
+
+// entityPathPattern matches request paths such as `/v6/device` or `/v6/device(123)`.
+var entityPathPattern = regexp.MustCompile(`^/v[0-9]+/([a-z_]+)(?:\(([0-9]+)\))?$`)
+
+// Server is a stateful in-memory fake of the balena cloud API, serving the resources used by the client.
+//
+// It understands the OData queries emitted by the client: $filter expressions with comparisons, `and`,
+// `or`, `not` and navigation paths such as `device/uuid`, entity paths such as `device(123)`, as well as
+// $select, $expand, $orderby, $top and $skip. Creating entities that violate a unique constraint results in a
+// 409 Conflict, as with the real API. Deleting an entity also deletes the entities it owns, such as the
+// environment variables of a device. Actions on devices, such as reboots and purges, are recorded, see
+// DeviceActions, and devices can be registered with provisioning keys, see AddProvisioningKey.
+//
+// Entities are represented as maps from field names to values, where references to other entities hold the
+// referenced ID as an int64.
+type Server struct {
+	// URL is the base URL of the server, with a trailing slash.
+	URL string
+
+	t      testing.TB
+	server *httptest.Server
+	token  string
+
+	mu       sync.Mutex
+	nextID   int64
+	entities map[string]map[int64]map[string]interface{}
+	now      func() time.Time
+	// updateLocks and actions hold the state of device actions, see DeviceActions.
+	updateLocks map[string]bool
+	actions     []DeviceAction
+	// provisioningKeys maps provisioning keys to the IDs of their applications.
+	provisioningKeys map[string]int64
+}
+
+// NewServer starts a new fake API server, which is closed when the test finishes. If token is non-empty,
+// requests must be authenticated with it.
+func NewServer(t testing.TB, token string) *Server {
+	t.Helper()
+	s := &Server{
+		t:                t,
+		token:            token,
+		nextID:           1,
+		entities:         map[string]map[int64]map[string]interface{}{},
+		now:              time.Now,
+		updateLocks:      map[string]bool{},
+		provisioningKeys: map[string]int64{},
+	}
+	for name := range schema {
+		s.entities[name] = map[int64]map[string]interface{}{}
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.server.URL + "/"
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+// Client returns a client for the server, authenticated with the token of the server.
+// The given options are applied after the ones connecting the client to the server.
+func (s *Server) Client(opts ...balena.Option) *balena.Client {
+	s.t.Helper()
+	token := s.token
+	if token == "" {
+		token = "test-token"
+	}
+	c, err := balena.NewClient(token, append([]balena.Option{
+		balena.WithBaseURL(s.URL),
+		balena.WithHTTPClient(s.server.Client()),
+	}, opts...)...)
+	if err != nil {
+		s.t.Fatalf("balenatest: unable to create client: %v", err)
+	}
+	return c
+}
+
+// AddDeviceType adds a device type and returns its ID.
+func (s *Server) AddDeviceType(slug, name string) int64 {
+	s.t.Helper()
+	return s.mustCreate("device_type", map[string]interface{}{"slug": slug, "name": name})
+}
+
+// AddApplication adds an application for the given device type and returns its ID.
+// The slug of the application is its lowercase name prefixed by `test/`.
+func (s *Server) AddApplication(name string, deviceTypeID int64) int64 {
+	s.t.Helper()
+	return s.mustCreate("application", map[string]interface{}{
+		"app_name":            name,
+		"slug":                "test/" + strings.ToLower(name),
+		"uuid":                newUUID(),
+		"is_for__device_type": deviceTypeID,
+	})
+}
+
+// AddRelease adds a successful release of an application and returns its ID.
+func (s *Server) AddRelease(applicationID int64, commit string) int64 {
+	s.t.Helper()
+	return s.mustCreate("release", map[string]interface{}{
+		"commit":                  commit,
+		"belongs_to__application": applicationID,
+	})
+}
+
+// AddService adds a service to an application and returns its ID. The service is installed on all devices of
+// the application.
+func (s *Server) AddService(applicationID int64, name string) int64 {
+	s.t.Helper()
+	id := s.mustCreate("service", map[string]interface{}{"application": applicationID, "service_name": name})
+	for _, device := range s.List("device") {
+		if device["belongs_to__application"] == applicationID {
+			s.mustCreate("service_install", map[string]interface{}{
+				"device":            device["id"],
+				"installs__service": id,
+			})
+		}
+	}
+	return id
+}
+
+// AddDevice adds a device to an application and returns its ID. A UUID is generated if uuid is empty.
+// The services of the application are installed on the device.
+func (s *Server) AddDevice(applicationID int64, uuid, name string) int64 {
+	s.t.Helper()
+	if uuid == "" {
+		uuid = newUUID()
+	}
+	application, ok := s.Get("application", applicationID)
+	if !ok {
+		s.t.Fatalf("balenatest: no application with ID %d", applicationID)
+	}
+	id := s.mustCreate("device", map[string]interface{}{
+		"uuid":                    uuid,
+		"device_name":             name,
+		"belongs_to__application": applicationID,
+		"device_type":             application["is_for__device_type"],
+	})
+	for _, service := range s.List("service") {
+		if service["application"] == applicationID {
+			s.mustCreate("service_install", map[string]interface{}{"device": id, "installs__service": service["id"]})
+		}
+	}
+	return id
+}
+
+// ServiceInstallID returns the ID of the service install of the named service on a device.
+func (s *Server) ServiceInstallID(deviceID int64, serviceName string) int64 {
+	s.t.Helper()
+	for _, install := range s.List("service_install") {
+		if install["device"] != deviceID {
+			continue
+		}
+		if service, ok := s.Get("service", install["installs__service"].(int64)); ok &&
+			service["service_name"] == serviceName {
+			return install["id"].(int64)
+		}
+	}
+	s.t.Fatalf("balenatest: service %q is not installed on device %d", serviceName, deviceID)
+	return 0
+}
+
+// Create creates an entity of a resource, such as `device_tag`, and returns its ID.
+// Fields not given are set to their defaults.
+func (s *Server) Create(resource string, fields map[string]interface{}) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, err := s.create(resource, fields)
+	if err != nil {
+		return 0, err
+	}
+	return e["id"].(int64), nil
+}
+
+// Get returns a copy of the entity of a resource with the given ID.
+func (s *Server) Get(resource string, id int64) (map[string]interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entities[resource][id]
+	if !ok {
+		return nil, false
+	}
+	return copyEntity(e), true
+}
+
+// List returns copies of all entities of a resource, ordered by ID.
+func (s *Server) List(resource string) []map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sorted := s.sorted(resource)
+	result := make([]map[string]interface{}, 0, len(sorted))
+	for _, e := range sorted {
+		result = append(result, copyEntity(e))
+	}
+	return result
+}
+
+// Update sets fields of the entity of a resource with the given ID, e.g. to change the status of a device.
+func (s *Server) Update(resource string, id int64, fields map[string]interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entities[resource][id]
+	if !ok {
+		return fmt.Errorf("no %s with ID %d", resource, id)
+	}
+	return s.update(resource, []map[string]interface{}{e}, fields)
+}
+
+// Delete deletes the entity of a resource with the given ID, along with the entities it owns.
+func (s *Server) Delete(resource string, id int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entities[resource][id]; ok {
+		s.delete(resource, []map[string]interface{}{e})
+	}
+}
+
+func (s *Server) mustCreate(resource string, fields map[string]interface{}) int64 {
+	s.t.Helper()
+	id, err := s.Create(resource, fields)
+	if err != nil {
+		s.t.Fatalf("balenatest: unable to create %s: %v", resource, err)
+	}
+	return id
+}
+
+// httpError is an error with the status code to respond with.
+type httpError struct {
+	status  int
+	message string
+}
+
+func (e *httpError) Error() string {
+	return e.message
+}
+
+func errorf(status int, format string, v ...interface{}) error {
+	return &httpError{status: status, message: fmt.Sprintf(format, v...)}
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	// Devices are registered with a provisioning key instead of the token of the server.
+	if r.URL.Path == "/device/register" {
+		s.serveRegisterDevice(w, r)
+		return
+	}
+	if s.token != "" && r.Header.Get("Authorization") != "Bearer "+s.token {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if m := provisioningKeyPathPattern.FindStringSubmatch(r.URL.Path); m != nil {
+		s.serveCreateProvisioningKey(w, r, m[1])
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, supervisorProxyPrefix) {
+		s.serveSupervisorProxy(w, r)
+		return
+	}
+	if m := deviceV2ActionPathPattern.FindStringSubmatch(r.URL.Path); m != nil {
+		s.serveDeviceV2Action(w, r, m[1], m[2])
+		return
+	}
+	m := entityPathPattern.FindStringSubmatch(r.URL.Path)
+	if m == nil {
+		http.NotFound(w, r)
+		return
+	}
+	resource := m[1]
+	if _, ok := schema[resource]; !ok {
+		http.NotFound(w, r)
+		return
+	}
+	var id int64
+	if m[2] != "" {
+		var err error
+		if id, err = strconv.ParseInt(m[2], 10, 64); err != nil {
+			http.Error(w, "invalid ID", http.StatusBadRequest)
+			return
+		}
+	}
+	q, err := parseQuery(resource, r.URL.RawQuery)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var status int
+	var body interface{}
+	switch r.Method {
+	case http.MethodGet:
+		status, body = http.StatusOK, map[string]interface{}{"d": s.read(resource, id, q)}
+	case http.MethodPost:
+		if id != 0 {
+			err = errorf(http.StatusMethodNotAllowed, "cannot POST to an entity")
+			break
+		}
+		var fields map[string]interface{}
+		if fields, err = decodeFields(r); err != nil {
+			break
+		}
+		var e map[string]interface{}
+		if e, err = s.create(resource, fields); err == nil {
+			status, body = http.StatusCreated, s.render(resource, e, &query{})
+		}
+	case http.MethodPatch:
+		var fields map[string]interface{}
+		if fields, err = decodeFields(r); err != nil {
+			break
+		}
+		if err = s.update(resource, s.match(resource, id, q.filter), fields); err == nil {
+			status = http.StatusOK
+		}
+	case http.MethodDelete:
+		s.delete(resource, s.match(resource, id, q.filter))
+		status = http.StatusOK
+	default:
+		err = errorf(http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+	}
+	if err != nil {
+		status := http.StatusBadRequest
+		if httpErr, ok := err.(*httpError); ok {
+			status = httpErr.status
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if body == nil {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("OK"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// decodeFields decodes the JSON object in the body of a request.
+func decodeFields(r *http.Request) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, errorf(http.StatusBadRequest, "invalid request body: %v", err)
+	}
+	return fields, nil
+}
+
+// read returns the rendered entities of a resource matching an ID, if non-zero, and a query.
+func (s *Server) read(resource string, id int64, q *query) []interface{} {
+	matches := s.match(resource, id, q.filter)
+	if len(q.orderBy) > 0 {
+		sort.SliceStable(matches, func(i, j int) bool {
+			for _, term := range q.orderBy {
+				a, b := s.resolve(resource, matches[i], term.path), s.resolve(resource, matches[j], term.path)
+				if compareValues("eq", a, b) {
+					continue
+				}
+				return compareValues("lt", a, b) != term.desc
+			}
+			return false
+		})
+	}
+	if q.skip >= len(matches) {
+		matches = nil
+	} else {
+		matches = matches[q.skip:]
+	}
+	if q.top >= 0 && q.top < len(matches) {
+		matches = matches[:q.top]
+	}
+	result := make([]interface{}, 0, len(matches))
+	for _, e := range matches {
+		result = append(result, s.render(resource, e, q))
+	}
+	return result
+}
+
+// match returns the entities of a resource, ordered by ID, matching an ID, if non-zero, and a filter.
+func (s *Server) match(resource string, id int64, filter expr) []map[string]interface{} {
+	var matches []map[string]interface{}
+	for _, e := range s.sorted(resource) {
+		if id != 0 && e["id"] != id {
+			continue
+		}
+		if filter != nil && !filter.match(func(path []string) interface{} {
+			return s.resolve(resource, e, path)
+		}) {
+			continue
+		}
+		matches = append(matches, e)
+	}
+	return matches
+}
+
+// resolve returns the value at the end of a navigation path starting at an entity of a resource.
+func (s *Server) resolve(resource string, e map[string]interface{}, path []string) interface{} {
+	for _, name := range path[:len(path)-1] {
+		id, ok := e[name].(int64)
+		if !ok {
+			return nil
+		}
+		resource = schema[resource].fields[name].ref
+		if e, ok = s.entities[resource][id]; !ok {
+			return nil
+		}
+	}
+	return e[path[len(path)-1]]
+}
+
+// render returns the JSON representation of an entity of a resource, with the fields selected and expanded
+// by a query.
+func (s *Server) render(resource string, e map[string]interface{}, q *query) map[string]interface{} {
+	names := q.selects
+	if len(names) == 0 {
+		names = []string{"id", "created_at", "modified_at"}
+		for name := range schema[resource].fields {
+			names = append(names, name)
+		}
+	}
+	result := map[string]interface{}{
+		"__metadata": map[string]interface{}{"uri": deferredURI(resource, e["id"].(int64))},
+	}
+	for _, name := range names {
+		f := schema[resource].fields[name]
+		if _, ok := q.expand[name]; ok {
+			continue
+		}
+		if id, ok := e[name].(int64); ok && f.ref != "" {
+			result[name] = map[string]interface{}{
+				"__id":       id,
+				"__deferred": map[string]interface{}{"uri": deferredURI(f.ref, id)},
+			}
+		} else {
+			result[name] = e[name]
+		}
+	}
+	for name, expanded := range q.expand {
+		f := schema[resource].fields[name]
+		items := []interface{}{}
+		if id, ok := e[name].(int64); ok {
+			if target, ok := s.entities[f.ref][id]; ok {
+				items = append(items, s.render(f.ref, target, expanded))
+			}
+		}
+		result[name] = items
+	}
+	return result
+}
+
+func deferredURI(resource string, id int64) string {
+	return "/resin/" + resource + "(@id)?@id=" + strconv.FormatInt(id, 10)
+}
+
+// create creates an entity of a resource from the given fields.
+func (s *Server) create(resource string, fields map[string]interface{}) (map[string]interface{}, error) {
+	r, ok := schema[resource]
+	if !ok {
+		return nil, errorf(http.StatusNotFound, "unknown resource %s", resource)
+	}
+	now := s.now().UTC().Format(timeFormat)
+	e := map[string]interface{}{"created_at": now, "modified_at": now}
+	for name, f := range r.fields {
+		e[name] = f.def
+	}
+	if err := s.setFields(resource, e, fields); err != nil {
+		return nil, err
+	}
+	for name, f := range r.fields {
+		if f.owner && e[name] == nil {
+			return nil, errorf(http.StatusBadRequest, "%s of %s must not be null", name, resource)
+		}
+	}
+	e["id"] = s.nextID
+	if err := s.checkUnique(resource, e); err != nil {
+		return nil, err
+	}
+	s.nextID++
+	s.entities[resource][e["id"].(int64)] = e
+	return e, nil
+}
+
+// update sets fields of entities of a resource. No entity is updated if the fields are invalid for any of them.
+func (s *Server) update(resource string, entities []map[string]interface{}, fields map[string]interface{}) error {
+	updated := make([]map[string]interface{}, 0, len(entities))
+	for _, e := range entities {
+		e = copyEntity(e)
+		if err := s.setFields(resource, e, fields); err != nil {
+			return err
+		}
+		e["modified_at"] = s.now().UTC().Format(timeFormat)
+		if err := s.checkUnique(resource, e); err != nil {
+			return err
+		}
+		updated = append(updated, e)
+	}
+	for _, e := range updated {
+		s.entities[resource][e["id"].(int64)] = e
+	}
+	return nil
+}
+
+// delete deletes entities of a resource, along with the entities they own.
+func (s *Server) delete(resource string, entities []map[string]interface{}) {
+	for _, e := range entities {
+		id := e["id"].(int64)
+		if _, ok := s.entities[resource][id]; !ok {
+			continue
+		}
+		delete(s.entities[resource], id)
+		for name, r := range schema {
+			for fieldName, f := range r.fields {
+				if f.ref != resource {
+					continue
+				}
+				var owned []map[string]interface{}
+				for _, other := range s.sorted(name) {
+					if other[fieldName] != id {
+						continue
+					}
+					if f.owner {
+						owned = append(owned, other)
+					} else {
+						other[fieldName] = nil
+					}
+				}
+				s.delete(name, owned)
+			}
+		}
+	}
+}
+
+// setFields sets fields of an entity of a resource, converting JSON values to the types used for storage.
+func (s *Server) setFields(resource string, e, fields map[string]interface{}) error {
+	for name, value := range fields {
+		f, ok := schema[resource].fields[name]
+		if !ok {
+			return errorf(http.StatusBadRequest, "unknown field %q of %s", name, resource)
+		}
+		value, err := normalize(value)
+		if err != nil {
+			return errorf(http.StatusBadRequest, "invalid value of %s: %v", name, err)
+		}
+		if f.ref != "" && value != nil {
+			var id int64
+			switch v := value.(type) {
+			case int64:
+				id = v
+			case string:
+				if id, err = strconv.ParseInt(v, 10, 64); err != nil {
+					return errorf(http.StatusBadRequest, "invalid reference %q in %s", v, name)
+				}
+			case map[string]interface{}:
+				if id, ok = v["__id"].(int64); !ok {
+					return errorf(http.StatusBadRequest, "invalid reference in %s", name)
+				}
+			default:
+				return errorf(http.StatusBadRequest, "invalid reference in %s", name)
+			}
+			if _, ok := s.entities[f.ref][id]; !ok {
+				return errorf(http.StatusBadRequest, "Foreign key constraint violated: no %s with ID %d", f.ref, id)
+			}
+			value = id
+		}
+		e[name] = value
+	}
+	return nil
+}
+
+// normalize converts numbers, which may be json.Number or any Go number type, to int64 or float64.
+func normalize(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n, nil
+		}
+		return v.Float64()
+	case int:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case float32:
+		return float64(v), nil
+	case []interface{}:
+		result := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			item, err := normalize(item)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, item)
+		}
+		return result, nil
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			item, err := normalize(item)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = item
+		}
+		return result, nil
+	}
+	return value, nil
+}
+
+// checkUnique returns a 409 Conflict error if an entity violates a unique constraint of its resource.
+func (s *Server) checkUnique(resource string, e map[string]interface{}) error {
+	for _, fields := range schema[resource].unique {
+		for _, other := range s.entities[resource] {
+			if other["id"] == e["id"] {
+				continue
+			}
+			same := true
+			for _, name := range fields {
+				if e[name] == nil || e[name] == "" || e[name] != other[name] {
+					same = false
+					break
+				}
+			}
+			if same {
+				return errorf(http.StatusConflict, "Unique key constraint violated")
+			}
+		}
+	}
+	return nil
+}
+
+// sorted returns the entities of a resource ordered by ID.
+func (s *Server) sorted(resource string) []map[string]interface{} {
+	result := make([]map[string]interface{}, 0, len(s.entities[resource]))
+	for _, e := range s.entities[resource] {
+		result = append(result, e)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i]["id"].(int64) < result[j]["id"].(int64)
+	})
+	return result
+}
+
+func copyEntity(e map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(e))
+	for name, value := range e {
+		result[name] = value
+	}
+	return result
+}
+
+// newUUID returns a random device UUID in the format used by balena.
+func newUUID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
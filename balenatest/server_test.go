@@ -0,0 +1,419 @@
+package balenatest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"go.einride.tech/balena"
+	"go.einride.tech/balena/odata"
+	"gotest.tools/v3/assert"
+)
+
+func TestServer_Application(t *testing.T) {
+	// Given
+	server := NewServer(t, "")
+	deviceTypeID := server.AddDeviceType("raspberrypi4-64", "Raspberry Pi 4")
+	applicationID := server.AddApplication("it's-an-app", deviceTypeID)
+	server.AddApplication("other", deviceTypeID)
+	client := server.Client()
+	ctx := context.Background()
+	// When
+	byName, err := client.Application.GetByName(ctx, "it's-an-app")
+	// Then
+	assert.NilError(t, err)
+	assert.Equal(t, byName.ID, applicationID)
+	assert.Equal(t, byName.Slug, "test/it's-an-app")
+	assert.Equal(t, byName.IsForDeviceType.ID, deviceTypeID)
+	// When
+	byID, err := client.Application.Get(ctx, applicationID)
+	// Then
+	assert.NilError(t, err)
+	assert.Equal(t, byID.AppName, "it's-an-app")
+	// When
+	missing, err := client.Application.Get(ctx, 9999)
+	// Then
+	assert.NilError(t, err)
+	assert.Assert(t, missing == nil)
+	// When
+	_, err = client.Application.EnableTrackLatestRelease(ctx, applicationID)
+	assert.NilError(t, err)
+	_, err = client.Application.DisableTrackLatestRelease(ctx, applicationID)
+	// Then
+	assert.NilError(t, err)
+	application, _ := server.Get("application", applicationID)
+	assert.Equal(t, application["should_track_latest_release"], false)
+}
+
+func TestServer_Device(t *testing.T) {
+	// Given
+	server := NewServer(t, "")
+	deviceTypeID := server.AddDeviceType("raspberrypi4-64", "Raspberry Pi 4")
+	applicationID := server.AddApplication("app", deviceTypeID)
+	otherApplicationID := server.AddApplication("other", deviceTypeID)
+	releaseID := server.AddRelease(applicationID, "abc123")
+	deviceID := server.AddDevice(applicationID, "uuid1", "first")
+	server.AddDevice(applicationID, "uuid2", "second")
+	server.AddDevice(otherApplicationID, "uuid3", "third")
+	client := server.Client(balena.WithPageSize(2))
+	ctx := context.Background()
+	// When
+	devices, err := client.Device.ListByApplication(ctx, applicationID)
+	// Then
+	assert.NilError(t, err)
+	assert.Equal(t, len(devices), 2)
+	// When
+	device, err := client.Device.Get(ctx, balena.DeviceUUID("uuid1"))
+	// Then
+	assert.NilError(t, err)
+	assert.Equal(t, device.ID, deviceID)
+	assert.Equal(t, device.DeviceType.ID, deviceTypeID)
+	// When
+	query, err := odata.NewQuery().
+		Filter(odata.Or(odata.Eq("device_name", "second"), odata.Eq("device_name", "third"))).
+		Select("id", "uuid").
+		OrderBy("uuid", odata.Desc).
+		Encode()
+	assert.NilError(t, err)
+	devices, err = client.Device.GetWithQuery(ctx, query)
+	// Then
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{devices[0].UUID, devices[1].UUID}, []string{"uuid3", "uuid2"})
+	assert.Equal(t, devices[0].DeviceName, "")
+	// When
+	var uuids []string
+	err = client.Device.ForEach(ctx, "", func(d *balena.DeviceResponse) error {
+		uuids = append(uuids, d.UUID)
+		return nil
+	})
+	// Then
+	assert.NilError(t, err)
+	assert.DeepEqual(t, uuids, []string{"uuid1", "uuid2", "uuid3"})
+	// When
+	_, err = client.Device.PinRelease(ctx, balena.DeviceUUID("uuid1"), releaseID)
+	assert.NilError(t, err)
+	_, err = client.Device.MoveToApplication(ctx, balena.DeviceID(deviceID), otherApplicationID)
+	// Then
+	assert.NilError(t, err)
+	device, err = client.Device.Get(ctx, balena.DeviceID(deviceID))
+	assert.NilError(t, err)
+	assert.Equal(t, device.ShouldBeRunningRelease.ID, releaseID)
+	assert.Equal(t, device.BelongsToApplication.ID, otherApplicationID)
+	// When
+	_, err = client.Device.MoveToApplication(ctx, balena.DeviceID(deviceID), 9999)
+	// Then
+	assert.ErrorContains(t, err, "Foreign key constraint violated")
+}
+
+func TestServer_DeviceEnvVar(t *testing.T) {
+	// Given
+	server := NewServer(t, "")
+	applicationID := server.AddApplication("app", server.AddDeviceType("raspberrypi4-64", "Raspberry Pi 4"))
+	deviceID := server.AddDevice(applicationID, "uuid1", "first")
+	otherDeviceID := server.AddDevice(applicationID, "uuid2", "second")
+	client := server.Client()
+	ctx := context.Background()
+	// When
+	created, err := client.DeviceEnvVar.Create(ctx, balena.DeviceUUID("uuid1"), "KEY", "a&b 'c'")
+	assert.NilError(t, err)
+	_, err = client.DeviceEnvVar.Create(ctx, balena.DeviceID(otherDeviceID), "KEY", "other")
+	assert.NilError(t, err)
+	// Then
+	assert.Equal(t, created.Device.ID, deviceID)
+	// When
+	_, err = client.DeviceEnvVar.Create(ctx, balena.DeviceID(deviceID), "KEY", "duplicate")
+	// Then
+	assert.Assert(t, errors.Is(err, balena.ErrConflict))
+	// When
+	err = client.DeviceEnvVar.Update(ctx, balena.DeviceUUID("uuid1"), "KEY", "new value")
+	assert.NilError(t, err)
+	envVars, err := client.DeviceEnvVar.List(ctx, balena.DeviceID(deviceID))
+	// Then
+	assert.NilError(t, err)
+	assert.Equal(t, len(envVars), 1)
+	assert.Equal(t, envVars[0].Value, "new value")
+	// When
+	err = client.DeviceEnvVar.DeleteWithName(ctx, balena.DeviceUUID("uuid1"), "KEY")
+	assert.NilError(t, err)
+	envVars, err = client.DeviceEnvVar.List(ctx, balena.DeviceUUID("uuid1"))
+	// Then
+	assert.NilError(t, err)
+	assert.Equal(t, len(envVars), 0)
+	assert.Equal(t, len(server.List("device_environment_variable")), 1)
+}
+
+func TestServer_DeviceServVar(t *testing.T) {
+	// Given
+	server := NewServer(t, "")
+	applicationID := server.AddApplication("app", server.AddDeviceType("raspberrypi4-64", "Raspberry Pi 4"))
+	server.AddService(applicationID, "main")
+	deviceID := server.AddDevice(applicationID, "uuid1", "first")
+	serviceID := server.AddService(applicationID, "sidecar")
+	client := server.Client()
+	ctx := context.Background()
+	// When
+	installs, err := client.ServiceInstall.List(ctx, balena.DeviceUUID("uuid1"))
+	// Then
+	assert.NilError(t, err)
+	assert.DeepEqual(t, installs.ServiceNames(), []string{"main", "sidecar"})
+	// When
+	installsService, ok := installs.FindByServiceName("sidecar")
+	assert.Assert(t, ok)
+	assert.Equal(t, installsService.ID, serviceID)
+	_, err = client.DeviceServVar.Create(ctx, server.ServiceInstallID(deviceID, "sidecar"), "KEY", "value")
+	assert.NilError(t, err)
+	servVars, err := client.DeviceServVar.List(ctx, balena.DeviceUUID("uuid1"))
+	// Then
+	assert.NilError(t, err)
+	assert.Equal(t, len(servVars), 1)
+	assert.DeepEqual(t, servVars[0].ServiceInstall.ServiceNames(), []string{"sidecar"})
+	// When deleting the device, its service installs and variables are deleted along with it.
+	server.Delete("device", deviceID)
+	// Then
+	assert.Equal(t, len(server.List("service_install")), 0)
+	assert.Equal(t, len(server.List("device_service_environment_variable")), 0)
+	assert.Equal(t, len(server.List("service")), 2)
+}
+
+func TestServer_Tags(t *testing.T) {
+	// Given
+	server := NewServer(t, "")
+	applicationID := server.AddApplication("app", server.AddDeviceType("raspberrypi4-64", "Raspberry Pi 4"))
+	deviceID := server.AddDevice(applicationID, "uuid1", "first")
+	releaseID := server.AddRelease(applicationID, "abc123")
+	_, err := server.Create("release_tag", map[string]interface{}{
+		"release": releaseID,
+		"tag_key": "version",
+		"value":   "1.0.0",
+	})
+	assert.NilError(t, err)
+	client := server.Client()
+	ctx := context.Background()
+	// When
+	releaseTags, err := client.ReleaseTag.ListByCommit(ctx, "abc123")
+	// Then
+	assert.NilError(t, err)
+	assert.Equal(t, len(releaseTags), 1)
+	assert.Equal(t, releaseTags[0].Value, "1.0.0")
+	// When
+	_, err = client.DeviceTag.Create(ctx, balena.DeviceID(deviceID), "key", "value")
+	assert.NilError(t, err)
+	err = client.DeviceTag.UpdateWithKey(ctx, balena.DeviceUUID("uuid1"), "key", "new value")
+	assert.NilError(t, err)
+	tag, err := client.DeviceTag.GetWithKey(ctx, balena.DeviceID(deviceID), "key")
+	// Then
+	assert.NilError(t, err)
+	assert.Equal(t, tag.Value, "new value")
+}
+
+func TestServer_Unauthorized(t *testing.T) {
+	// Given
+	server := NewServer(t, "secret")
+	client, err := balena.NewClient("wrong", balena.WithBaseURL(server.URL))
+	assert.NilError(t, err)
+	// When
+	_, err = client.Application.List(context.Background())
+	// Then
+	assert.Assert(t, errors.Is(err, balena.ErrUnauthorized))
+	// When
+	_, err = server.Client().Application.List(context.Background())
+	// Then
+	assert.NilError(t, err)
+}
+
+func TestServer_InvalidQuery(t *testing.T) {
+	server := NewServer(t, "")
+	for _, query := range []string{
+		"%24filter=unknown+eq+1",
+		"%24filter=device_name+eq+%27unterminated",
+		"%24filter=(device_name+eq+%27x%27",
+		"%24filter=device_name+eq",
+		"%24expand=uuid",
+		"%24orderby=device_name+sideways",
+		"%24top=-1",
+	} {
+		query := query
+		t.Run(query, func(t *testing.T) {
+			// When
+			resp, err := http.Get(server.URL + "v6/device?" + query)
+			// Then
+			assert.NilError(t, err)
+			defer resp.Body.Close()
+			assert.Equal(t, resp.StatusCode, http.StatusBadRequest)
+		})
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	device := map[string]interface{}{
+		"id":          int64(1),
+		"device_name": "it's",
+		"is_online":   true,
+		"note":        nil,
+		"cpu_temp":    int64(50),
+	}
+	resolve := func(path []string) interface{} {
+		return device[path[0]]
+	}
+	for _, tt := range []struct {
+		filter   string
+		expected bool
+	}{
+		{filter: "id eq '1'", expected: true},
+		{filter: "id eq 1", expected: true},
+		{filter: "id ne 1", expected: false},
+		{filter: "device_name eq 'it''s'", expected: true},
+		{filter: "is_online eq true and cpu_temp gt 40", expected: true},
+		{filter: "is_online eq false or cpu_temp le 40", expected: false},
+		{filter: "not (cpu_temp lt 40)", expected: true},
+		{filter: "note eq null", expected: true},
+		{filter: "note ne null", expected: false},
+		{filter: "(id eq 2 or id eq 1) and not device_name eq 'x'", expected: true},
+	} {
+		tt := tt
+		t.Run(tt.filter, func(t *testing.T) {
+			// When
+			e, err := parseFilter("device", tt.filter)
+			// Then
+			assert.NilError(t, err)
+			assert.Equal(t, e.match(resolve), tt.expected)
+		})
+	}
+}
+
+func TestServer_ApplicationEnvVar(t *testing.T) {
+	// Given
+	server := NewServer(t, "")
+	applicationID := server.AddApplication("App", server.AddDeviceType("raspberrypi4-64", "Raspberry Pi 4"))
+	client := server.Client()
+	ctx := context.Background()
+	// When
+	created, err := client.ApplicationEnvVar.Upsert(ctx, balena.ApplicationSlug("test/app"), "KEY", "first")
+	assert.NilError(t, err)
+	updated, err := client.ApplicationEnvVar.Upsert(ctx, balena.ApplicationID(applicationID), "KEY", "second")
+	assert.NilError(t, err)
+	envVar, err := client.ApplicationEnvVar.Get(ctx, balena.ApplicationSlug("test/app"), "KEY")
+	// Then
+	assert.NilError(t, err)
+	assert.Equal(t, created.ID, updated.ID)
+	assert.Equal(t, envVar.Value, "second")
+	assert.Equal(t, envVar.Application.ID, applicationID)
+	// When
+	err = client.ApplicationEnvVar.DeleteWithName(ctx, balena.ApplicationSlug("test/app"), "KEY")
+	assert.NilError(t, err)
+	envVars, err := client.ApplicationEnvVar.List(ctx, balena.ApplicationID(applicationID))
+	// Then
+	assert.NilError(t, err)
+	assert.Equal(t, len(envVars), 0)
+}
+
+func TestServer_ServiceEnvVar(t *testing.T) {
+	// Given
+	server := NewServer(t, "")
+	applicationID := server.AddApplication("App", server.AddDeviceType("raspberrypi4-64", "Raspberry Pi 4"))
+	otherApplicationID := server.AddApplication("Other", server.AddDeviceType("fincm3", "Balena Fin"))
+	server.AddService(applicationID, "main")
+	server.AddService(applicationID, "sidecar")
+	server.AddService(otherApplicationID, "main")
+	client := server.Client()
+	ctx := context.Background()
+	// When
+	_, err := client.ServiceEnvVar.Upsert(ctx, balena.ApplicationSlug("test/app"), "main", "KEY", "first")
+	assert.NilError(t, err)
+	_, err = client.ServiceEnvVar.Upsert(ctx, balena.ApplicationID(applicationID), "main", "KEY", "second")
+	assert.NilError(t, err)
+	_, err = client.ServiceEnvVar.Create(ctx, balena.ApplicationID(applicationID), "sidecar", "KEY", "sidecar")
+	assert.NilError(t, err)
+	_, err = client.ServiceEnvVar.Create(ctx, balena.ApplicationID(otherApplicationID), "main", "KEY", "other")
+	assert.NilError(t, err)
+	envVars, err := client.ServiceEnvVar.List(ctx, balena.ApplicationSlug("test/app"), "main")
+	// Then
+	assert.NilError(t, err)
+	assert.Equal(t, len(envVars), 1)
+	assert.Equal(t, envVars[0].Value, "second")
+	// When
+	err = client.ServiceEnvVar.DeleteWithName(ctx, balena.ApplicationID(applicationID), "main", "KEY")
+	assert.NilError(t, err)
+	// Then
+	assert.Equal(t, len(server.List("service_environment_variable")), 2)
+	// When
+	_, err = client.ServiceEnvVar.Create(ctx, balena.ApplicationID(applicationID), "missing", "KEY", "value")
+	// Then
+	assert.Assert(t, errors.Is(err, balena.ErrNotFound))
+}
+
+func TestServer_DeviceActions(t *testing.T) {
+	// Given
+	server := NewServer(t, "")
+	applicationID := server.AddApplication("App", server.AddDeviceType("raspberrypi4-64", "Raspberry Pi 4"))
+	deviceID := server.AddDevice(applicationID, "uuid1", "device")
+	server.SetUpdateLock("uuid1", true)
+	client := server.Client()
+	ctx := context.Background()
+	// When
+	err := client.Device.Reboot(ctx, balena.DeviceUUID("uuid1"), false)
+	// Then
+	assert.Assert(t, errors.Is(err, balena.ErrLocked))
+	// When
+	assert.NilError(t, client.Device.Reboot(ctx, balena.DeviceUUID("uuid1"), true))
+	assert.NilError(t, client.Device.PurgeData(ctx, balena.DeviceID(deviceID), true))
+	assert.NilError(t, client.Device.Identify(ctx, balena.DeviceID(deviceID)))
+	server.SetUpdateLock("uuid1", false)
+	assert.NilError(t, client.Device.RestartApplication(ctx, balena.DeviceUUID("uuid1"), false))
+	// Then
+	assert.DeepEqual(t, server.DeviceActions(), []DeviceAction{
+		{UUID: "uuid1", Action: "reboot", Force: true},
+		{UUID: "uuid1", Action: "purge", Force: true},
+		{UUID: "uuid1", Action: "blink"},
+		{UUID: "uuid1", Action: "restart-application"},
+	})
+	// When
+	err = client.Device.Shutdown(ctx, balena.DeviceUUID("missing"), false)
+	// Then
+	assert.Assert(t, errors.Is(err, balena.ErrNotFound))
+	// When
+	err = client.Device.PurgeData(ctx, balena.DeviceUUID("missing"), false)
+	// Then
+	assert.Assert(t, errors.Is(err, balena.ErrNotFound))
+}
+
+func TestServer_RegisterDevice(t *testing.T) {
+	// Given
+	server := NewServer(t, "token")
+	applicationID := server.AddApplication("App", server.AddDeviceType("raspberrypi4-64", "Raspberry Pi 4"))
+	server.AddService(applicationID, "main")
+	client := server.Client()
+	ctx := context.Background()
+	key, err := client.Application.CreateProvisioningKey(
+		ctx,
+		balena.ApplicationSlug("test/app"),
+		balena.ProvisioningKeyOptions{Name: "factory"},
+	)
+	assert.NilError(t, err)
+	registration := balena.DeviceRegistration{
+		ApplicationID:   applicationID,
+		DeviceType:      "raspberrypi4-64",
+		ProvisioningKey: key,
+	}
+	// When
+	registered, err := client.Device.Register(ctx, registration)
+	// Then
+	assert.NilError(t, err)
+	device, err := client.Device.Get(ctx, balena.DeviceUUID(registered.UUID))
+	assert.NilError(t, err)
+	assert.Equal(t, device.ID, registered.ID)
+	installs, err := client.ServiceInstall.List(ctx, balena.DeviceID(registered.ID))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, installs.ServiceNames(), []string{"main"})
+	// When
+	registration.UUID = registered.UUID
+	_, err = client.Device.Register(ctx, registration)
+	// Then
+	assert.Assert(t, errors.Is(err, balena.ErrConflict))
+	// When
+	registration.ProvisioningKey = "invalid"
+	_, err = client.Device.Register(ctx, registration)
+	// Then
+	assert.Assert(t, errors.Is(err, balena.ErrUnauthorized))
+}
@@ -0,0 +1,199 @@
+package balenatest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"go.einride.tech/balena"
+)
+
+// Service statuses reported by the supervisor.
+const (
+	ServiceRunning = "Running"
+	ServiceExited  = "exited"
+)
+
+// Supervisor is a fake of the local supervisor API of a device running a single application.
+//
+// Requests must carry the API key of the supervisor. Service states can be controlled with SetService and
+// inspected after restart and stop requests. Reboots are refused while updates are locked, unless forced.
+type Supervisor struct {
+	// Config is the configuration of clients of the supervisor.
+	Config balena.SupervisorConfig
+
+	t      testing.TB
+	server *httptest.Server
+
+	mu         sync.Mutex
+	commit     string
+	services   map[string]balena.ServiceState
+	restarts   map[string]int
+	reboots    int
+	updateLock bool
+}
+
+// NewSupervisor starts a new fake supervisor for a device running the given application, which is closed when
+// the test finishes.
+func NewSupervisor(t testing.TB, appID int64, deviceUUID string) *Supervisor {
+	t.Helper()
+	s := &Supervisor{
+		t:        t,
+		services: map[string]balena.ServiceState{},
+		restarts: map[string]int{},
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	t.Cleanup(s.server.Close)
+	s.Config = balena.SupervisorConfig{
+		Address:    s.server.URL,
+		APIKey:     newUUID(),
+		DeviceUUID: deviceUUID,
+		AppID:      appID,
+	}
+	return s
+}
+
+// V2 returns a supervisor v2 API client for the supervisor.
+func (s *Supervisor) V2() *balena.SupervisorV2Service {
+	s.t.Helper()
+	svc, err := balena.NewSupervisorV2FromConfig(s.server.Client(), s.Config)
+	if err != nil {
+		s.t.Fatalf("balenatest: unable to create supervisor v2 client: %v", err)
+	}
+	return svc
+}
+
+// V1 returns a supervisor v1 API client for the supervisor.
+func (s *Supervisor) V1() *balena.SupervisorV1Service {
+	s.t.Helper()
+	svc, err := balena.NewSupervisorV1FromConfig(s.server.Client(), s.Config)
+	if err != nil {
+		s.t.Fatalf("balenatest: unable to create supervisor v1 client: %v", err)
+	}
+	return svc
+}
+
+// SetCommit sets the commit of the release running on the device.
+func (s *Supervisor) SetCommit(commit string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commit = commit
+}
+
+// SetService adds or replaces a service of the application.
+func (s *Supervisor) SetService(name string, state balena.ServiceState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.services[name] = state
+}
+
+// Service returns the state of a service of the application.
+func (s *Supervisor) Service(name string) (balena.ServiceState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.services[name]
+	return state, ok
+}
+
+// Restarts returns the number of times a service has been restarted.
+func (s *Supervisor) Restarts(name string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.restarts[name]
+}
+
+// Reboots returns the number of times the device has been rebooted.
+func (s *Supervisor) Reboots() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reboots
+}
+
+// SetUpdateLock sets whether updates are locked, which makes reboots fail unless forced.
+func (s *Supervisor) SetUpdateLock(locked bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.updateLock = locked
+}
+
+func (s *Supervisor) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("apikey") != s.Config.APIKey {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	appPath := "/v2/applications/" + strconv.FormatInt(s.Config.AppID, 10)
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == appPath+"/state":
+		writeJSON(w, http.StatusOK, &balena.SvAppStateResp{
+			Local: map[string]balena.ApplicationState{
+				strconv.FormatInt(s.Config.AppID, 10): {Services: s.services},
+			},
+			Commit: s.commit,
+		})
+	case r.Method == http.MethodPost && r.URL.Path == appPath+"/restart-service":
+		s.setServiceStatus(w, r, ServiceRunning, true)
+	case r.Method == http.MethodPost && r.URL.Path == appPath+"/start-service":
+		s.setServiceStatus(w, r, ServiceRunning, false)
+	case r.Method == http.MethodPost && r.URL.Path == appPath+"/stop-service":
+		s.setServiceStatus(w, r, ServiceExited, false)
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/reboot":
+		s.reboot(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// setServiceStatus sets the status of the service named in the request, counting it as a restart if restart
+// is set.
+func (s *Supervisor) setServiceStatus(w http.ResponseWriter, r *http.Request, status string, restart bool) {
+	var request struct {
+		ServiceName string `json:"serviceName"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	state, ok := s.services[request.ServiceName]
+	if !ok {
+		http.Error(w, "Service not found, a container must exist for this endpoint to work", http.StatusNotFound)
+		return
+	}
+	if restart {
+		s.restarts[request.ServiceName]++
+	}
+	state.Status = status
+	s.services[request.ServiceName] = state
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte("OK"))
+}
+
+func (s *Supervisor) reboot(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Force bool `json:"force"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	type response struct {
+		Data  string `json:"Data"`
+		Error string `json:"Error"`
+	}
+	if s.updateLock && !request.Force {
+		writeJSON(w, http.StatusLocked, &response{Error: "Updates are locked"})
+		return
+	}
+	s.reboots++
+	writeJSON(w, http.StatusAccepted, &response{Data: "OK"})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
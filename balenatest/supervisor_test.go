@@ -0,0 +1,73 @@
+package balenatest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"go.einride.tech/balena"
+	"gotest.tools/v3/assert"
+)
+
+func TestSupervisor_V2(t *testing.T) {
+	// Given
+	supervisor := NewSupervisor(t, 1234, "uuid1")
+	supervisor.SetCommit("abc123")
+	supervisor.SetService("main", balena.ServiceState{Status: ServiceRunning, ReleaseID: 42})
+	client := supervisor.V2()
+	ctx := context.Background()
+	// When
+	assert.NilError(t, client.RestartServiceByName(ctx, "main"))
+	assert.NilError(t, client.StopServiceByName(ctx, "main"))
+	state, err := client.ApplicationState(ctx)
+	// Then
+	assert.NilError(t, err)
+	assert.Equal(t, supervisor.Restarts("main"), 1)
+	assert.DeepEqual(t, state, &balena.SvAppStateResp{
+		Local: map[string]balena.ApplicationState{
+			"1234": {Services: map[string]balena.ServiceState{
+				"main": {Status: ServiceExited, ReleaseID: 42},
+			}},
+		},
+		Commit: "abc123",
+	})
+	// When
+	err = client.RestartServiceByName(ctx, "missing")
+	// Then
+	assert.Assert(t, errors.Is(err, balena.ErrNotFound))
+}
+
+func TestSupervisor_V1_Reboot(t *testing.T) {
+	// Given
+	supervisor := NewSupervisor(t, 1234, "uuid1")
+	supervisor.SetUpdateLock(true)
+	client := supervisor.V1()
+	ctx := context.Background()
+	// When
+	err := client.Reboot(ctx, false)
+	// Then
+	assert.ErrorContains(t, err, "Updates are locked")
+	assert.Equal(t, supervisor.Reboots(), 0)
+	// When
+	err = client.Reboot(ctx, true)
+	// Then
+	assert.NilError(t, err)
+	assert.Equal(t, supervisor.Reboots(), 1)
+}
+
+func TestSupervisor_InvalidAPIKey(t *testing.T) {
+	// Given
+	supervisor := NewSupervisor(t, 1234, "uuid1")
+	config := supervisor.Config
+	config.APIKey = "wrong"
+	client, err := balena.NewSupervisorV2FromConfig(nil, config)
+	assert.NilError(t, err)
+	// When
+	_, err = client.ApplicationState(context.Background())
+	// Then
+	assert.Assert(t, errors.Is(err, balena.ErrUnauthorized))
+	var errorResponse *balena.ErrorResponse
+	assert.Assert(t, errors.As(err, &errorResponse))
+	assert.Equal(t, errorResponse.Response.StatusCode, http.StatusUnauthorized)
+}
@@ -3,10 +3,14 @@ package balena
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"go.einride.tech/balena/odata"
 )
@@ -17,63 +21,213 @@ const deviceBasePath = "v6/device"
 // Balena Cloud API.
 type DeviceService service
 
+// DeviceResponse is a device as returned by the API.
 type DeviceResponse struct {
-	ID               int64  `json:"id,omitempty"`
-	Actor            int64  `json:"actor,omitempty"`
-	MemoryUsage      int64  `json:"memory_usage,omitempty"`
-	MemoryTotal      int64  `json:"memory_total,omitempty"`
-	StorageUsage     int64  `json:"storage_usage,omitempty"`
-	StorageTotal     int64  `json:"storage_total,omitempty"`
-	CPUTemp          int64  `json:"cpu_temp,omitempty"`
-	CPUUsage         int64  `json:"cpu_usage,omitempty"`
-	IsOnline         bool   `json:"is_online,omitempty"`
-	IsConnectedToVPN bool   `json:"is_connected_to_vpn,omitempty"`
-	IsWebAccessible  bool   `json:"is_web_accessible,omitempty"`
-	IsActive         bool   `json:"is_active,omitempty"`
-	IsUndervolted    bool   `json:"is_undervolted,omitempty"`
-	DeviceName       string `json:"device_name,omitempty"`
-	UUID             string `json:"uuid,omitempty"`
-	// TODO: Change to time.Time maybe?
-	LastConnectivityEvent string `json:"last_connectivity_event,omitempty"`
-	Status                string `json:"status,omitempty"`
-	LastVPNEvent          string `json:"last_vpn_event,omitempty"`
-	// TODO: Should we change to net.IP maybe?
-	IPAddress string `json:"ip_address,omitempty"`
-	// TODO: Should we change to net.IP maybe?
-	VPNAddress string `json:"vpn_address,omitempty"`
-	// TODO: Should we change to net.IP maybe?
-	CPUID                              string        `json:"cpu_id,omitempty"`
-	StorageBlockDevice                 string        `json:"storage_block_device,omitempty"`
-	PublicAddress                      string        `json:"public_address,omitempty"`
-	MACAddress                         string        `json:"mac_address,omitempty"`
-	APIHeartbeatState                  string        `json:"api_heartbeat_state,omitempty"`
-	OSVersion                          string        `json:"os_version,omitempty"`
-	OSVariant                          string        `json:"os_variant,omitempty"`
-	SupervisorVersion                  string        `json:"supervisor_version,omitempty"`
-	ProvisioningState                  string        `json:"provisioning_state,omitempty"`
-	Longitude                          string        `json:"longitude,omitempty"`
-	Latitude                           string        `json:"latitude,omitempty"`
-	Location                           string        `json:"location,omitempty"`
-	CustomLongitude                    string        `json:"custom_longitude,omitempty"`
-	CustomLatitude                     string        `json:"custom_latitude,omitempty"`
-	CreatedAt                          string        `json:"created_at,omitempty"`
+	ID                    int64             `json:"id,omitempty"`
+	Actor                 int64             `json:"actor,omitempty"`
+	MemoryUsage           int64             `json:"memory_usage,omitempty"`
+	MemoryTotal           int64             `json:"memory_total,omitempty"`
+	StorageUsage          int64             `json:"storage_usage,omitempty"`
+	StorageTotal          int64             `json:"storage_total,omitempty"`
+	CPUTemp               int64             `json:"cpu_temp,omitempty"`
+	CPUUsage              int64             `json:"cpu_usage,omitempty"`
+	IsOnline              bool              `json:"is_online,omitempty"`
+	IsConnectedToVPN      bool              `json:"is_connected_to_vpn,omitempty"`
+	IsWebAccessible       bool              `json:"is_web_accessible,omitempty"`
+	IsActive              bool              `json:"is_active,omitempty"`
+	IsUndervolted         bool              `json:"is_undervolted,omitempty"`
+	DeviceName            string            `json:"device_name,omitempty"`
+	UUID                  string            `json:"uuid,omitempty"`
+	LastConnectivityEvent *time.Time        `json:"last_connectivity_event,omitempty"`
+	Status                DeviceStatus      `json:"status,omitempty"`
+	LastVPNEvent          *time.Time        `json:"last_vpn_event,omitempty"`
+	IPAddress             IPAddressList     `json:"ip_address"`
+	VPNAddress            IPAddressList     `json:"vpn_address"`
+	PublicAddress         IPAddressList     `json:"public_address"`
+	CPUID                 string            `json:"cpu_id,omitempty"`
+	StorageBlockDevice    string            `json:"storage_block_device,omitempty"`
+	MACAddress            string            `json:"mac_address,omitempty"`
+	APIHeartbeatState     APIHeartbeatState `json:"api_heartbeat_state,omitempty"`
+	OSVersion             string            `json:"os_version,omitempty"`
+	OSVariant             string            `json:"os_variant,omitempty"`
+	SupervisorVersion     string            `json:"supervisor_version,omitempty"`
+	ProvisioningState     string            `json:"provisioning_state,omitempty"`
+	Longitude             string            `json:"longitude,omitempty"`
+	Latitude              string            `json:"latitude,omitempty"`
+	Location              string            `json:"location,omitempty"`
+	CustomLongitude       string            `json:"custom_longitude,omitempty"`
+	CustomLatitude        string            `json:"custom_latitude,omitempty"`
+	CreatedAt             time.Time         `json:"created_at"`
+	// ProvisioningProgress and DownloadProgress are percentages, or nil when not provisioning or downloading.
+	ProvisioningProgress               *int64        `json:"provisioning_progress,omitempty"`
+	DownloadProgress                   *int64        `json:"download_progress,omitempty"`
 	DeviceType                         *odata.Object `json:"device_type,omitempty"`
 	BelongsToApplication               *odata.Object `json:"belongs_to__application,omitempty"`
 	BelongsToUser                      *odata.Object `json:"belongs_to__user,omitempty"`
 	IsManagedByServiceInstance         *odata.Object `json:"is_managed_by__service_instance,omitempty"`
-	IsManagedByDevice                  interface{}   `json:"is_managed_by__device,omitempty"`
+	IsManagedByDevice                  *odata.Object `json:"is_managed_by__device,omitempty"`
 	IsRunningRelease                   *odata.Object `json:"is_running__release,omitempty"`
 	ShouldBeRunningRelease             *odata.Object `json:"should_be_running__release,omitempty"`
 	ShouldBeManagedBySupervisorRelease *odata.Object `json:"should_be_managed_by__supervisor_release,omitempty"`
-	Note                               interface{}   `json:"note,omitempty"`
-	LocalID                            interface{}   `json:"local_id,omitempty"`
-	ProvisioningProgress               interface{}   `json:"provisioning_progress,omitempty"`
-	DownloadProgress                   interface{}   `json:"download_progress,omitempty"`
-	LogsChannel                        interface{}   `json:"logs_channel,omitempty"`
-	IsLockedUntil                      interface{}   `json:"is_locked_until__date,omitempty"`
-	IsAccessibleBySupportUntil         interface{}   `json:"is_accessible_by_support_until__date,omitempty"`
+	Note                               string        `json:"note,omitempty"`
+	LocalID                            string        `json:"local_id,omitempty"`
+	LogsChannel                        string        `json:"logs_channel,omitempty"`
+	// IsLockedUntil and IsAccessibleBySupportUntil are nil when not set.
+	IsLockedUntil              *time.Time `json:"is_locked_until__date,omitempty"`
+	IsAccessibleBySupportUntil *time.Time `json:"is_accessible_by_support_until__date,omitempty"`
 	// OverallStatus will only be populated when explicitly selected through a query parameter: `$select=overall_status`.
-	OverallStatus string `json:"overall_status,omitempty"`
+	OverallStatus DeviceOverallStatus `json:"overall_status,omitempty"`
+	// Raw is the JSON object the device was decoded from, giving access to fields not covered by DeviceResponse.
+	Raw json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler, retaining the JSON object in Raw. Timestamps that are null or empty
+// strings are decoded as nil, or the zero time for CreatedAt.
+func (d *DeviceResponse) UnmarshalJSON(b []byte) error {
+	type device DeviceResponse
+	// The timestamps shadow those of the embedded device.
+	aux := struct {
+		*device
+		LastConnectivityEvent      apiTime `json:"last_connectivity_event"`
+		LastVPNEvent               apiTime `json:"last_vpn_event"`
+		CreatedAt                  apiTime `json:"created_at"`
+		IsLockedUntil              apiTime `json:"is_locked_until__date"`
+		IsAccessibleBySupportUntil apiTime `json:"is_accessible_by_support_until__date"`
+	}{device: (*device)(d)}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	d.LastConnectivityEvent = aux.LastConnectivityEvent.t
+	d.LastVPNEvent = aux.LastVPNEvent.t
+	d.CreatedAt = time.Time{}
+	if aux.CreatedAt.t != nil {
+		d.CreatedAt = *aux.CreatedAt.t
+	}
+	d.IsLockedUntil = aux.IsLockedUntil.t
+	d.IsAccessibleBySupportUntil = aux.IsAccessibleBySupportUntil.t
+	d.Raw = append(json.RawMessage(nil), b...)
+	return nil
+}
+
+// apiTime decodes a timestamp returned by the API, where null and the empty string mean no time.
+type apiTime struct {
+	t *time.Time
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (a *apiTime) UnmarshalJSON(b []byte) error {
+	var s *string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("unmarshal timestamp: %w", err)
+	}
+	a.t = nil
+	if s == nil || *s == "" {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, *s)
+	if err != nil {
+		return fmt.Errorf("unmarshal timestamp: %w", err)
+	}
+	a.t = &t
+	return nil
+}
+
+// DeviceStatus is the status of a device as reported by its supervisor.
+// Values other than the constants below may be returned and are kept as is.
+type DeviceStatus string
+
+const (
+	DeviceStatusIdle        DeviceStatus = "idle"
+	DeviceStatusDownloading DeviceStatus = "downloading"
+	DeviceStatusUpdating    DeviceStatus = "updating"
+	DeviceStatusOffline     DeviceStatus = "offline"
+)
+
+// DeviceOverallStatus is the status of a device as summarized by the API, see DeviceResponse.OverallStatus.
+// Values other than the constants below may be returned and are kept as is.
+type DeviceOverallStatus string
+
+const (
+	DeviceOverallStatusIdle                 DeviceOverallStatus = "idle"
+	DeviceOverallStatusConfiguring          DeviceOverallStatus = "configuring"
+	DeviceOverallStatusUpdating             DeviceOverallStatus = "updating"
+	DeviceOverallStatusOffline              DeviceOverallStatus = "offline"
+	DeviceOverallStatusDisconnected         DeviceOverallStatus = "disconnected"
+	DeviceOverallStatusInactive             DeviceOverallStatus = "inactive"
+	DeviceOverallStatusPostProvisioning     DeviceOverallStatus = "post-provisioning"
+	DeviceOverallStatusReducedFunctionality DeviceOverallStatus = "reduced-functionality"
+)
+
+// APIHeartbeatState is the state of the heartbeat of a device to the API.
+// Values other than the constants below may be returned and are kept as is.
+type APIHeartbeatState string
+
+const (
+	APIHeartbeatStateOnline  APIHeartbeatState = "online"
+	APIHeartbeatStateOffline APIHeartbeatState = "offline"
+	APIHeartbeatStateTimeout APIHeartbeatState = "timeout"
+	APIHeartbeatStateUnknown APIHeartbeatState = "unknown"
+)
+
+// IPAddressList is a list of IP addresses, encoded by the API as a single space-separated string.
+// Decoding never fails on malformed addresses: they are left out of IPs and kept in Raw.
+type IPAddressList struct {
+	// IPs are the addresses that could be parsed, in order. The zones of scoped IPv6 addresses such as
+	// `fe80::1%wlan0` are dropped.
+	IPs []net.IP
+	// Raw is the list as returned by the API.
+	Raw string
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (l *IPAddressList) UnmarshalJSON(b []byte) error {
+	var s *string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("unmarshal IP address list: %w", err)
+	}
+	*l = IPAddressList{}
+	if s == nil {
+		return nil
+	}
+	l.Raw = *s
+	for _, field := range strings.Fields(*s) {
+		if i := strings.IndexByte(field, '%'); i >= 0 {
+			field = field[:i]
+		}
+		if ip := net.ParseIP(field); ip != nil {
+			l.IPs = append(l.IPs, ip)
+		}
+	}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (l IPAddressList) MarshalJSON() ([]byte, error) {
+	if l.Raw == "" && len(l.IPs) == 0 {
+		return []byte("null"), nil
+	}
+	return json.Marshal(l.String())
+}
+
+// First returns the first parsed address, or nil if there is none. It is useful for fields holding a single
+// address, such as DeviceResponse.VPNAddress.
+func (l IPAddressList) First() net.IP {
+	if len(l.IPs) == 0 {
+		return nil
+	}
+	return l.IPs[0]
+}
+
+// String returns the list as returned by the API, or the parsed addresses separated by spaces if there is none.
+func (l IPAddressList) String() string {
+	if l.Raw != "" {
+		return l.Raw
+	}
+	fields := make([]string, 0, len(l.IPs))
+	for _, ip := range l.IPs {
+		fields = append(fields, ip.String())
+	}
+	return strings.Join(fields, " ")
 }
 
 // List returns a list of all devices.
@@ -81,6 +235,47 @@ func (s *DeviceService) List(ctx context.Context) ([]*DeviceResponse, error) {
 	return s.GetWithQuery(ctx, "")
 }
 
+// ForEach calls fn for each device matching the query, fetching devices page by page as they are needed.
+// The query should be a valid, escaped OData query as accepted by GetWithQuery. It must not contain $orderby,
+// $top or $skip, which are used for paginating by device ID.
+// Iteration stops at the first error returned by fn, which is then returned, or when ctx is done.
+func (s *DeviceService) ForEach(ctx context.Context, query string, fn func(*DeviceResponse) error) error {
+	return s.ForEachPage(ctx, query, func(page []*DeviceResponse) error {
+		for _, device := range page {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := fn(device); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ForEachPage is like ForEach, but calls fn once per page of devices.
+func (s *DeviceService) ForEachPage(ctx context.Context, query string, fn func([]*DeviceResponse) error) error {
+	p, err := s.client.newPager(deviceBasePath, query)
+	if err != nil {
+		return fmt.Errorf("unable to create device request: %w", err)
+	}
+	for {
+		type Response struct {
+			D []*DeviceResponse `json:"d,omitempty"`
+		}
+		resp := &Response{}
+		if err := p.next(ctx, resp); err != nil {
+			return fmt.Errorf("unable to query device: %w", err)
+		}
+		if err := fn(resp.D); err != nil {
+			return err
+		}
+		if !p.more(len(resp.D)) {
+			return nil
+		}
+	}
+}
+
 // ListByApplication returns a list of all devices owned by the single application given its ID.
 func (s *DeviceService) ListByApplication(ctx context.Context, applicationID int64) ([]*DeviceResponse, error) {
 	query := "%24filter=belongs_to__application%20eq%20%27" + strconv.FormatInt(applicationID, 10) + "%27"
@@ -90,15 +285,13 @@ func (s *DeviceService) ListByApplication(ctx context.Context, applicationID int
 // Get returns information on a single device given its ID or UUID.
 // If the device does not exist, both the response and error are nil.
 func (s *DeviceService) Get(ctx context.Context, deviceID IDOrUUID) (*DeviceResponse, error) {
-	var query string
-	path := odata.EntityURL(deviceBasePath, deviceID.id)
-	if deviceID.isUUID {
-		query = "%24filter=uuid+eq+%27" + deviceID.id + "%27"
-		path = deviceBasePath
+	path, query, err := deviceEntity(deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create get request: %w", err)
 	}
 	req, err := s.client.NewRequest(ctx, http.MethodGet, path, query, nil)
 	if err != nil {
-		return nil, fmt.Errorf("unable to create get request: %v", err)
+		return nil, fmt.Errorf("unable to create get request: %w", err)
 	}
 	type Response struct {
 		D []DeviceResponse `json:"d,omitempty"`
@@ -106,7 +299,7 @@ func (s *DeviceService) Get(ctx context.Context, deviceID IDOrUUID) (*DeviceResp
 	resp := &Response{}
 	err = s.client.Do(req, resp)
 	if err != nil {
-		return nil, fmt.Errorf("unable to get device: %v", err)
+		return nil, fmt.Errorf("unable to get device: %w", err)
 	}
 	if len(resp.D) > 1 {
 		return nil, errors.New("received more than 1 device, expected 0 or 1")
@@ -117,14 +310,44 @@ func (s *DeviceService) Get(ctx context.Context, deviceID IDOrUUID) (*DeviceResp
 	return &resp.D[0], nil
 }
 
+// deviceEntity returns the path and query addressing a single device given its ID or UUID.
+func deviceEntity(deviceID IDOrUUID) (path string, query string, err error) {
+	if !deviceID.isUUID {
+		return odata.EntityURL(deviceBasePath, deviceID.id), "", nil
+	}
+	query, err = filterQuery(odata.Eq("uuid", deviceID.id))
+	if err != nil {
+		return "", "", err
+	}
+	return deviceBasePath, query, nil
+}
+
+// doDeviceRequest performs a PATCH or DELETE request on the device with given ID/UUID. A device given by ID is
+// addressed as an entity, like in the other methods of DeviceService. A device given by UUID is looked up by its
+// UUID first, and ErrNotFound is returned if no device has the UUID.
+func (s *DeviceService) doDeviceRequest(ctx context.Context, method string, deviceID IDOrUUID, body interface{}) error {
+	if !deviceID.isUUID {
+		return s.client.doQueryRequest(ctx, method, odata.EntityURL(deviceBasePath, deviceID.id), "", body)
+	}
+	n, err := s.client.doFilterRequest(ctx, method, deviceBasePath, odata.Eq("uuid", deviceID.id), body, true)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
 // GetWithQuery allows querying for devices using a custom open data protocol query.
 // The query should be a valid, escaped OData query such as `%24filter=uuid+eq+'12333422'`
 //
 // Forward slash in filter keys should not be escaped (So `device/uuid` should not be escaped).
+// Use odata.Query to build a correctly escaped query.
 func (s *DeviceService) GetWithQuery(ctx context.Context, query string) ([]*DeviceResponse, error) {
 	req, err := s.client.NewRequest(ctx, http.MethodGet, deviceBasePath, query, nil)
 	if err != nil {
-		return nil, fmt.Errorf("unable to create device request: %v", err)
+		return nil, fmt.Errorf("unable to create device request: %w", err)
 	}
 	type Response struct {
 		D []*DeviceResponse `json:"d,omitempty"`
@@ -132,7 +355,7 @@ func (s *DeviceService) GetWithQuery(ctx context.Context, query string) ([]*Devi
 	resp := &Response{}
 	err = s.client.Do(req, resp)
 	if err != nil {
-		return nil, fmt.Errorf("unable to query device: %v", err)
+		return nil, fmt.Errorf("unable to query device: %w", err)
 	}
 	return resp.D, nil
 }
@@ -143,11 +366,9 @@ func (s *DeviceService) PinRelease(ctx context.Context, deviceID IDOrUUID, relea
 		ShouldRunRelease string `json:"should_be_running__release"`
 	}
 	release := strconv.FormatInt(releaseID, 10)
-	var query string
-	path := odata.EntityURL(deviceBasePath, deviceID.id)
-	if deviceID.isUUID {
-		query = "%24filter=uuid+eq+%27" + deviceID.id + "%27"
-		path = deviceBasePath
+	path, query, err := deviceEntity(deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create setRelease request: %w", err)
 	}
 	req, err := s.client.NewRequest(
 		ctx,
@@ -157,12 +378,12 @@ func (s *DeviceService) PinRelease(ctx context.Context, deviceID IDOrUUID, relea
 		&request{ShouldRunRelease: release},
 	)
 	if err != nil {
-		return nil, fmt.Errorf("unable to create setRelease request: %v", err)
+		return nil, fmt.Errorf("unable to create setRelease request: %w", err)
 	}
 	buf := &bytes.Buffer{}
 	err = s.client.Do(req, buf)
 	if err != nil {
-		return nil, fmt.Errorf("unable to patch device: %v", err)
+		return nil, fmt.Errorf("unable to patch device: %w", err)
 	}
 	return buf.Bytes(), nil
 }
@@ -172,11 +393,9 @@ func (s *DeviceService) TrackLatestRelease(ctx context.Context, deviceID IDOrUUI
 	type request struct {
 		ShouldRunRelease interface{} `json:"should_be_running__release"`
 	}
-	var query string
-	path := odata.EntityURL(deviceBasePath, deviceID.id)
-	if deviceID.isUUID {
-		query = "%24filter=uuid+eq+%27" + deviceID.id + "%27"
-		path = deviceBasePath
+	path, query, err := deviceEntity(deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create setRelease request: %w", err)
 	}
 	req, err := s.client.NewRequest(
 		ctx,
@@ -186,12 +405,12 @@ func (s *DeviceService) TrackLatestRelease(ctx context.Context, deviceID IDOrUUI
 		&request{ShouldRunRelease: nil},
 	)
 	if err != nil {
-		return nil, fmt.Errorf("unable to create setRelease request: %v", err)
+		return nil, fmt.Errorf("unable to create setRelease request: %w", err)
 	}
 	buf := &bytes.Buffer{}
 	err = s.client.Do(req, buf)
 	if err != nil {
-		return nil, fmt.Errorf("unable to patch device: %v", err)
+		return nil, fmt.Errorf("unable to patch device: %w", err)
 	}
 	return buf.Bytes(), nil
 }
@@ -205,11 +424,9 @@ func (s *DeviceService) MoveToApplication(ctx context.Context, deviceID IDOrUUID
 		BelongsToApplication string `json:"belongs_to__application"`
 	}
 	application := strconv.FormatInt(applicationID, 10)
-	var query string
-	path := odata.EntityURL(deviceBasePath, deviceID.id)
-	if deviceID.isUUID {
-		query = "%24filter=uuid+eq+%27" + deviceID.id + "%27"
-		path = deviceBasePath
+	path, query, err := deviceEntity(deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
 	}
 	req, err := s.client.NewRequest(
 		ctx,
@@ -219,12 +436,12 @@ func (s *DeviceService) MoveToApplication(ctx context.Context, deviceID IDOrUUID
 		&request{BelongsToApplication: application},
 	)
 	if err != nil {
-		return nil, fmt.Errorf("unable to create request: %v", err)
+		return nil, fmt.Errorf("unable to create request: %w", err)
 	}
 	buf := &bytes.Buffer{}
 	err = s.client.Do(req, buf)
 	if err != nil {
-		return nil, fmt.Errorf("unable to patch device: %v", err)
+		return nil, fmt.Errorf("unable to patch device: %w", err)
 	}
 	return buf.Bytes(), nil
 }
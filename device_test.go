@@ -2,11 +2,15 @@ package balena
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"go.einride.tech/balena/odata"
 	"gotest.tools/v3/assert"
@@ -128,13 +132,13 @@ func TestDeviceService_List_ID(t *testing.T) {
 				Deferred: odata.Deferred{URI: "/resin/device_type(@id)?@id=58"}, ID: 58,
 			},
 			UUID:                  "6fe2836d9bbebc5b399f5fc28b840e8e",
-			LastConnectivityEvent: "2021-05-23T04:13:21.629Z",
-			Status:                "idle",
-			OverallStatus:         "idle",
-			LastVPNEvent:          "2021-05-23T04:13:21.629Z",
-			IPAddress:             "10.1.20.198",
-			VPNAddress:            "12.345.95.246",
-			PublicAddress:         "12.345.41.74",
+			LastConnectivityEvent: Time(time.Date(2021, 5, 23, 4, 13, 21, 629000000, time.UTC)),
+			Status:                DeviceStatusIdle,
+			OverallStatus:         DeviceOverallStatusIdle,
+			LastVPNEvent:          Time(time.Date(2021, 5, 23, 4, 13, 21, 629000000, time.UTC)),
+			IPAddress:             IPAddressList{IPs: []net.IP{net.ParseIP("10.1.20.198")}, Raw: "10.1.20.198"},
+			VPNAddress:            IPAddressList{Raw: "12.345.95.246"},
+			PublicAddress:         IPAddressList{Raw: "12.345.41.74"},
 			OSVersion:             "balenaOS 2.75.0+rev1",
 			OSVariant:             "prod",
 			SupervisorVersion:     "12.5.10",
@@ -144,7 +148,7 @@ func TestDeviceService_List_ID(t *testing.T) {
 			Location:              "Landvetter, Västra Götaland County, Sweden",
 			CustomLongitude:       "",
 			CustomLatitude:        "",
-			CreatedAt:             "2021-05-11T08:05:16.634Z",
+			CreatedAt:             time.Date(2021, 5, 11, 8, 5, 16, 634000000, time.UTC),
 			IsOnline:              true,
 			IsConnectedToVPN:      true,
 			IsWebAccessible:       false,
@@ -163,33 +167,27 @@ func TestDeviceService_List_ID(t *testing.T) {
 			ShouldBeRunningRelease: &odata.Object{
 				Deferred: odata.Deferred{URI: "/resin/release(@id)?@id=1796078"}, ID: 1796078,
 			},
-			Note:    nil,
-			LocalID: nil,
 			ShouldBeManagedBySupervisorRelease: &odata.Object{
 				Deferred: odata.Deferred{URI: "/resin/supervisor_release(@id)?@id=1764685"}, ID: 1764685,
 			},
-			ProvisioningProgress:       nil,
-			DownloadProgress:           nil,
-			LogsChannel:                nil,
-			IsLockedUntil:              nil,
-			IsAccessibleBySupportUntil: nil,
-			MACAddress:                 "b8:27:eb:72:f9:5e b8:40:eb:27:ac:0b",
-			APIHeartbeatState:          "online",
-			MemoryUsage:                321,
-			MemoryTotal:                973,
-			StorageBlockDevice:         "/dev/mmcblk0p6",
-			StorageUsage:               191,
-			StorageTotal:               14138,
-			CPUTemp:                    63,
-			CPUUsage:                   34,
-			CPUID:                      "000000008e72f95e",
-			IsUndervolted:              false,
+			MACAddress:         "b8:27:eb:72:f9:5e b8:40:eb:27:ac:0b",
+			APIHeartbeatState:  APIHeartbeatStateOnline,
+			MemoryUsage:        321,
+			MemoryTotal:        973,
+			StorageBlockDevice: "/dev/mmcblk0p6",
+			StorageUsage:       191,
+			StorageTotal:       14138,
+			CPUTemp:            63,
+			CPUUsage:           34,
+			CPUID:              "000000008e72f95e",
+			IsUndervolted:      false,
 		},
 	}
 	// When
 	actual, err := client.Device.List(context.Background())
 	// Then
 	assert.NilError(t, err)
+	clearRaw(t, actual...)
 	assert.DeepEqual(t, expected, actual)
 }
 
@@ -216,13 +214,13 @@ func TestDeviceService_ListByApplication(t *testing.T) {
 				Deferred: odata.Deferred{URI: "/resin/device_type(@id)?@id=58"}, ID: 58,
 			},
 			UUID:                  "6fe2836d9bbebc5b399f5fc28b840e8e",
-			LastConnectivityEvent: "2021-05-23T04:13:21.629Z",
-			Status:                "idle",
-			OverallStatus:         "idle",
-			LastVPNEvent:          "2021-05-23T04:13:21.629Z",
-			IPAddress:             "10.1.20.198",
-			VPNAddress:            "12.345.95.246",
-			PublicAddress:         "12.345.41.74",
+			LastConnectivityEvent: Time(time.Date(2021, 5, 23, 4, 13, 21, 629000000, time.UTC)),
+			Status:                DeviceStatusIdle,
+			OverallStatus:         DeviceOverallStatusIdle,
+			LastVPNEvent:          Time(time.Date(2021, 5, 23, 4, 13, 21, 629000000, time.UTC)),
+			IPAddress:             IPAddressList{IPs: []net.IP{net.ParseIP("10.1.20.198")}, Raw: "10.1.20.198"},
+			VPNAddress:            IPAddressList{Raw: "12.345.95.246"},
+			PublicAddress:         IPAddressList{Raw: "12.345.41.74"},
 			OSVersion:             "balenaOS 2.75.0+rev1",
 			OSVariant:             "prod",
 			SupervisorVersion:     "12.5.10",
@@ -232,7 +230,7 @@ func TestDeviceService_ListByApplication(t *testing.T) {
 			Location:              "Landvetter, Västra Götaland County, Sweden",
 			CustomLongitude:       "",
 			CustomLatitude:        "",
-			CreatedAt:             "2021-05-11T08:05:16.634Z",
+			CreatedAt:             time.Date(2021, 5, 11, 8, 5, 16, 634000000, time.UTC),
 			IsOnline:              true,
 			IsConnectedToVPN:      true,
 			IsWebAccessible:       false,
@@ -251,33 +249,27 @@ func TestDeviceService_ListByApplication(t *testing.T) {
 			ShouldBeRunningRelease: &odata.Object{
 				Deferred: odata.Deferred{URI: "/resin/release(@id)?@id=1796078"}, ID: 1796078,
 			},
-			Note:    nil,
-			LocalID: nil,
 			ShouldBeManagedBySupervisorRelease: &odata.Object{
 				Deferred: odata.Deferred{URI: "/resin/supervisor_release(@id)?@id=1764685"}, ID: 1764685,
 			},
-			ProvisioningProgress:       nil,
-			DownloadProgress:           nil,
-			LogsChannel:                nil,
-			IsLockedUntil:              nil,
-			IsAccessibleBySupportUntil: nil,
-			MACAddress:                 "b8:27:eb:72:f9:5e b8:40:eb:27:ac:0b",
-			APIHeartbeatState:          "online",
-			MemoryUsage:                321,
-			MemoryTotal:                973,
-			StorageBlockDevice:         "/dev/mmcblk0p6",
-			StorageUsage:               191,
-			StorageTotal:               14138,
-			CPUTemp:                    63,
-			CPUUsage:                   34,
-			CPUID:                      "000000008e72f95e",
-			IsUndervolted:              false,
+			MACAddress:         "b8:27:eb:72:f9:5e b8:40:eb:27:ac:0b",
+			APIHeartbeatState:  APIHeartbeatStateOnline,
+			MemoryUsage:        321,
+			MemoryTotal:        973,
+			StorageBlockDevice: "/dev/mmcblk0p6",
+			StorageUsage:       191,
+			StorageTotal:       14138,
+			CPUTemp:            63,
+			CPUUsage:           34,
+			CPUID:              "000000008e72f95e",
+			IsUndervolted:      false,
 		},
 	}
 	// When
 	actual, err := client.Device.ListByApplication(context.Background(), applicationID)
 	// Then
 	assert.NilError(t, err)
+	clearRaw(t, actual...)
 	assert.DeepEqual(t, expected, actual)
 }
 
@@ -301,13 +293,13 @@ func TestDeviceService_Get_ID(t *testing.T) {
 			Deferred: odata.Deferred{URI: "/resin/device_type(@id)?@id=58"}, ID: 58,
 		},
 		UUID:                  "6fe2836d9bbebc5b399f5fc28b840e8e",
-		LastConnectivityEvent: "2021-05-23T04:13:21.629Z",
-		Status:                "idle",
-		OverallStatus:         "idle",
-		LastVPNEvent:          "2021-05-23T04:13:21.629Z",
-		IPAddress:             "10.1.20.198",
-		VPNAddress:            "12.345.95.246",
-		PublicAddress:         "12.345.41.74",
+		LastConnectivityEvent: Time(time.Date(2021, 5, 23, 4, 13, 21, 629000000, time.UTC)),
+		Status:                DeviceStatusIdle,
+		OverallStatus:         DeviceOverallStatusIdle,
+		LastVPNEvent:          Time(time.Date(2021, 5, 23, 4, 13, 21, 629000000, time.UTC)),
+		IPAddress:             IPAddressList{IPs: []net.IP{net.ParseIP("10.1.20.198")}, Raw: "10.1.20.198"},
+		VPNAddress:            IPAddressList{Raw: "12.345.95.246"},
+		PublicAddress:         IPAddressList{Raw: "12.345.41.74"},
 		OSVersion:             "balenaOS 2.75.0+rev1",
 		OSVariant:             "prod",
 		SupervisorVersion:     "12.5.10",
@@ -317,7 +309,7 @@ func TestDeviceService_Get_ID(t *testing.T) {
 		Location:              "Landvetter, Västra Götaland County, Sweden",
 		CustomLongitude:       "",
 		CustomLatitude:        "",
-		CreatedAt:             "2021-05-11T08:05:16.634Z",
+		CreatedAt:             time.Date(2021, 5, 11, 8, 5, 16, 634000000, time.UTC),
 		IsOnline:              true,
 		IsConnectedToVPN:      true,
 		IsWebAccessible:       false,
@@ -336,32 +328,26 @@ func TestDeviceService_Get_ID(t *testing.T) {
 		ShouldBeRunningRelease: &odata.Object{
 			Deferred: odata.Deferred{URI: "/resin/release(@id)?@id=1796078"}, ID: 1796078,
 		},
-		Note:    nil,
-		LocalID: nil,
 		ShouldBeManagedBySupervisorRelease: &odata.Object{
 			Deferred: odata.Deferred{URI: "/resin/supervisor_release(@id)?@id=1764685"}, ID: 1764685,
 		},
-		ProvisioningProgress:       nil,
-		DownloadProgress:           nil,
-		LogsChannel:                nil,
-		IsLockedUntil:              nil,
-		IsAccessibleBySupportUntil: nil,
-		MACAddress:                 "b8:27:eb:72:f9:5e b8:40:eb:27:ac:0b",
-		APIHeartbeatState:          "online",
-		MemoryUsage:                321,
-		MemoryTotal:                973,
-		StorageBlockDevice:         "/dev/mmcblk0p6",
-		StorageUsage:               191,
-		StorageTotal:               14138,
-		CPUTemp:                    63,
-		CPUUsage:                   34,
-		CPUID:                      "000000008e72f95e",
-		IsUndervolted:              false,
+		MACAddress:         "b8:27:eb:72:f9:5e b8:40:eb:27:ac:0b",
+		APIHeartbeatState:  APIHeartbeatStateOnline,
+		MemoryUsage:        321,
+		MemoryTotal:        973,
+		StorageBlockDevice: "/dev/mmcblk0p6",
+		StorageUsage:       191,
+		StorageTotal:       14138,
+		CPUTemp:            63,
+		CPUUsage:           34,
+		CPUID:              "000000008e72f95e",
+		IsUndervolted:      false,
 	}
 	// When
 	actual, err := client.Device.Get(context.Background(), DeviceID(entityID))
 	// Then
 	assert.NilError(t, err)
+	clearRaw(t, actual)
 	assert.DeepEqual(t, expected, actual)
 }
 
@@ -387,13 +373,13 @@ func TestDeviceService_Get_UUID(t *testing.T) {
 			Deferred: odata.Deferred{URI: "/resin/device_type(@id)?@id=58"}, ID: 58,
 		},
 		UUID:                  "6fe2836d9bbebc5b399f5fc28b840e8e",
-		LastConnectivityEvent: "2021-05-23T04:13:21.629Z",
-		Status:                "idle",
-		OverallStatus:         "idle",
-		LastVPNEvent:          "2021-05-23T04:13:21.629Z",
-		IPAddress:             "10.1.20.198",
-		VPNAddress:            "12.345.95.246",
-		PublicAddress:         "12.345.41.74",
+		LastConnectivityEvent: Time(time.Date(2021, 5, 23, 4, 13, 21, 629000000, time.UTC)),
+		Status:                DeviceStatusIdle,
+		OverallStatus:         DeviceOverallStatusIdle,
+		LastVPNEvent:          Time(time.Date(2021, 5, 23, 4, 13, 21, 629000000, time.UTC)),
+		IPAddress:             IPAddressList{IPs: []net.IP{net.ParseIP("10.1.20.198")}, Raw: "10.1.20.198"},
+		VPNAddress:            IPAddressList{Raw: "12.345.95.246"},
+		PublicAddress:         IPAddressList{Raw: "12.345.41.74"},
 		OSVersion:             "balenaOS 2.75.0+rev1",
 		OSVariant:             "prod",
 		SupervisorVersion:     "12.5.10",
@@ -403,7 +389,7 @@ func TestDeviceService_Get_UUID(t *testing.T) {
 		Location:              "Landvetter, Västra Götaland County, Sweden",
 		CustomLongitude:       "",
 		CustomLatitude:        "",
-		CreatedAt:             "2021-05-11T08:05:16.634Z",
+		CreatedAt:             time.Date(2021, 5, 11, 8, 5, 16, 634000000, time.UTC),
 		IsOnline:              true,
 		IsConnectedToVPN:      true,
 		IsWebAccessible:       false,
@@ -422,32 +408,26 @@ func TestDeviceService_Get_UUID(t *testing.T) {
 		ShouldBeRunningRelease: &odata.Object{
 			Deferred: odata.Deferred{URI: "/resin/release(@id)?@id=1796078"}, ID: 1796078,
 		},
-		Note:    nil,
-		LocalID: nil,
 		ShouldBeManagedBySupervisorRelease: &odata.Object{
 			Deferred: odata.Deferred{URI: "/resin/supervisor_release(@id)?@id=1764685"}, ID: 1764685,
 		},
-		ProvisioningProgress:       nil,
-		DownloadProgress:           nil,
-		LogsChannel:                nil,
-		IsLockedUntil:              nil,
-		IsAccessibleBySupportUntil: nil,
-		MACAddress:                 "b8:27:eb:72:f9:5e b8:40:eb:27:ac:0b",
-		APIHeartbeatState:          "online",
-		MemoryUsage:                321,
-		MemoryTotal:                973,
-		StorageBlockDevice:         "/dev/mmcblk0p6",
-		StorageUsage:               191,
-		StorageTotal:               14138,
-		CPUTemp:                    63,
-		CPUUsage:                   34,
-		CPUID:                      "000000008e72f95e",
-		IsUndervolted:              false,
+		MACAddress:         "b8:27:eb:72:f9:5e b8:40:eb:27:ac:0b",
+		APIHeartbeatState:  APIHeartbeatStateOnline,
+		MemoryUsage:        321,
+		MemoryTotal:        973,
+		StorageBlockDevice: "/dev/mmcblk0p6",
+		StorageUsage:       191,
+		StorageTotal:       14138,
+		CPUTemp:            63,
+		CPUUsage:           34,
+		CPUID:              "000000008e72f95e",
+		IsUndervolted:      false,
 	}
 	// When
 	actual, err := client.Device.Get(context.Background(), DeviceUUID(uuid))
 	// Then
 	assert.NilError(t, err)
+	clearRaw(t, actual)
 	assert.DeepEqual(t, expected, actual)
 }
 
@@ -472,6 +452,35 @@ func TestDeviceService_Get_NotFound(t *testing.T) {
 	assert.Assert(t, device == nil)
 }
 
+func TestDeviceService_Get_UnsetFields(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/"+deviceBasePath, func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		fmt.Fprint(w, `{"d":[{
+			"id":1,
+			"uuid":"abc",
+			"created_at":"2021-01-02T03:04:05.000Z",
+			"api_heartbeat_state":"unknown",
+			"overall_status":"idle",
+			"download_progress":null,
+			"ip_address":null,
+			"is_locked_until__date":null
+		}]}`)
+	})
+	// When
+	device, err := client.Device.Get(context.Background(), DeviceUUID("abc"))
+	// Then
+	assert.NilError(t, err)
+	assert.Assert(t, device.CreatedAt.Equal(time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)))
+	assert.Equal(t, device.APIHeartbeatState, APIHeartbeatStateUnknown)
+	assert.Equal(t, device.OverallStatus, DeviceOverallStatusIdle)
+	assert.Assert(t, device.DownloadProgress == nil)
+	assert.Assert(t, device.IPAddress.First() == nil)
+	assert.Assert(t, device.IsLockedUntil == nil)
+}
+
 func TestDeviceService_GetWithQuery(t *testing.T) {
 	// Given
 	client, mux, cleanup := newFixture()
@@ -494,13 +503,13 @@ func TestDeviceService_GetWithQuery(t *testing.T) {
 				Deferred: odata.Deferred{URI: "/resin/device_type(@id)?@id=58"}, ID: 58,
 			},
 			UUID:                  "6fe2836d9bbebc5b399f5fc28b840e8e",
-			LastConnectivityEvent: "2021-05-23T04:13:21.629Z",
-			Status:                "idle",
-			OverallStatus:         "idle",
-			LastVPNEvent:          "2021-05-23T04:13:21.629Z",
-			IPAddress:             "10.1.20.198",
-			VPNAddress:            "12.345.95.246",
-			PublicAddress:         "12.345.41.74",
+			LastConnectivityEvent: Time(time.Date(2021, 5, 23, 4, 13, 21, 629000000, time.UTC)),
+			Status:                DeviceStatusIdle,
+			OverallStatus:         DeviceOverallStatusIdle,
+			LastVPNEvent:          Time(time.Date(2021, 5, 23, 4, 13, 21, 629000000, time.UTC)),
+			IPAddress:             IPAddressList{IPs: []net.IP{net.ParseIP("10.1.20.198")}, Raw: "10.1.20.198"},
+			VPNAddress:            IPAddressList{Raw: "12.345.95.246"},
+			PublicAddress:         IPAddressList{Raw: "12.345.41.74"},
 			OSVersion:             "balenaOS 2.75.0+rev1",
 			OSVariant:             "prod",
 			SupervisorVersion:     "12.5.10",
@@ -510,7 +519,7 @@ func TestDeviceService_GetWithQuery(t *testing.T) {
 			Location:              "Landvetter, Västra Götaland County, Sweden",
 			CustomLongitude:       "",
 			CustomLatitude:        "",
-			CreatedAt:             "2021-05-11T08:05:16.634Z",
+			CreatedAt:             time.Date(2021, 5, 11, 8, 5, 16, 634000000, time.UTC),
 			IsOnline:              true,
 			IsConnectedToVPN:      true,
 			IsWebAccessible:       false,
@@ -529,33 +538,27 @@ func TestDeviceService_GetWithQuery(t *testing.T) {
 			ShouldBeRunningRelease: &odata.Object{
 				Deferred: odata.Deferred{URI: "/resin/release(@id)?@id=1796078"}, ID: 1796078,
 			},
-			Note:    nil,
-			LocalID: nil,
 			ShouldBeManagedBySupervisorRelease: &odata.Object{
 				Deferred: odata.Deferred{URI: "/resin/supervisor_release(@id)?@id=1764685"}, ID: 1764685,
 			},
-			ProvisioningProgress:       nil,
-			DownloadProgress:           nil,
-			LogsChannel:                nil,
-			IsLockedUntil:              nil,
-			IsAccessibleBySupportUntil: nil,
-			MACAddress:                 "b8:27:eb:72:f9:5e b8:40:eb:27:ac:0b",
-			APIHeartbeatState:          "online",
-			MemoryUsage:                321,
-			MemoryTotal:                973,
-			StorageBlockDevice:         "/dev/mmcblk0p6",
-			StorageUsage:               191,
-			StorageTotal:               14138,
-			CPUTemp:                    63,
-			CPUUsage:                   34,
-			CPUID:                      "000000008e72f95e",
-			IsUndervolted:              false,
+			MACAddress:         "b8:27:eb:72:f9:5e b8:40:eb:27:ac:0b",
+			APIHeartbeatState:  APIHeartbeatStateOnline,
+			MemoryUsage:        321,
+			MemoryTotal:        973,
+			StorageBlockDevice: "/dev/mmcblk0p6",
+			StorageUsage:       191,
+			StorageTotal:       14138,
+			CPUTemp:            63,
+			CPUUsage:           34,
+			CPUID:              "000000008e72f95e",
+			IsUndervolted:      false,
 		},
 	}
 	// When
 	actual, err := client.Device.GetWithQuery(context.Background(), "%24filter=key+eq+%27value%27")
 	// Then
 	assert.NilError(t, err)
+	clearRaw(t, actual...)
 	assert.DeepEqual(t, expected, actual)
 }
 
@@ -709,3 +712,144 @@ func TestDeviceService_MoveToApplication_UUID(t *testing.T) {
 	assert.NilError(t, err)
 	assert.Equal(t, "OK", string(resp))
 }
+
+func TestDeviceService_Get_EscapesUUID(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/"+deviceBasePath, func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		expected := "%24filter=uuid+eq+%27x%27%27+or+uuid+ne+%27%27%27"
+		if r.URL.RawQuery != expected {
+			http.Error(w, fmt.Sprintf("query = %s ; expected %s", r.URL.RawQuery, expected), http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, `{"d":[]}`)
+	})
+	// When
+	actual, err := client.Device.Get(context.Background(), DeviceUUID("x' or uuid ne '"))
+	// Then
+	assert.NilError(t, err)
+	assert.Assert(t, actual == nil)
+}
+
+// clearRaw asserts that the devices retain the JSON they were decoded from, and clears it for comparing the devices
+// with expected devices.
+func clearRaw(t *testing.T, devices ...*DeviceResponse) {
+	t.Helper()
+	for _, device := range devices {
+		assert.Assert(t, len(device.Raw) > 0)
+		device.Raw = nil
+	}
+}
+
+func TestDeviceResponse_Raw(t *testing.T) {
+	// Given
+	var resp struct {
+		D []*DeviceResponse `json:"d"`
+	}
+	// When
+	err := json.Unmarshal([]byte(deviceResponse), &resp)
+	// Then
+	assert.NilError(t, err)
+	var raw struct {
+		ShouldBeOperatedByRelease odata.Object `json:"should_be_operated_by__release"`
+	}
+	assert.NilError(t, json.Unmarshal(resp.D[0].Raw, &raw))
+	assert.Equal(t, raw.ShouldBeOperatedByRelease.ID, int64(1781572))
+}
+
+func TestIPAddressList_UnmarshalJSON(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		input    string
+		expected IPAddressList
+	}{
+		{name: "null", input: `null`},
+		{name: "empty", input: `""`},
+		{
+			name:  "multiple",
+			input: `"10.0.0.2  172.17.0.1 fe80::1"`,
+			expected: IPAddressList{
+				IPs: []net.IP{net.ParseIP("10.0.0.2"), net.ParseIP("172.17.0.1"), net.ParseIP("fe80::1")},
+				Raw: "10.0.0.2  172.17.0.1 fe80::1",
+			},
+		},
+		{
+			name:  "zoned",
+			input: `"10.0.0.2 fe80::1%wlan0"`,
+			expected: IPAddressList{
+				IPs: []net.IP{net.ParseIP("10.0.0.2"), net.ParseIP("fe80::1")},
+				Raw: "10.0.0.2 fe80::1%wlan0",
+			},
+		},
+		{
+			name:     "invalid",
+			input:    `"12.345.95.246 10.0.0.2"`,
+			expected: IPAddressList{IPs: []net.IP{net.ParseIP("10.0.0.2")}, Raw: "12.345.95.246 10.0.0.2"},
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			var actual IPAddressList
+			// When
+			err := json.Unmarshal([]byte(tt.input), &actual)
+			// Then
+			assert.NilError(t, err)
+			assert.DeepEqual(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestIPAddressList_MarshalJSON(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		list     IPAddressList
+		expected string
+	}{
+		{name: "empty", expected: `null`},
+		{name: "raw", list: IPAddressList{Raw: "fe80::1%wlan0 bad"}, expected: `"fe80::1%wlan0 bad"`},
+		{
+			name:     "parsed",
+			list:     IPAddressList{IPs: []net.IP{net.ParseIP("10.0.0.2"), net.ParseIP("fe80::1")}},
+			expected: `"10.0.0.2 fe80::1"`,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// When
+			b, err := json.Marshal(tt.list)
+			// Then
+			assert.NilError(t, err)
+			assert.Equal(t, string(b), tt.expected)
+		})
+	}
+}
+
+func TestDeviceResponse_UnmarshalJSON_Timestamps(t *testing.T) {
+	// Given
+	input := `{"id":1,"created_at":"","last_connectivity_event":null,"last_vpn_event":"",` +
+		`"is_locked_until__date":"2021-01-02T03:04:05Z"}`
+	// When
+	var device DeviceResponse
+	err := json.Unmarshal([]byte(input), &device)
+	// Then
+	assert.NilError(t, err)
+	assert.Assert(t, device.CreatedAt.IsZero())
+	assert.Assert(t, device.LastConnectivityEvent == nil)
+	assert.Assert(t, device.LastVPNEvent == nil)
+	assert.Assert(t, device.IsAccessibleBySupportUntil == nil)
+	assert.Assert(t, device.IsLockedUntil.Equal(time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)))
+}
+
+func TestDeviceResponse_MarshalJSON_OmitsUnsetTimestamps(t *testing.T) {
+	// Given
+	device := DeviceResponse{ID: 1}
+	// When
+	b, err := json.Marshal(&device)
+	// Then
+	assert.NilError(t, err)
+	assert.Assert(t, !strings.Contains(string(b), "is_locked_until__date"))
+	assert.Assert(t, !strings.Contains(string(b), "last_connectivity_event"))
+}
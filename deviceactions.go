@@ -0,0 +1,117 @@
+package balena
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+const (
+	// supervisorProxyV1BasePath is the path of the cloud proxy to the supervisor v1 API of devices.
+	supervisorProxyV1BasePath = "supervisor/v1"
+	// deviceV2BasePath is the path of the device actions of the cloud API, addressed by device UUID.
+	deviceV2BasePath = "device/v2"
+)
+
+// RestartApplication restarts all services of the application running on the device with given ID/UUID.
+// If force is set, the update lock of the device is overridden, otherwise an error wrapping ErrLocked is
+// returned if the device holds an update lock.
+func (s *DeviceService) RestartApplication(ctx context.Context, deviceID IDOrUUID, force bool) error {
+	return s.doDeviceV2Action(ctx, deviceID, "restart-application", force)
+}
+
+// Reboot reboots the device with given ID/UUID.
+// If force is set, the update lock of the device is overridden, otherwise an error wrapping ErrLocked is
+// returned if the device holds an update lock.
+func (s *DeviceService) Reboot(ctx context.Context, deviceID IDOrUUID, force bool) error {
+	return s.doSupervisorAction(ctx, deviceID, "reboot", force)
+}
+
+// Shutdown shuts down the device with given ID/UUID. The device stays offline until it is powered on again.
+// If force is set, the update lock of the device is overridden, otherwise an error wrapping ErrLocked is
+// returned if the device holds an update lock.
+func (s *DeviceService) Shutdown(ctx context.Context, deviceID IDOrUUID, force bool) error {
+	return s.doSupervisorAction(ctx, deviceID, "shutdown", force)
+}
+
+// PurgeData deletes the data in the persistent storage of the application on the device with given ID/UUID, and
+// restarts its services. If force is set, the update lock of the device is overridden, otherwise an error
+// wrapping ErrLocked is returned if the device holds an update lock.
+func (s *DeviceService) PurgeData(ctx context.Context, deviceID IDOrUUID, force bool) error {
+	return s.doDeviceV2Action(ctx, deviceID, "purge", force)
+}
+
+// Identify blinks the identification LED of the device with given ID/UUID.
+func (s *DeviceService) Identify(ctx context.Context, deviceID IDOrUUID) error {
+	return s.doSupervisorAction(ctx, deviceID, "blink", false)
+}
+
+// doDeviceV2Action performs an action of the device v2 endpoints of the cloud API on the device with given
+// ID/UUID. An error wrapping ErrNotFound is returned if the device does not exist.
+func (s *DeviceService) doDeviceV2Action(ctx context.Context, deviceID IDOrUUID, action string, force bool) error {
+	uuid, err := s.resolveUUID(ctx, deviceID)
+	if err != nil {
+		return fmt.Errorf("unable to %s device: %w", action, err)
+	}
+	type request struct {
+		Force bool `json:"force"`
+	}
+	var body interface{}
+	if force {
+		body = &request{Force: force}
+	}
+	path := deviceV2BasePath + "/" + url.PathEscape(uuid) + "/" + action
+	req, err := s.client.NewRequest(ctx, http.MethodPost, path, "", body)
+	if err != nil {
+		return fmt.Errorf("unable to create %s request: %w", action, err)
+	}
+	buf := &bytes.Buffer{}
+	if err := s.client.Do(req, buf); err != nil {
+		return fmt.Errorf("unable to %s device %s: %w", action, uuid, err)
+	}
+	return nil
+}
+
+// doSupervisorAction performs an action of the supervisor v1 API on the device with given ID/UUID through the
+// cloud proxy, for actions without a device v2 endpoint. An error wrapping ErrNotFound is returned if the device
+// does not exist.
+func (s *DeviceService) doSupervisorAction(ctx context.Context, deviceID IDOrUUID, action string, force bool) error {
+	uuid, err := s.resolveUUID(ctx, deviceID)
+	if err != nil {
+		return fmt.Errorf("unable to %s device: %w", action, err)
+	}
+	type data struct {
+		Force bool `json:"force,omitempty"`
+	}
+	body := &CloudRequest{UUID: uuid, Method: http.MethodPost}
+	if force {
+		body.Data = &data{Force: force}
+	}
+	req, err := s.client.NewRequest(ctx, http.MethodPost, supervisorProxyV1BasePath+"/"+action, "", body)
+	if err != nil {
+		return fmt.Errorf("unable to create %s request: %w", action, err)
+	}
+	buf := &bytes.Buffer{}
+	if err := s.client.Do(req, buf); err != nil {
+		return fmt.Errorf("unable to %s device %s: %w", action, uuid, err)
+	}
+	return nil
+}
+
+// resolveUUID returns the UUID of the device with given ID/UUID, looking it up by ID if needed.
+// An error wrapping ErrNotFound is returned if no device has the ID.
+func (s *DeviceService) resolveUUID(ctx context.Context, deviceID IDOrUUID) (string, error) {
+	if deviceID.isUUID {
+		return deviceID.id, nil
+	}
+	device, err := s.Get(ctx, deviceID)
+	if err != nil {
+		return "", err
+	}
+	if device == nil {
+		return "", fmt.Errorf("device %s: %w", deviceID.id, ErrNotFound)
+	}
+	return device.UUID, nil
+}
@@ -0,0 +1,80 @@
+package balena
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestDeviceService_Reboot_UUID(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/"+supervisorProxyV1BasePath+"/reboot", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodPost)
+		b, err := io.ReadAll(r.Body)
+		assert.NilError(t, err)
+		assert.Equal(t, string(b), `{"uuid":"abc","method":"POST","data":{"force":true}}`+"\n")
+		fmt.Fprint(w, "OK")
+	})
+	// When
+	err := client.Device.Reboot(context.Background(), DeviceUUID("abc"), true)
+	// Then
+	assert.NilError(t, err)
+}
+
+func TestDeviceService_RestartApplication_ID(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/"+deviceBasePath+"(123)", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		fmt.Fprint(w, `{"d":[{"id":123,"uuid":"abc","belongs_to__application":{"__id":7}}]}`)
+	})
+	mux.HandleFunc("/"+deviceV2BasePath+"/abc/restart-application", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodPost)
+		b, err := io.ReadAll(r.Body)
+		assert.NilError(t, err)
+		assert.Equal(t, string(b), "")
+		http.Error(w, "Updates are locked", http.StatusLocked)
+	})
+	// When
+	err := client.Device.RestartApplication(context.Background(), DeviceID(123), false)
+	// Then
+	assert.Assert(t, errors.Is(err, ErrLocked))
+}
+
+func TestDeviceService_PurgeData_UUID(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/"+deviceV2BasePath+"/abc/purge", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodPost)
+		b, err := io.ReadAll(r.Body)
+		assert.NilError(t, err)
+		assert.Equal(t, string(b), `{"force":true}`+"\n")
+		fmt.Fprint(w, "OK")
+	})
+	// When
+	err := client.Device.PurgeData(context.Background(), DeviceUUID("abc"), true)
+	// Then
+	assert.NilError(t, err)
+}
+
+func TestDeviceService_Identify_UnknownDevice(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/"+deviceBasePath+"(123)", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"d":[]}`)
+	})
+	// When
+	err := client.Device.Identify(context.Background(), DeviceID(123))
+	// Then
+	assert.Assert(t, errors.Is(err, ErrNotFound))
+}
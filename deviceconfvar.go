@@ -23,23 +23,35 @@ type DeviceConfVarResponse struct {
 
 // List lists all environment variables given a specific device ID/UUID.
 func (s *DeviceConfVarService) List(ctx context.Context, deviceID IDOrUUID) ([]*DeviceConfVarResponse, error) {
-	query := "%24filter=device+eq+%27" + deviceID.id + "%27"
-	if deviceID.isUUID {
-		query = "%24filter=device/uuid+eq+%27" + deviceID.id + "%27"
-	}
-	req, err := s.client.NewRequest(ctx, http.MethodGet, deviceConfVarBasePath, query, nil)
+	query, err := filterQuery(deviceID.filter("device", "device/uuid"))
 	if err != nil {
-		return nil, fmt.Errorf("unable to create request: %v", err)
+		return nil, fmt.Errorf("unable to create request: %w", err)
 	}
-	type Response struct {
-		D []*DeviceConfVarResponse `json:"d,omitempty"`
+	return s.getWithQuery(ctx, query)
+}
+
+// Get retrieves the variable with the given name from the device with given ID/UUID.
+// If no variable with such name exists, both the response and error are nil.
+func (s *DeviceConfVarService) Get(
+	ctx context.Context,
+	deviceID IDOrUUID,
+	name string,
+) (*DeviceConfVarResponse, error) {
+	query, err := filterQuery(odata.And(deviceID.filter("device", "device/uuid"), odata.Eq("name", name)))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
 	}
-	resp := &Response{}
-	err = s.client.Do(req, resp)
+	resp, err := s.getWithQuery(ctx, query)
 	if err != nil {
-		return nil, fmt.Errorf("unable to perform request: %v", err)
+		return nil, err
 	}
-	return resp.D, nil
+	if len(resp) > 1 {
+		return nil, fmt.Errorf("expected 1 variable but got %d", len(resp))
+	}
+	if len(resp) == 0 {
+		return nil, nil
+	}
+	return resp[0], nil
 }
 
 // Create creates an environment variable with name=value given a device ID/UUID.
@@ -56,6 +68,9 @@ func (s *DeviceConfVarService) Create(
 		if err != nil {
 			return nil, err
 		}
+		if resp == nil {
+			return nil, fmt.Errorf("device %s: %w", deviceID.id, ErrNotFound)
+		}
 		id = strconv.FormatInt(resp.ID, 10)
 	}
 	type request struct {
@@ -69,32 +84,116 @@ func (s *DeviceConfVarService) Create(
 		Value:    value,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("unable to create request: %v", err)
+		return nil, fmt.Errorf("unable to create request: %w", err)
 	}
 	resp := &DeviceConfVarResponse{}
 	err = s.client.Do(req, resp)
 	if err != nil {
-		return nil, fmt.Errorf("unable to perform request: %v", err)
+		return nil, fmt.Errorf("unable to perform request: %w", err)
 	}
 	return resp, nil
 }
 
+// Update a variable with the given name from the device with given ID/UUID to the specified new value.
+// No error is returned if no variable with such name exists, unless the client uses WithStrictMatching.
+func (s *DeviceConfVarService) Update(ctx context.Context, deviceID IDOrUUID, name, newValue string) error {
+	_, err := s.update(ctx, deviceID, name, newValue, false)
+	return err
+}
+
+// UpdateCount is like Update, but returns the number of updated variables, which is zero if no variable with such
+// name exists. WithStrictMatching does not apply.
+func (s *DeviceConfVarService) UpdateCount(ctx context.Context, deviceID IDOrUUID, name, newValue string) (int, error) {
+	return s.update(ctx, deviceID, name, newValue, true)
+}
+
+func (s *DeviceConfVarService) update(
+	ctx context.Context,
+	deviceID IDOrUUID,
+	name string,
+	newValue string,
+	count bool,
+) (int, error) {
+	body := struct {
+		Value string `json:"value"`
+	}{Value: newValue}
+	filter := odata.And(deviceID.filter("device", "device/uuid"), odata.Eq("name", name))
+	return s.client.doFilterRequest(ctx, http.MethodPatch, deviceConfVarBasePath, filter, body, count)
+}
+
+// Upsert sets the variable with the given name of the device with given ID/UUID to value, creating it if it does
+// not exist.
+func (s *DeviceConfVarService) Upsert(
+	ctx context.Context,
+	deviceID IDOrUUID,
+	name string,
+	value string,
+) (*DeviceConfVarResponse, error) {
+	v, err := s.client.upsertVariable(
+		ctx,
+		deviceConfVarBasePath,
+		name,
+		value,
+		func() (variable, error) {
+			existing, err := s.Get(ctx, deviceID, name)
+			if existing == nil {
+				return nil, err
+			}
+			return existing, err
+		},
+		func() (variable, error) {
+			created, err := s.Create(ctx, deviceID, name, value)
+			if created == nil {
+				return nil, err
+			}
+			return created, err
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return v.(*DeviceConfVarResponse), nil
+}
+
 // DeleteWithName deletes a variable with the given name from the device with given ID/UUID.
-// No error is returned if no variable with such name exists.
+// No error is returned if no variable with such name exists, unless the client uses WithStrictMatching.
 func (s *DeviceConfVarService) DeleteWithName(ctx context.Context, deviceID IDOrUUID, name string) error {
-	// Get the variable ID
-	query := "%24filter=device+eq+%27" + deviceID.id + "%27"
-	if deviceID.isUUID {
-		query = "%24filter=device/uuid+eq+%27" + deviceID.id + "%27"
-	}
-	query = query + "+and+name+eq+%27" + name + "%27"
-	req, err := s.client.NewRequest(ctx, http.MethodDelete, deviceConfVarBasePath, query, nil)
+	_, err := s.deleteWithName(ctx, deviceID, name, false)
+	return err
+}
+
+// DeleteWithNameCount is like DeleteWithName, but returns the number of deleted variables, which is zero if no
+// variable with such name exists. WithStrictMatching does not apply.
+func (s *DeviceConfVarService) DeleteWithNameCount(ctx context.Context, deviceID IDOrUUID, name string) (int, error) {
+	return s.deleteWithName(ctx, deviceID, name, true)
+}
+
+func (s *DeviceConfVarService) deleteWithName(
+	ctx context.Context,
+	deviceID IDOrUUID,
+	name string,
+	count bool,
+) (int, error) {
+	filter := odata.And(deviceID.filter("device", "device/uuid"), odata.Eq("name", name))
+	return s.client.doFilterRequest(ctx, http.MethodDelete, deviceConfVarBasePath, filter, nil, count)
+}
+
+func (s *DeviceConfVarService) getWithQuery(ctx context.Context, query string) ([]*DeviceConfVarResponse, error) {
+	req, err := s.client.NewRequest(ctx, http.MethodGet, deviceConfVarBasePath, query, nil)
 	if err != nil {
-		return fmt.Errorf("unable to create request: %v", err)
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+	type Response struct {
+		D []*DeviceConfVarResponse `json:"d,omitempty"`
 	}
-	err = s.client.Do(req, nil)
+	resp := &Response{}
+	err = s.client.Do(req, resp)
 	if err != nil {
-		return fmt.Errorf("unable to perform request: %v", err)
+		return nil, fmt.Errorf("unable to perform request: %w", err)
 	}
-	return nil
+	return resp.D, nil
+}
+
+func (v *DeviceConfVarResponse) variableFields() (int64, *string) {
+	return v.ID, &v.Value
 }
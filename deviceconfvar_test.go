@@ -338,3 +338,26 @@ func TestDeviceConfVarService_DeleteWithName_NotFound(t *testing.T) {
 	// Then
 	assert.NilError(t, err)
 }
+
+func TestDeviceConfVarService_Upsert_Update(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/"+deviceConfVarBasePath, func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		assert.Equal(t, r.URL.RawQuery, "%24filter=device+eq+%27123%27+and+name+eq+%27BALENA_KEY%27")
+		fmt.Fprint(w, `{"d":[{"id":1,"name":"BALENA_KEY","value":"old"}]}`)
+	})
+	mux.HandleFunc("/"+deviceConfVarBasePath+"(1)", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodPatch)
+		b, err := io.ReadAll(r.Body)
+		assert.NilError(t, err)
+		assert.Equal(t, string(b), `{"value":"new"}`+"\n")
+		fmt.Fprint(w, "OK")
+	})
+	// When
+	actual, err := client.DeviceConfVar.Upsert(context.Background(), DeviceID(123), "BALENA_KEY", "new")
+	// Then
+	assert.NilError(t, err)
+	assert.DeepEqual(t, actual, &DeviceConfVarResponse{ID: 1, Name: "BALENA_KEY", Value: "new"})
+}
@@ -0,0 +1,78 @@
+package balena
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"go.einride.tech/balena/odata"
+)
+
+// ErrUnfilteredDelete is returned by DeviceService.DeleteWithFilter when given an empty filter, which would
+// delete every device accessible to the client.
+var ErrUnfilteredDelete = errors.New("refusing to delete devices without a filter")
+
+// DeleteDevicesOptions configures DeviceService.DeleteWithFilter.
+type DeleteDevicesOptions struct {
+	// DryRun lists the devices that would be deleted without deleting them.
+	DryRun bool
+}
+
+// Delete deletes the device with given ID/UUID, along with its variables, tags and service installs.
+// An error wrapping ErrNotFound is returned if no device has the given UUID, or if the API responds that no device
+// has the given ID.
+func (s *DeviceService) Delete(ctx context.Context, deviceID IDOrUUID) error {
+	if err := s.doDeviceRequest(ctx, http.MethodDelete, deviceID, nil); err != nil {
+		return fmt.Errorf("unable to delete device %s: %w", deviceID.id, err)
+	}
+	return nil
+}
+
+// Deactivate deactivates the device with given ID/UUID by marking it inactive, without deleting it.
+// An error wrapping ErrNotFound is returned if no device has the given UUID, or if the API responds that no device
+// has the given ID.
+func (s *DeviceService) Deactivate(ctx context.Context, deviceID IDOrUUID) error {
+	body := struct {
+		IsActive bool `json:"is_active"`
+	}{IsActive: false}
+	if err := s.doDeviceRequest(ctx, http.MethodPatch, deviceID, body); err != nil {
+		return fmt.Errorf("unable to deactivate device %s: %w", deviceID.id, err)
+	}
+	return nil
+}
+
+// DeleteWithFilter deletes all devices matching filter, and returns them. With opts.DryRun, the devices that
+// would be deleted are returned without deleting them. An error wrapping ErrUnfilteredDelete is returned if the
+// filter is empty.
+//
+// The matching devices are listed first and then deleted by ID, so devices that start matching the filter
+// concurrently are not deleted. If deleting fails, the devices deleted so far are returned along with the error.
+func (s *DeviceService) DeleteWithFilter(
+	ctx context.Context,
+	filter odata.Filter,
+	opts DeleteDevicesOptions,
+) ([]*DeviceResponse, error) {
+	if filter.IsZero() {
+		return nil, fmt.Errorf("unable to delete devices: %w", ErrUnfilteredDelete)
+	}
+	query, err := filterQuery(filter)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+	devices, err := s.GetWithQuery(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list devices to delete: %w", err)
+	}
+	if opts.DryRun {
+		return devices, nil
+	}
+	ids := make([]int64, 0, len(devices))
+	for _, device := range devices {
+		ids = append(ids, device.ID)
+	}
+	if n, err := s.client.doIDsRequest(ctx, http.MethodDelete, deviceBasePath, ids, nil); err != nil {
+		return devices[:n], fmt.Errorf("unable to delete devices: %w", err)
+	}
+	return devices, nil
+}
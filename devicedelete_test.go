@@ -0,0 +1,152 @@
+package balena
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"go.einride.tech/balena/odata"
+	"gotest.tools/v3/assert"
+)
+
+func TestDeviceService_Delete(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	var methods []string
+	mux.HandleFunc("/"+deviceBasePath, func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		switch r.Method {
+		case http.MethodGet:
+			assert.Equal(t, r.URL.RawQuery, "%24select=id&%24filter=uuid+eq+%27abc%27")
+			fmt.Fprint(w, `{"d":[{"id":1}]}`)
+		case http.MethodDelete:
+			assert.Equal(t, r.URL.RawQuery, "%24filter=id+eq+1")
+			fmt.Fprint(w, "OK")
+		}
+	})
+	// When
+	err := client.Device.Delete(context.Background(), DeviceUUID("abc"))
+	// Then
+	assert.NilError(t, err)
+	assert.DeepEqual(t, methods, []string{http.MethodGet, http.MethodDelete})
+}
+
+func TestDeviceService_Delete_NotFound(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/"+deviceBasePath, func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		fmt.Fprint(w, `{"d":[]}`)
+	})
+	// When
+	err := client.Device.Delete(context.Background(), DeviceUUID("abc"))
+	// Then
+	assert.Assert(t, errors.Is(err, ErrNotFound))
+}
+
+func TestDeviceService_Delete_ID(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	var methods []string
+	mux.HandleFunc("/"+deviceBasePath+"(1)", func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		fmt.Fprint(w, "OK")
+	})
+	// When
+	err := client.Device.Delete(context.Background(), DeviceID(1))
+	// Then
+	assert.NilError(t, err)
+	assert.DeepEqual(t, methods, []string{http.MethodDelete})
+}
+
+func TestDeviceService_Deactivate(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/"+deviceBasePath+"(1)", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodPatch)
+		b, err := io.ReadAll(r.Body)
+		assert.NilError(t, err)
+		assert.Equal(t, string(b), `{"is_active":false}`+"\n")
+		fmt.Fprint(w, "OK")
+	})
+	// When
+	err := client.Device.Deactivate(context.Background(), DeviceID(1))
+	// Then
+	assert.NilError(t, err)
+}
+
+func TestDeviceService_Deactivate_NotFound(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/"+deviceBasePath, func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		fmt.Fprint(w, `{"d":[]}`)
+	})
+	// When
+	err := client.Device.Deactivate(context.Background(), DeviceUUID("abc"))
+	// Then
+	assert.Assert(t, errors.Is(err, ErrNotFound))
+}
+
+func TestDeviceService_DeleteWithFilter(t *testing.T) {
+	for _, tt := range []struct {
+		name            string
+		opts            DeleteDevicesOptions
+		expectedMethods []string
+	}{
+		{
+			name:            "delete",
+			expectedMethods: []string{http.MethodGet, http.MethodDelete},
+		},
+		{
+			name:            "dry run",
+			opts:            DeleteDevicesOptions{DryRun: true},
+			expectedMethods: []string{http.MethodGet},
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			client, mux, cleanup := newFixture()
+			defer cleanup()
+			var methods []string
+			mux.HandleFunc("/"+deviceBasePath, func(w http.ResponseWriter, r *http.Request) {
+				methods = append(methods, r.Method)
+				switch r.Method {
+				case http.MethodGet:
+					assert.Equal(t, r.URL.RawQuery, "%24filter=is_online+eq+false")
+					fmt.Fprint(w, `{"d":[{"id":1,"uuid":"a"},{"id":2,"uuid":"b"}]}`)
+				case http.MethodDelete:
+					assert.Equal(t, r.URL.RawQuery, "%24filter=id+eq+1+or+id+eq+2")
+					fmt.Fprint(w, "OK")
+				}
+			})
+			// When
+			devices, err := client.Device.DeleteWithFilter(context.Background(), odata.Eq("is_online", false), tt.opts)
+			// Then
+			assert.NilError(t, err)
+			assert.Equal(t, len(devices), 2)
+			assert.Equal(t, devices[0].UUID, "a")
+			assert.Equal(t, devices[1].UUID, "b")
+			assert.DeepEqual(t, methods, tt.expectedMethods)
+		})
+	}
+}
+
+func TestDeviceService_DeleteWithFilter_Unfiltered(t *testing.T) {
+	// Given
+	client, _, cleanup := newFixture()
+	defer cleanup()
+	// When
+	_, err := client.Device.DeleteWithFilter(context.Background(), odata.And(), DeleteDevicesOptions{})
+	// Then
+	assert.Assert(t, errors.Is(err, ErrUnfilteredDelete))
+}
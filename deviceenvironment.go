@@ -0,0 +1,171 @@
+package balena
+
+import (
+	"context"
+	"fmt"
+)
+
+// EnvVarSource identifies where a variable of the environment of a device service is set.
+// Sources are ordered by precedence, so that variables from later sources override earlier ones.
+type EnvVarSource int
+
+const (
+	// EnvVarSourceApplication is a variable set for all services of an application, see ApplicationEnvVarService.
+	EnvVarSourceApplication EnvVarSource = iota + 1
+	// EnvVarSourceService is a variable set for a service of an application, see ServiceEnvVarService.
+	EnvVarSourceService
+	// EnvVarSourceDevice is a variable set for all services of a device, see DeviceEnvVarService.
+	EnvVarSourceDevice
+	// EnvVarSourceDeviceService is a variable set for a service of a device, see DeviceServVarService.
+	EnvVarSourceDeviceService
+)
+
+// String returns the name of the source.
+func (s EnvVarSource) String() string {
+	switch s {
+	case EnvVarSourceApplication:
+		return "application"
+	case EnvVarSourceService:
+		return "service"
+	case EnvVarSourceDevice:
+		return "device"
+	case EnvVarSourceDeviceService:
+		return "device service"
+	}
+	return fmt.Sprintf("EnvVarSource(%d)", int(s))
+}
+
+// EnvVarValue is the value of a variable from one source.
+type EnvVarValue struct {
+	Value  string
+	Source EnvVarSource
+	// ID is the ID of the variable in its source, e.g. of the device environment variable.
+	ID int64
+}
+
+// EffectiveEnvVar is a variable of the environment of a device service.
+type EffectiveEnvVar struct {
+	Name string
+	// EnvVarValue is the value seen by the service, and where it is set.
+	EnvVarValue
+	// Overridden are the values from sources with lower precedence, in order of precedence.
+	Overridden []EnvVarValue
+}
+
+// DeviceEnvironment is the effective environment of the services of a device.
+type DeviceEnvironment struct {
+	DeviceID      int64
+	ApplicationID int64
+	// Services maps the names of the services installed on the device to their environment, keyed by
+	// variable name.
+	Services map[string]map[string]*EffectiveEnvVar
+}
+
+// EffectiveEnvironment returns the environment of each service on the device with given ID/UUID, combining
+// application, service, device and device service environment variables in order of increasing precedence.
+// An error wrapping ErrNotFound is returned if the device does not exist.
+func (s *DeviceService) EffectiveEnvironment(ctx context.Context, deviceID IDOrUUID) (*DeviceEnvironment, error) {
+	device, err := s.Get(ctx, deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get device: %w", err)
+	}
+	if device == nil {
+		return nil, fmt.Errorf("device %s: %w", deviceID.id, ErrNotFound)
+	}
+	if device.BelongsToApplication == nil {
+		return nil, fmt.Errorf("device %s does not belong to an application", deviceID.id)
+	}
+	env := &DeviceEnvironment{
+		DeviceID:      device.ID,
+		ApplicationID: device.BelongsToApplication.ID,
+		Services:      map[string]map[string]*EffectiveEnvVar{},
+	}
+	id := DeviceID(device.ID)
+	installs, err := s.client.ServiceInstall.List(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list service installs: %w", err)
+	}
+	for _, name := range installs.ServiceNames() {
+		env.Services[name] = map[string]*EffectiveEnvVar{}
+	}
+	applicationID := ApplicationID(env.ApplicationID)
+	applicationEnvVars, err := s.client.ApplicationEnvVar.List(ctx, applicationID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list application environment variables: %w", err)
+	}
+	for _, v := range applicationEnvVars {
+		env.set("", v.Name, EnvVarValue{Value: v.Value, Source: EnvVarSourceApplication, ID: v.ID})
+	}
+	serviceEnvVars, err := s.client.ServiceEnvVar.listByApplication(ctx, applicationID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list service environment variables: %w", err)
+	}
+	for serviceName, vars := range serviceEnvVars {
+		for _, v := range vars {
+			env.set(serviceName, v.Name, EnvVarValue{Value: v.Value, Source: EnvVarSourceService, ID: v.ID})
+		}
+	}
+	deviceEnvVars, err := s.client.DeviceEnvVar.List(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list device environment variables: %w", err)
+	}
+	for _, v := range deviceEnvVars {
+		env.set("", v.Name, EnvVarValue{Value: v.Value, Source: EnvVarSourceDevice, ID: v.ID})
+	}
+	deviceServVars, err := s.client.DeviceServVar.List(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list device service environment variables: %w", err)
+	}
+	for _, v := range deviceServVars {
+		for _, serviceName := range v.ServiceInstall.ServiceNames() {
+			env.set(serviceName, v.Name, EnvVarValue{Value: v.Value, Source: EnvVarSourceDeviceService, ID: v.ID})
+		}
+	}
+	return env, nil
+}
+
+// set sets a variable of the named service, or of all services if serviceName is empty, overriding any
+// existing value. Services not installed on the device are ignored.
+func (e *DeviceEnvironment) set(serviceName, name string, value EnvVarValue) {
+	for service, vars := range e.Services {
+		if serviceName != "" && service != serviceName {
+			continue
+		}
+		existing, ok := vars[name]
+		if !ok {
+			vars[name] = &EffectiveEnvVar{Name: name, EnvVarValue: value}
+			continue
+		}
+		existing.Overridden = append(existing.Overridden, existing.EnvVarValue)
+		existing.EnvVarValue = value
+	}
+}
+
+// listByApplication lists the environment variables of all services of the application with given ID/slug,
+// keyed by service name.
+func (s *ServiceEnvVarService) listByApplication(
+	ctx context.Context,
+	applicationID IDOrSlug,
+) (map[string][]*ServiceEnvVarResponse, error) {
+	serviceNames, err := s.listServiceNames(ctx, applicationID)
+	if err != nil {
+		return nil, err
+	}
+	query, err := filterQuery(applicationID.filter("service/application", "service/application/slug"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+	vars, err := s.getWithQuery(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string][]*ServiceEnvVarResponse{}
+	for _, v := range vars {
+		serviceName, ok := serviceNames[v.Service.ID]
+		if !ok {
+			return nil, fmt.Errorf("service %d of variable %s: %w", v.Service.ID, v.Name, ErrNotFound)
+		}
+		result[serviceName] = append(result[serviceName], v)
+	}
+	return result, nil
+}
@@ -0,0 +1,110 @@
+package balena
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestDeviceService_EffectiveEnvironment(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	respond := func(path, expectedQuery, body string) {
+		mux.HandleFunc("/"+path, func(w http.ResponseWriter, r *http.Request) {
+			testMethod(t, r, http.MethodGet)
+			if expectedQuery != "" {
+				assert.Equal(t, r.URL.RawQuery, expectedQuery)
+			}
+			fmt.Fprint(w, body)
+		})
+	}
+	respond(deviceBasePath, "%24filter=uuid+eq+%27abc%27", `{"d":[{"id":1,"belongs_to__application":{"__id":123}}]}`)
+	respond(serviceInstallBasePath, "", `{"d":[
+		{"id":11,"installs__service":[{"id":7,"service_name":"main"}]},
+		{"id":12,"installs__service":[{"id":8,"service_name":"sidecar"}]}
+	]}`)
+	respond(applicationEnvVarBasePath, "%24filter=application+eq+%27123%27", `{"d":[
+		{"id":21,"name":"A","value":"app"},
+		{"id":22,"name":"B","value":"app"}
+	]}`)
+	respond(serviceBasePath, "%24select=id,service_name&%24filter=application+eq+%27123%27", `{"d":[
+		{"id":7,"service_name":"main"},
+		{"id":8,"service_name":"sidecar"}
+	]}`)
+	respond(serviceEnvVarBasePath, "%24filter=service/application+eq+%27123%27", `{"d":[
+		{"id":31,"service":{"__id":7},"name":"A","value":"service"}
+	]}`)
+	respond(deviceEnvVarBasePath, "%24filter=device+eq+%271%27", `{"d":[{"id":41,"name":"A","value":"device"}]}`)
+	respond(deviceServVarBasePath, "", `{"d":[
+		{"id":51,"service_install":[{"id":12,"installs__service":[{"id":8,"service_name":"sidecar"}]}],
+		 "name":"B","value":"device service"}
+	]}`)
+	// When
+	actual, err := client.Device.EffectiveEnvironment(context.Background(), DeviceUUID("abc"))
+	// Then
+	assert.NilError(t, err)
+	assert.DeepEqual(t, actual, &DeviceEnvironment{
+		DeviceID:      1,
+		ApplicationID: 123,
+		Services: map[string]map[string]*EffectiveEnvVar{
+			"main": {
+				"A": {
+					Name:        "A",
+					EnvVarValue: EnvVarValue{Value: "device", Source: EnvVarSourceDevice, ID: 41},
+					Overridden: []EnvVarValue{
+						{Value: "app", Source: EnvVarSourceApplication, ID: 21},
+						{Value: "service", Source: EnvVarSourceService, ID: 31},
+					},
+				},
+				"B": {Name: "B", EnvVarValue: EnvVarValue{Value: "app", Source: EnvVarSourceApplication, ID: 22}},
+			},
+			"sidecar": {
+				"A": {
+					Name:        "A",
+					EnvVarValue: EnvVarValue{Value: "device", Source: EnvVarSourceDevice, ID: 41},
+					Overridden:  []EnvVarValue{{Value: "app", Source: EnvVarSourceApplication, ID: 21}},
+				},
+				"B": {
+					Name:        "B",
+					EnvVarValue: EnvVarValue{Value: "device service", Source: EnvVarSourceDeviceService, ID: 51},
+					Overridden:  []EnvVarValue{{Value: "app", Source: EnvVarSourceApplication, ID: 22}},
+				},
+			},
+		},
+	})
+}
+
+func TestDeviceService_EffectiveEnvironment_NotFound(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/"+deviceBasePath, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"d":[]}`)
+	})
+	// When
+	_, err := client.Device.EffectiveEnvironment(context.Background(), DeviceUUID("missing"))
+	// Then
+	assert.Assert(t, errors.Is(err, ErrNotFound))
+}
+
+func TestServiceEnvVarService_listByApplication_UnknownService(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/"+serviceBasePath, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"d":[{"id":7,"service_name":"main"}]}`)
+	})
+	mux.HandleFunc("/"+serviceEnvVarBasePath, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"d":[{"id":31,"service":{"__id":8},"name":"A","value":"service"}]}`)
+	})
+	// When
+	_, err := client.ServiceEnvVar.listByApplication(context.Background(), ApplicationID(123))
+	// Then
+	assert.Assert(t, errors.Is(err, ErrNotFound))
+	assert.ErrorContains(t, err, "service 8 of variable A")
+}
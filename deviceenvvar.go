@@ -23,23 +23,31 @@ type DeviceEnvVarResponse struct {
 
 // List lists all environment variables given a specific device ID/UUID.
 func (s *DeviceEnvVarService) List(ctx context.Context, deviceID IDOrUUID) ([]*DeviceEnvVarResponse, error) {
-	query := "%24filter=device+eq+%27" + deviceID.id + "%27"
-	if deviceID.isUUID {
-		query = "%24filter=device/uuid+eq+%27" + deviceID.id + "%27"
-	}
-	req, err := s.client.NewRequest(ctx, http.MethodGet, deviceEnvVarBasePath, query, nil)
+	query, err := filterQuery(deviceID.filter("device", "device/uuid"))
 	if err != nil {
-		return nil, fmt.Errorf("unable to create request: %v", err)
+		return nil, fmt.Errorf("unable to create request: %w", err)
 	}
-	type Response struct {
-		D []*DeviceEnvVarResponse `json:"d,omitempty"`
+	return s.getWithQuery(ctx, query)
+}
+
+// Get retrieves the variable with the given name from the device with given ID/UUID.
+// If no variable with such name exists, both the response and error are nil.
+func (s *DeviceEnvVarService) Get(ctx context.Context, deviceID IDOrUUID, name string) (*DeviceEnvVarResponse, error) {
+	query, err := filterQuery(odata.And(deviceID.filter("device", "device/uuid"), odata.Eq("name", name)))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
 	}
-	resp := &Response{}
-	err = s.client.Do(req, resp)
+	resp, err := s.getWithQuery(ctx, query)
 	if err != nil {
-		return nil, fmt.Errorf("unable to perform request: %v", err)
+		return nil, err
 	}
-	return resp.D, nil
+	if len(resp) > 1 {
+		return nil, fmt.Errorf("expected 1 variable but got %d", len(resp))
+	}
+	if len(resp) == 0 {
+		return nil, nil
+	}
+	return resp[0], nil
 }
 
 // Create creates an environment variable with name=value given a device ID/UUID.
@@ -56,6 +64,9 @@ func (s *DeviceEnvVarService) Create(
 		if err != nil {
 			return nil, err
 		}
+		if resp == nil {
+			return nil, fmt.Errorf("device %s: %w", deviceID.id, ErrNotFound)
+		}
 		id = strconv.FormatInt(resp.ID, 10)
 	}
 	type request struct {
@@ -69,54 +80,116 @@ func (s *DeviceEnvVarService) Create(
 		Value:    value,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("unable to create request: %v", err)
+		return nil, fmt.Errorf("unable to create request: %w", err)
 	}
 	resp := &DeviceEnvVarResponse{}
 	err = s.client.Do(req, resp)
 	if err != nil {
-		return nil, fmt.Errorf("unable to perform request: %v", err)
+		return nil, fmt.Errorf("unable to perform request: %w", err)
 	}
 	return resp, nil
 }
 
 // Update a variable with the given name from the device with given ID/UUID to the specified new value.
-// No error is returned if no variable with such name exists.
+// No error is returned if no variable with such name exists, unless the client uses WithStrictMatching.
 func (s *DeviceEnvVarService) Update(ctx context.Context, deviceID IDOrUUID, name, newValue string) error {
-	query := "%24filter=device+eq+%27" + deviceID.id + "%27"
-	if deviceID.isUUID {
-		query = "%24filter=device/uuid+eq+%27" + deviceID.id + "%27"
-	}
-	query = query + "+and+name+eq+%27" + name + "%27"
+	_, err := s.update(ctx, deviceID, name, newValue, false)
+	return err
+}
+
+// UpdateCount is like Update, but returns the number of updated variables, which is zero if no variable with such
+// name exists. WithStrictMatching does not apply.
+func (s *DeviceEnvVarService) UpdateCount(ctx context.Context, deviceID IDOrUUID, name, newValue string) (int, error) {
+	return s.update(ctx, deviceID, name, newValue, true)
+}
+
+func (s *DeviceEnvVarService) update(
+	ctx context.Context,
+	deviceID IDOrUUID,
+	name string,
+	newValue string,
+	count bool,
+) (int, error) {
 	body := struct {
 		Value string `json:"value"`
 	}{Value: newValue}
-	req, err := s.client.NewRequest(ctx, http.MethodPatch, deviceEnvVarBasePath, query, body)
-	if err != nil {
-		return fmt.Errorf("unable to create request: %v", err)
-	}
-	err = s.client.Do(req, nil)
+	filter := odata.And(deviceID.filter("device", "device/uuid"), odata.Eq("name", name))
+	return s.client.doFilterRequest(ctx, http.MethodPatch, deviceEnvVarBasePath, filter, body, count)
+}
+
+// Upsert sets the variable with the given name of the device with given ID/UUID to value, creating it if it does
+// not exist.
+func (s *DeviceEnvVarService) Upsert(
+	ctx context.Context,
+	deviceID IDOrUUID,
+	name string,
+	value string,
+) (*DeviceEnvVarResponse, error) {
+	v, err := s.client.upsertVariable(
+		ctx,
+		deviceEnvVarBasePath,
+		name,
+		value,
+		func() (variable, error) {
+			existing, err := s.Get(ctx, deviceID, name)
+			if existing == nil {
+				return nil, err
+			}
+			return existing, err
+		},
+		func() (variable, error) {
+			created, err := s.Create(ctx, deviceID, name, value)
+			if created == nil {
+				return nil, err
+			}
+			return created, err
+		},
+	)
 	if err != nil {
-		return fmt.Errorf("unable to perform request: %v", err)
+		return nil, err
 	}
-	return nil
+	return v.(*DeviceEnvVarResponse), nil
 }
 
 // DeleteWithName deletes a variable with the given name from the device with given ID/UUID.
-// No error is returned if no variable with such name exists.
+// No error is returned if no variable with such name exists, unless the client uses WithStrictMatching.
 func (s *DeviceEnvVarService) DeleteWithName(ctx context.Context, deviceID IDOrUUID, name string) error {
-	// Get the variable ID
-	query := "%24filter=device+eq+%27" + deviceID.id + "%27"
-	if deviceID.isUUID {
-		query = "%24filter=device/uuid+eq+%27" + deviceID.id + "%27"
-	}
-	query = query + "+and+name+eq+%27" + name + "%27"
-	req, err := s.client.NewRequest(ctx, http.MethodDelete, deviceEnvVarBasePath, query, nil)
+	_, err := s.deleteWithName(ctx, deviceID, name, false)
+	return err
+}
+
+// DeleteWithNameCount is like DeleteWithName, but returns the number of deleted variables, which is zero if no
+// variable with such name exists. WithStrictMatching does not apply.
+func (s *DeviceEnvVarService) DeleteWithNameCount(ctx context.Context, deviceID IDOrUUID, name string) (int, error) {
+	return s.deleteWithName(ctx, deviceID, name, true)
+}
+
+func (s *DeviceEnvVarService) deleteWithName(
+	ctx context.Context,
+	deviceID IDOrUUID,
+	name string,
+	count bool,
+) (int, error) {
+	filter := odata.And(deviceID.filter("device", "device/uuid"), odata.Eq("name", name))
+	return s.client.doFilterRequest(ctx, http.MethodDelete, deviceEnvVarBasePath, filter, nil, count)
+}
+
+func (s *DeviceEnvVarService) getWithQuery(ctx context.Context, query string) ([]*DeviceEnvVarResponse, error) {
+	req, err := s.client.NewRequest(ctx, http.MethodGet, deviceEnvVarBasePath, query, nil)
 	if err != nil {
-		return fmt.Errorf("unable to create request: %v", err)
+		return nil, fmt.Errorf("unable to create request: %w", err)
 	}
-	err = s.client.Do(req, nil)
+	type Response struct {
+		D []*DeviceEnvVarResponse `json:"d,omitempty"`
+	}
+	resp := &Response{}
+	err = s.client.Do(req, resp)
 	if err != nil {
-		return fmt.Errorf("unable to perform request: %v", err)
+		return nil, fmt.Errorf("unable to perform request: %w", err)
 	}
-	return nil
+	return resp.D, nil
+}
+
+func (v *DeviceEnvVarResponse) variableFields() (int64, *string) {
+	return v.ID, &v.Value
 }
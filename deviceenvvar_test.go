@@ -3,6 +3,7 @@ package balena
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -359,3 +360,200 @@ func TestDeviceEnvVarService_DeleteWithName_NotFound(t *testing.T) {
 	// Then
 	assert.NilError(t, err)
 }
+
+func TestDeviceEnvVarService_DeleteWithName_EscapesName(t *testing.T) {
+	// Given
+	uuid := "12345678901234567890"
+	key := "key' or name ne '&x=y+z"
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/"+deviceEnvVarBasePath, func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodDelete)
+		expected := "%24filter=device/uuid+eq+%27" + uuid + "%27" +
+			"+and+name+eq+%27key%27%27+or+name+ne+%27%27%26x%3Dy%2Bz%27"
+		if r.URL.RawQuery != expected {
+			http.Error(w, fmt.Sprintf("query = %s ; expected %s", r.URL.RawQuery, expected), http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, "OK")
+	})
+	// When
+	err := client.DeviceEnvVar.DeleteWithName(context.Background(), DeviceUUID(uuid), key)
+	// Then
+	assert.NilError(t, err)
+}
+
+func TestDeviceEnvVarService_DeleteWithName_InvalidName(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/"+deviceEnvVarBasePath, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("unexpected request")
+	})
+	// When
+	err := client.DeviceEnvVar.DeleteWithName(context.Background(), DeviceID(123456), "\xff")
+	// Then
+	assert.ErrorContains(t, err, "invalid OData literal")
+}
+
+func TestDeviceEnvVarService_Get(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/"+deviceEnvVarBasePath, func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		assert.Equal(t, r.URL.RawQuery, "%24filter=device/uuid+eq+%27abc%27+and+name+eq+%27KEY%27")
+		fmt.Fprint(w, `{"d":[{"id":1,"device":{"__id":123},"name":"KEY","value":"value"}]}`)
+	})
+	// When
+	actual, err := client.DeviceEnvVar.Get(context.Background(), DeviceUUID("abc"), "KEY")
+	// Then
+	assert.NilError(t, err)
+	assert.DeepEqual(t, actual, &DeviceEnvVarResponse{ID: 1, Device: odata.Object{ID: 123}, Name: "KEY", Value: "value"})
+}
+
+func TestDeviceEnvVarService_Upsert(t *testing.T) {
+	for _, tt := range []struct {
+		name            string
+		existing        string
+		expectedMethods []string
+	}{
+		{name: "create", existing: `{"d":[]}`, expectedMethods: []string{"GET", "POST"}},
+		{
+			name:            "update",
+			existing:        `{"d":[{"id":1,"name":"KEY","value":"old"}]}`,
+			expectedMethods: []string{"GET", "PATCH"},
+		},
+		{name: "unchanged", existing: `{"d":[{"id":1,"name":"KEY","value":"new"}]}`, expectedMethods: []string{"GET"}},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			client, mux, cleanup := newFixture()
+			defer cleanup()
+			var methods []string
+			mux.HandleFunc("/"+deviceEnvVarBasePath, func(w http.ResponseWriter, r *http.Request) {
+				methods = append(methods, r.Method)
+				switch r.Method {
+				case http.MethodGet:
+					assert.Equal(t, r.URL.RawQuery, "%24filter=device+eq+%27123%27+and+name+eq+%27KEY%27")
+					fmt.Fprint(w, tt.existing)
+				case http.MethodPost:
+					var body map[string]string
+					assert.NilError(t, json.NewDecoder(r.Body).Decode(&body))
+					assert.DeepEqual(t, body, map[string]string{"device": "123", "name": "KEY", "value": "new"})
+					fmt.Fprint(w, `{"id":1,"name":"KEY","value":"new"}`)
+				}
+			})
+			mux.HandleFunc("/"+deviceEnvVarBasePath+"(1)", func(w http.ResponseWriter, r *http.Request) {
+				methods = append(methods, r.Method)
+				testMethod(t, r, http.MethodPatch)
+				fmt.Fprint(w, "OK")
+			})
+			// When
+			actual, err := client.DeviceEnvVar.Upsert(context.Background(), DeviceID(123), "KEY", "new")
+			// Then
+			assert.NilError(t, err)
+			assert.Equal(t, actual.Value, "new")
+			assert.DeepEqual(t, methods, tt.expectedMethods)
+		})
+	}
+}
+
+func TestDeviceEnvVarService_UpdateCount_NotFound(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/"+deviceEnvVarBasePath, func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		fmt.Fprint(w, `{"d":[]}`)
+	})
+	// When
+	n, err := client.DeviceEnvVar.UpdateCount(context.Background(), DeviceID(123), "KEY", "new")
+	// Then
+	assert.NilError(t, err)
+	assert.Equal(t, n, 0)
+}
+
+func TestDeviceEnvVarService_Update_StrictMatching(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	assert.NilError(t, WithStrictMatching()(client))
+	mux.HandleFunc("/"+deviceEnvVarBasePath, func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		assert.Equal(t, r.URL.RawQuery, "%24select=id&%24filter=device/uuid+eq+%27abc%27+and+name+eq+%27TYPO%27")
+		fmt.Fprint(w, `{"d":[]}`)
+	})
+	// When
+	err := client.DeviceEnvVar.Update(context.Background(), DeviceUUID("abc"), "TYPO", "value")
+	// Then
+	assert.Assert(t, errors.Is(err, ErrNotFound))
+}
+
+func TestDeviceEnvVarService_DeleteWithNameCount(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	var methods []string
+	mux.HandleFunc("/"+deviceEnvVarBasePath, func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		switch r.Method {
+		case http.MethodGet:
+			assert.Equal(t, r.URL.RawQuery, "%24select=id&%24filter=device+eq+%27123%27+and+name+eq+%27KEY%27")
+			fmt.Fprint(w, `{"d":[{"id":1}]}`)
+		case http.MethodDelete:
+			assert.Equal(t, r.URL.RawQuery, "%24filter=id+eq+1")
+			fmt.Fprint(w, "OK")
+		}
+	})
+	// When
+	n, err := client.DeviceEnvVar.DeleteWithNameCount(context.Background(), DeviceID(123), "KEY")
+	// Then
+	assert.NilError(t, err)
+	assert.Equal(t, n, 1)
+	assert.DeepEqual(t, methods, []string{http.MethodGet, http.MethodDelete})
+}
+
+func TestDeviceEnvVarService_DeleteWithNameCount_StrictMatching(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	assert.NilError(t, WithStrictMatching()(client))
+	mux.HandleFunc("/"+deviceEnvVarBasePath, func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		fmt.Fprint(w, `{"d":[]}`)
+	})
+	// When
+	n, err := client.DeviceEnvVar.DeleteWithNameCount(context.Background(), DeviceID(123), "KEY")
+	// Then
+	assert.NilError(t, err)
+	assert.Equal(t, n, 0)
+}
+
+func TestDeviceEnvVarService_DeleteWithNameCount_ListFails(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/"+deviceEnvVarBasePath, func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		w.WriteHeader(http.StatusNotFound)
+	})
+	// When
+	_, err := client.DeviceEnvVar.DeleteWithNameCount(context.Background(), DeviceID(123), "KEY")
+	// Then
+	assert.Assert(t, errors.Is(err, ErrNotFound))
+}
+
+func TestDeviceEnvVarService_Create_UnknownUUID(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/"+deviceBasePath, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"d":[]}`)
+	})
+	// When
+	_, err := client.DeviceEnvVar.Create(context.Background(), DeviceUUID("missing"), "KEY", "value")
+	// Then
+	assert.Assert(t, errors.Is(err, ErrNotFound))
+}
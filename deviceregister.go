@@ -0,0 +1,112 @@
+package balena
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+const deviceRegisterPath = "device/register"
+
+// DeviceRegistration describes a device to register with DeviceService.Register.
+type DeviceRegistration struct {
+	// ApplicationID is the ID of the application to register the device with.
+	ApplicationID int64
+	// DeviceType is the slug of the device type, such as `raspberrypi4-64`.
+	DeviceType string
+	// ProvisioningKey is a provisioning key of the application, see ApplicationService.CreateProvisioningKey.
+	ProvisioningKey string
+	// UUID is the UUID of the device. A UUID is generated with GenerateDeviceUUID if empty.
+	UUID string
+	// UserID is the ID of the user owning the device, if any.
+	UserID int64
+	// OSVersion, OSVariant, SupervisorVersion and MACAddress optionally describe the device.
+	OSVersion         string
+	OSVariant         string
+	SupervisorVersion string
+	MACAddress        string
+}
+
+// RegisteredDevice is a device registered with DeviceService.Register.
+type RegisteredDevice struct {
+	ID   int64  `json:"id"`
+	UUID string `json:"uuid"`
+	// APIKey is the API key of the device, to be written to the config.json of the device as `deviceApiKey`.
+	APIKey string `json:"api_key"`
+}
+
+// Register pre-registers a device with an application, authenticated by a provisioning key of the application
+// instead of the token of the client. The UUID and API key of the device are generated unless given. An error
+// wrapping ErrConflict is returned if a device with the UUID already exists.
+func (s *DeviceService) Register(ctx context.Context, registration DeviceRegistration) (*RegisteredDevice, error) {
+	if registration.ApplicationID == 0 {
+		return nil, errors.New("unable to register device: application ID must not be zero")
+	}
+	if registration.DeviceType == "" {
+		return nil, errors.New("unable to register device: device type must not be empty")
+	}
+	if registration.ProvisioningKey == "" {
+		return nil, errors.New("unable to register device: provisioning key must not be empty")
+	}
+	uuid := registration.UUID
+	if uuid == "" {
+		var err error
+		if uuid, err = GenerateDeviceUUID(); err != nil {
+			return nil, fmt.Errorf("unable to register device: %w", err)
+		}
+	}
+	apiKey, err := generateKey()
+	if err != nil {
+		return nil, fmt.Errorf("unable to register device: %w", err)
+	}
+	type request struct {
+		User              int64  `json:"user,omitempty"`
+		Application       int64  `json:"application"`
+		UUID              string `json:"uuid"`
+		DeviceType        string `json:"device_type"`
+		APIKey            string `json:"api_key"`
+		OSVersion         string `json:"os_version,omitempty"`
+		OSVariant         string `json:"os_variant,omitempty"`
+		SupervisorVersion string `json:"supervisor_version,omitempty"`
+		MACAddress        string `json:"mac_address,omitempty"`
+	}
+	req, err := s.client.newRequest(ctx, http.MethodPost, deviceRegisterPath, "", &request{
+		User:              registration.UserID,
+		Application:       registration.ApplicationID,
+		UUID:              uuid,
+		DeviceType:        registration.DeviceType,
+		APIKey:            apiKey,
+		OSVersion:         registration.OSVersion,
+		OSVariant:         registration.OSVariant,
+		SupervisorVersion: registration.SupervisorVersion,
+		MACAddress:        registration.MACAddress,
+	}, registration.ProvisioningKey)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+	resp := &RegisteredDevice{}
+	if err := s.client.Do(req, resp); err != nil {
+		return nil, fmt.Errorf("unable to register device %s: %w", uuid, err)
+	}
+	if resp.APIKey == "" {
+		resp.APIKey = apiKey
+	}
+	return resp, nil
+}
+
+// GenerateDeviceUUID generates a random device UUID of 32 hexadecimal characters, as generated by balena.
+func GenerateDeviceUUID() (string, error) {
+	return generateKey()
+}
+
+// generateKey returns 16 random bytes encoded as hexadecimal characters.
+func generateKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("unable to generate key: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
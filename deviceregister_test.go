@@ -0,0 +1,70 @@
+package balena
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestDeviceService_Register(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	var apiKey string
+	mux.HandleFunc("/"+deviceRegisterPath, func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodPost)
+		assert.Equal(t, r.Header.Get("Authorization"), "Bearer provisioning-key")
+		var body map[string]interface{}
+		assert.NilError(t, json.NewDecoder(r.Body).Decode(&body))
+		apiKey = body["api_key"].(string)
+		assert.Equal(t, len(apiKey), 32)
+		delete(body, "api_key")
+		assert.DeepEqual(t, body, map[string]interface{}{
+			"application": float64(123),
+			"uuid":        "abc",
+			"device_type": "raspberrypi4-64",
+			"os_version":  "balenaOS 2.83.18",
+		})
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"id":1,"uuid":"abc","api_key":%q}`, apiKey)
+	})
+	// When
+	actual, err := client.Device.Register(context.Background(), DeviceRegistration{
+		ApplicationID:   123,
+		DeviceType:      "raspberrypi4-64",
+		ProvisioningKey: "provisioning-key",
+		UUID:            "abc",
+		OSVersion:       "balenaOS 2.83.18",
+	})
+	// Then
+	assert.NilError(t, err)
+	assert.DeepEqual(t, actual, &RegisteredDevice{ID: 1, UUID: "abc", APIKey: apiKey})
+}
+
+func TestDeviceService_Register_MissingProvisioningKey(t *testing.T) {
+	// Given
+	client, _, cleanup := newFixture()
+	defer cleanup()
+	// When
+	_, err := client.Device.Register(context.Background(), DeviceRegistration{
+		ApplicationID: 123,
+		DeviceType:    "raspberrypi4-64",
+	})
+	// Then
+	assert.ErrorContains(t, err, "provisioning key must not be empty")
+}
+
+func TestGenerateDeviceUUID(t *testing.T) {
+	// When
+	first, err := GenerateDeviceUUID()
+	assert.NilError(t, err)
+	second, err := GenerateDeviceUUID()
+	assert.NilError(t, err)
+	// Then
+	assert.Equal(t, len(first), 32)
+	assert.Assert(t, first != second)
+}
@@ -30,25 +30,36 @@ type DeviceServVarCreateResponse struct {
 
 // List lists all environment variables given a specific device ID/UUID.
 func (s *DeviceServVarService) List(ctx context.Context, deviceID IDOrUUID) ([]*DeviceServVarResponse, error) {
-	query := "%24filter=service_install/device+eq+%27" + deviceID.id + "%27"
-	if deviceID.isUUID {
-		query = "%24filter=service_install/device/uuid+eq+%27" + deviceID.id + "%27"
-	}
-	//nolint:lll
-	query += "&$expand=service_install($select=id,device,created_at;$expand=installs__service($select=id,service_name,created_at,application))"
-	req, err := s.client.NewRequest(ctx, http.MethodGet, deviceServVarBasePath, query, nil)
+	query, err := filterQuery(deviceID.filter("service_install/device", "service_install/device/uuid"))
 	if err != nil {
-		return nil, fmt.Errorf("unable to create request: %v", err)
+		return nil, fmt.Errorf("unable to create request: %w", err)
 	}
-	type Response struct {
-		D []*DeviceServVarResponse `json:"d,omitempty"`
+	return s.getWithQuery(ctx, query)
+}
+
+// Get retrieves the variable with the given name of the named service on the device with given ID/UUID.
+// If no variable with such name exists, both the response and error are nil.
+func (s *DeviceServVarService) Get(
+	ctx context.Context,
+	deviceID IDOrUUID,
+	serviceName string,
+	name string,
+) (*DeviceServVarResponse, error) {
+	query, err := filterQuery(serviceInstallFilter(deviceID, serviceName, odata.Eq("name", name)))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
 	}
-	resp := &Response{}
-	err = s.client.Do(req, resp)
+	resp, err := s.getWithQuery(ctx, query)
 	if err != nil {
-		return nil, fmt.Errorf("unable to perform request: %v", err)
+		return nil, err
 	}
-	return resp.D, nil
+	if len(resp) > 1 {
+		return nil, fmt.Errorf("expected 1 variable but got %d", len(resp))
+	}
+	if len(resp) == 0 {
+		return nil, nil
+	}
+	return resp[0], nil
 }
 
 // Create creates an environment variable with name=value given a ServiceInstall ID (see ServiceInstallService).
@@ -69,54 +80,186 @@ func (s *DeviceServVarService) Create(
 		Value:            value,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("unable to create request: %v", err)
+		return nil, fmt.Errorf("unable to create request: %w", err)
 	}
 	resp := &DeviceServVarCreateResponse{}
 	err = s.client.Do(req, resp)
 	if err != nil {
-		return nil, fmt.Errorf("unable to perform request: %v", err)
+		return nil, fmt.Errorf("unable to perform request: %w", err)
 	}
 	return resp, nil
 }
 
-// Update a variable with the given name from the device with given ID/UUID to a new value.
-// No error is returned if no variable with such name exists.
+// Update a variable with the given name from the device with given ID/UUID to the specified new value.
+// No error is returned if no variable with such name exists, unless the client uses WithStrictMatching.
 func (s *DeviceServVarService) Update(ctx context.Context, deviceID IDOrUUID, name, newValue string) error {
-	query := "%24filter=service_install/device+eq+%27" + deviceID.id + "%27"
-	if deviceID.isUUID {
-		query = "%24filter=service_install/device/uuid+eq+%27" + deviceID.id + "%27"
-	}
-	query = query + "+and+name+eq+%27" + name + "%27"
+	_, err := s.update(ctx, deviceID, name, newValue, false)
+	return err
+}
+
+// UpdateCount is like Update, but returns the number of updated variables, which is one per service having a
+// variable with such name. WithStrictMatching does not apply.
+func (s *DeviceServVarService) UpdateCount(ctx context.Context, deviceID IDOrUUID, name, newValue string) (int, error) {
+	return s.update(ctx, deviceID, name, newValue, true)
+}
+
+func (s *DeviceServVarService) update(
+	ctx context.Context,
+	deviceID IDOrUUID,
+	name string,
+	newValue string,
+	count bool,
+) (int, error) {
+	return s.updateWithFilter(ctx, odata.And(
+		deviceID.filter("service_install/device", "service_install/device/uuid"),
+		odata.Eq("name", name),
+	), newValue, count)
+}
+
+// updateWithFilter updates the variables matching filter to the specified new value.
+func (s *DeviceServVarService) updateWithFilter(
+	ctx context.Context,
+	filter odata.Filter,
+	newValue string,
+	count bool,
+) (int, error) {
 	body := struct {
 		Value string `json:"value"`
 	}{Value: newValue}
-	req, err := s.client.NewRequest(ctx, http.MethodPatch, deviceServVarBasePath, query, body)
-	if err != nil {
-		return fmt.Errorf("unable to create request: %v", err)
-	}
-	err = s.client.Do(req, nil)
+	return s.client.doFilterRequest(ctx, http.MethodPatch, deviceServVarBasePath, filter, body, count)
+}
+
+// Upsert sets the variable with the given name of the named service on the device with given ID/UUID to value,
+// creating it if it does not exist. An error wrapping ErrNotFound is returned if the service is not installed on
+// the device.
+func (s *DeviceServVarService) Upsert(
+	ctx context.Context,
+	deviceID IDOrUUID,
+	serviceName string,
+	name string,
+	value string,
+) (*DeviceServVarResponse, error) {
+	v, err := s.client.upsertVariable(
+		ctx,
+		deviceServVarBasePath,
+		name,
+		value,
+		func() (variable, error) {
+			existing, err := s.Get(ctx, deviceID, serviceName, name)
+			if existing == nil {
+				return nil, err
+			}
+			return existing, err
+		},
+		func() (variable, error) {
+			serviceInstallID, err := s.resolveServiceInstallID(ctx, deviceID, serviceName)
+			if err != nil {
+				return nil, err
+			}
+			// Return no variable, so that it is retrieved with its service install expanded.
+			_, err = s.Create(ctx, serviceInstallID, name, value)
+			return nil, err
+		},
+	)
 	if err != nil {
-		return fmt.Errorf("unable to perform request: %v", err)
+		return nil, err
 	}
-	return nil
+	return v.(*DeviceServVarResponse), nil
 }
 
 // DeleteWithName deletes a variable with the given name from the device with given ID/UUID.
-// No error is returned if no variable with such name exists.
+// No error is returned if no variable with such name exists, unless the client uses WithStrictMatching.
 func (s *DeviceServVarService) DeleteWithName(ctx context.Context, deviceID IDOrUUID, name string) error {
-	// Get the variable ID
-	query := "%24filter=service_install/device+eq+%27" + deviceID.id + "%27"
-	if deviceID.isUUID {
-		query = "%24filter=service_install/device/uuid+eq+%27" + deviceID.id + "%27"
+	_, err := s.deleteWithName(ctx, deviceID, name, false)
+	return err
+}
+
+// DeleteWithNameCount is like DeleteWithName, but returns the number of deleted variables, which is one per
+// service having a variable with such name. WithStrictMatching does not apply.
+func (s *DeviceServVarService) DeleteWithNameCount(ctx context.Context, deviceID IDOrUUID, name string) (int, error) {
+	return s.deleteWithName(ctx, deviceID, name, true)
+}
+
+func (s *DeviceServVarService) deleteWithName(
+	ctx context.Context,
+	deviceID IDOrUUID,
+	name string,
+	count bool,
+) (int, error) {
+	return s.deleteWithFilter(ctx, odata.And(
+		deviceID.filter("service_install/device", "service_install/device/uuid"),
+		odata.Eq("name", name),
+	), count)
+}
+
+// deleteWithFilter deletes the variables matching filter.
+func (s *DeviceServVarService) deleteWithFilter(ctx context.Context, filter odata.Filter, count bool) (int, error) {
+	return s.client.doFilterRequest(ctx, http.MethodDelete, deviceServVarBasePath, filter, nil, count)
+}
+
+func (s *DeviceServVarService) getWithQuery(ctx context.Context, query string) ([]*DeviceServVarResponse, error) {
+	//nolint:lll
+	query += "&$expand=service_install($select=id,device,created_at;$expand=installs__service($select=id,service_name,created_at,application))"
+	req, err := s.client.NewRequest(ctx, http.MethodGet, deviceServVarBasePath, query, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
 	}
-	query = query + "+and+name+eq+%27" + name + "%27"
-	req, err := s.client.NewRequest(ctx, http.MethodDelete, deviceServVarBasePath, query, nil)
+	type Response struct {
+		D []*DeviceServVarResponse `json:"d,omitempty"`
+	}
+	resp := &Response{}
+	err = s.client.Do(req, resp)
+	if err != nil {
+		return nil, fmt.Errorf("unable to perform request: %w", err)
+	}
+	return resp.D, nil
+}
+
+func (v *DeviceServVarResponse) variableFields() (int64, *string) {
+	return v.ID, &v.Value
+}
+
+// resolveServiceInstallID returns the ID of the service install of the named service on the device with given
+// ID/UUID. An error wrapping ErrNotFound is returned if the service is not installed on the device.
+func (s *DeviceServVarService) resolveServiceInstallID(
+	ctx context.Context,
+	deviceID IDOrUUID,
+	serviceName string,
+) (int64, error) {
+	query, err := odata.NewQuery().
+		Filter(odata.And(
+			deviceID.filter("device", "device/uuid"),
+			odata.Eq("installs__service/service_name", serviceName),
+		)).
+		Select("id").
+		Encode()
 	if err != nil {
-		return fmt.Errorf("unable to create request: %v", err)
+		return 0, fmt.Errorf("unable to create request: %w", err)
 	}
-	err = s.client.Do(req, nil)
+	req, err := s.client.NewRequest(ctx, http.MethodGet, serviceInstallBasePath, query, nil)
 	if err != nil {
-		return fmt.Errorf("unable to perform request: %v", err)
+		return 0, fmt.Errorf("unable to create request: %w", err)
+	}
+	type Response struct {
+		D []struct {
+			ID int64 `json:"id"`
+		} `json:"d,omitempty"`
+	}
+	resp := &Response{}
+	if err := s.client.Do(req, resp); err != nil {
+		return 0, fmt.Errorf("unable to resolve service install of %s: %w", serviceName, err)
+	}
+	if len(resp.D) == 0 {
+		return 0, fmt.Errorf("service %s of device %s: %w", serviceName, deviceID.id, ErrNotFound)
 	}
-	return nil
+	return resp.D[0].ID, nil
+}
+
+// serviceInstallFilter returns an OData filter matching variables of the named service on a device, and the given
+// filters.
+func serviceInstallFilter(deviceID IDOrUUID, serviceName string, filters ...odata.Filter) odata.Filter {
+	return odata.And(append([]odata.Filter{
+		deviceID.filter("service_install/device", "service_install/device/uuid"),
+		odata.Eq("service_install/installs__service/service_name", serviceName),
+	}, filters...)...)
 }
@@ -3,10 +3,12 @@ package balena
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -447,3 +449,171 @@ func TestDeviceServVarService_DeleteWithName_NotFound(t *testing.T) {
 	// Then
 	assert.NilError(t, err)
 }
+
+func TestDeviceServVarService_Get(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/"+deviceServVarBasePath, func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		//nolint:lll
+		expected := "%24filter=service_install/device+eq+%27123%27+and+service_install/installs__service/service_name+eq+%27main%27+and+name+eq+%27KEY%27" +
+			"&$expand=service_install($select=id,device,created_at;$expand=installs__service($select=id,service_name,created_at,application))"
+		assert.Equal(t, r.URL.RawQuery, expected)
+		fmt.Fprint(w, `{"d":[]}`)
+	})
+	// When
+	actual, err := client.DeviceServVar.Get(context.Background(), DeviceID(123), "main", "KEY")
+	// Then
+	assert.NilError(t, err)
+	assert.Assert(t, actual == nil)
+}
+
+func TestDeviceServVarService_Upsert(t *testing.T) {
+	const existing = `{"d":[{"id":1,"name":"KEY","value":"%s","service_install":[
+		{"id":11,"installs__service":[{"id":7,"service_name":"main"}]}
+	]}]}`
+	for _, tt := range []struct {
+		name            string
+		existing        []string
+		expectedMethods []string
+	}{
+		{
+			name:            "create",
+			existing:        []string{`{"d":[]}`, fmt.Sprintf(existing, "new")},
+			expectedMethods: []string{"GET", "GET " + serviceInstallBasePath, "POST", "GET"},
+		},
+		{
+			name:            "update",
+			existing:        []string{fmt.Sprintf(existing, "old")},
+			expectedMethods: []string{"GET", "PATCH"},
+		},
+		{
+			name:            "unchanged",
+			existing:        []string{fmt.Sprintf(existing, "new")},
+			expectedMethods: []string{"GET"},
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			client, mux, cleanup := newFixture()
+			defer cleanup()
+			var methods []string
+			mux.HandleFunc("/"+serviceInstallBasePath, func(w http.ResponseWriter, r *http.Request) {
+				methods = append(methods, r.Method+" "+serviceInstallBasePath)
+				testMethod(t, r, http.MethodGet)
+				assert.Equal(
+					t,
+					r.URL.RawQuery,
+					"%24select=id&%24filter=device/uuid+eq+%27abc%27+and+installs__service/service_name+eq+%27main%27",
+				)
+				fmt.Fprint(w, `{"d":[{"id":11}]}`)
+			})
+			mux.HandleFunc("/"+deviceServVarBasePath, func(w http.ResponseWriter, r *http.Request) {
+				methods = append(methods, r.Method)
+				switch r.Method {
+				case http.MethodGet:
+					fmt.Fprint(w, tt.existing[0])
+					tt.existing = tt.existing[1:]
+				case http.MethodPost:
+					var body map[string]interface{}
+					assert.NilError(t, json.NewDecoder(r.Body).Decode(&body))
+					assert.DeepEqual(t, body, map[string]interface{}{
+						"service_install": float64(11),
+						"name":            "KEY",
+						"value":           "new",
+					})
+					fmt.Fprint(w, `{"id":1,"name":"KEY","value":"new","service_install":{"__id":11}}`)
+				}
+			})
+			mux.HandleFunc("/"+deviceServVarBasePath+"(1)", func(w http.ResponseWriter, r *http.Request) {
+				methods = append(methods, r.Method)
+				testMethod(t, r, http.MethodPatch)
+				fmt.Fprint(w, "OK")
+			})
+			// When
+			actual, err := client.DeviceServVar.Upsert(context.Background(), DeviceUUID("abc"), "main", "KEY", "new")
+			// Then
+			assert.NilError(t, err)
+			assert.Equal(t, actual.ID, int64(1))
+			assert.Equal(t, actual.Value, "new")
+			assert.DeepEqual(t, actual.ServiceInstall.ServiceNames(), []string{"main"})
+			assert.DeepEqual(t, methods, tt.expectedMethods)
+		})
+	}
+}
+
+func TestDeviceServVarService_Upsert_UnknownService(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/"+serviceInstallBasePath, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"d":[]}`)
+	})
+	mux.HandleFunc("/"+deviceServVarBasePath, func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		fmt.Fprint(w, `{"d":[]}`)
+	})
+	// When
+	_, err := client.DeviceServVar.Upsert(context.Background(), DeviceUUID("abc"), "missing", "KEY", "value")
+	// Then
+	assert.Assert(t, errors.Is(err, ErrNotFound))
+}
+
+func TestDeviceServVarService_UpdateCount_StrictMatching(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	assert.NilError(t, WithStrictMatching()(client))
+	var methods []string
+	mux.HandleFunc("/"+deviceServVarBasePath, func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		switch r.Method {
+		case http.MethodGet:
+			assert.Equal(
+				t,
+				r.URL.RawQuery,
+				"%24select=id&%24filter=service_install/device/uuid+eq+%27abc%27+and+name+eq+%27KEY%27",
+			)
+			fmt.Fprint(w, `{"d":[{"id":1},{"id":2}]}`)
+		case http.MethodPatch:
+			assert.Equal(t, r.URL.RawQuery, "%24filter=id+eq+1+or+id+eq+2")
+			fmt.Fprint(w, "OK")
+		}
+	})
+	// When
+	n, err := client.DeviceServVar.UpdateCount(context.Background(), DeviceUUID("abc"), "KEY", "new")
+	// Then
+	assert.NilError(t, err)
+	assert.Equal(t, n, 2)
+	assert.DeepEqual(t, methods, []string{http.MethodGet, http.MethodPatch})
+}
+
+func TestDeviceServVarService_DeleteWithNameCount_Batches(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	ids := make([]string, 0, entityBatchSize+1)
+	for id := 1; id <= entityBatchSize+1; id++ {
+		ids = append(ids, fmt.Sprintf(`{"id":%d}`, id))
+	}
+	var deleted []string
+	mux.HandleFunc("/"+deviceServVarBasePath, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			fmt.Fprintf(w, `{"d":[%s]}`, strings.Join(ids, ","))
+		case http.MethodDelete:
+			deleted = append(deleted, r.URL.RawQuery)
+			fmt.Fprint(w, "OK")
+		}
+	})
+	// When
+	n, err := client.DeviceServVar.DeleteWithNameCount(context.Background(), DeviceID(123), "KEY")
+	// Then
+	assert.NilError(t, err)
+	assert.Equal(t, n, entityBatchSize+1)
+	assert.Equal(t, len(deleted), 2)
+	assert.Equal(t, strings.Count(deleted[0], "+or+"), entityBatchSize-1)
+	assert.Equal(t, deleted[1], fmt.Sprintf("%%24filter=id+eq+%d", entityBatchSize+1))
+}
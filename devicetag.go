@@ -1,7 +1,6 @@
 package balena
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"net/http"
@@ -25,13 +24,13 @@ type DeviceTagResponse struct {
 
 // List lists all device tags for a given device ID/UUID.
 func (s *DeviceTagService) List(ctx context.Context, deviceID IDOrUUID) ([]*DeviceTagResponse, error) {
-	query := "%24filter=device/id+eq+%27" + deviceID.id + "%27"
-	if deviceID.isUUID {
-		query = "%24filter=device/uuid+eq+%27" + deviceID.id + "%27"
+	query, err := filterQuery(deviceID.filter("device/id", "device/uuid"))
+	if err != nil {
+		return nil, fmt.Errorf("list device tag NewRequest: %w", err)
 	}
 	req, err := s.client.NewRequest(ctx, http.MethodGet, deviceTagBasePath, query, nil)
 	if err != nil {
-		return nil, fmt.Errorf("list device tag NewRequest: %v", err)
+		return nil, fmt.Errorf("list device tag NewRequest: %w", err)
 	}
 	type Response struct {
 		D []*DeviceTagResponse `json:"d,omitempty"`
@@ -39,7 +38,7 @@ func (s *DeviceTagService) List(ctx context.Context, deviceID IDOrUUID) ([]*Devi
 	resp := &Response{}
 	err = s.client.Do(req, resp)
 	if err != nil {
-		return nil, fmt.Errorf("list device tag: %v", err)
+		return nil, fmt.Errorf("list device tag: %w", err)
 	}
 	return resp.D, nil
 }
@@ -72,12 +71,12 @@ func (s *DeviceTagService) Create(
 		Value:    value,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("create device tag NewRequest: %v", err)
+		return nil, fmt.Errorf("create device tag NewRequest: %w", err)
 	}
 	resp := &DeviceTagResponse{}
 	err = s.client.Do(req, resp)
 	if err != nil {
-		return nil, fmt.Errorf("create device tag: %v", err)
+		return nil, fmt.Errorf("create device tag: %w", err)
 	}
 	return resp, nil
 }
@@ -86,14 +85,13 @@ func (s *DeviceTagService) Create(
 // If no key is found both the response and error returned are nil.
 func (s *DeviceTagService) GetWithKey(ctx context.Context, deviceID IDOrUUID, key string) (*DeviceTagResponse, error) {
 	// Get the variable ID
-	query := "%24filter=device/id+eq+%27" + deviceID.id + "%27"
-	if deviceID.isUUID {
-		query = "%24filter=device/uuid+eq+%27" + deviceID.id + "%27"
+	query, err := filterQuery(odata.And(deviceID.filter("device/id", "device/uuid"), odata.Eq("tag_key", key)))
+	if err != nil {
+		return nil, fmt.Errorf("get device tag with key NewRequest: %w", err)
 	}
-	query = query + "+and+tag_key+eq+%27" + key + "%27"
 	req, err := s.client.NewRequest(ctx, http.MethodGet, deviceTagBasePath, query, nil)
 	if err != nil {
-		return nil, fmt.Errorf("get device tag with key NewRequest: %v", err)
+		return nil, fmt.Errorf("get device tag with key NewRequest: %w", err)
 	}
 	type Response struct {
 		D []*DeviceTagResponse `json:"d,omitempty"`
@@ -101,7 +99,7 @@ func (s *DeviceTagService) GetWithKey(ctx context.Context, deviceID IDOrUUID, ke
 	resp := &Response{}
 	err = s.client.Do(req, resp)
 	if err != nil {
-		return nil, fmt.Errorf("get device tag with key: %v", err)
+		return nil, fmt.Errorf("get device tag with key: %w", err)
 	}
 	if len(resp.D) > 1 {
 		return nil, fmt.Errorf("expected 1 tag but got %d", len(resp.D))
@@ -114,60 +112,67 @@ func (s *DeviceTagService) GetWithKey(ctx context.Context, deviceID IDOrUUID, ke
 }
 
 // UpdateWithKey updates the value of a device tag matching the given key and device ID/UUID.
-// No error is returned if the key or device does not exist.
+// No error is returned if the key or device does not exist, unless the client uses WithStrictMatching.
 func (s *DeviceTagService) UpdateWithKey(ctx context.Context, deviceID IDOrUUID, key, value string) error {
-	// Get the variable ID
-	query := "%24filter=device/id+eq+%27" + deviceID.id + "%27"
-	if deviceID.isUUID {
-		query = "%24filter=device/uuid+eq+%27" + deviceID.id + "%27"
-	}
-	query = query + "+and+tag_key+eq+%27" + key + "%27"
+	_, err := s.updateWithKey(ctx, deviceID, key, value, false)
+	return err
+}
+
+// UpdateWithKeyCount is like UpdateWithKey, but returns the number of updated device tags, which is zero if the key
+// or device does not exist. WithStrictMatching does not apply.
+func (s *DeviceTagService) UpdateWithKeyCount(ctx context.Context, deviceID IDOrUUID, key, value string) (int, error) {
+	return s.updateWithKey(ctx, deviceID, key, value, true)
+}
+
+func (s *DeviceTagService) updateWithKey(
+	ctx context.Context,
+	deviceID IDOrUUID,
+	key string,
+	value string,
+	count bool,
+) (int, error) {
 	type request struct {
 		Value string `json:"value"`
 	}
-	req, err := s.client.NewRequest(ctx, http.MethodPatch, deviceTagBasePath, query, &request{
-		Value: value,
-	})
-	if err != nil {
-		return fmt.Errorf("update device tag with key NewRequest: %v", err)
-	}
-	buf := &bytes.Buffer{}
-	err = s.client.Do(req, buf)
+	filter := odata.And(deviceID.filter("device/id", "device/uuid"), odata.Eq("tag_key", key))
+	n, err := s.client.doFilterRequest(ctx, http.MethodPatch, deviceTagBasePath, filter, &request{Value: value}, count)
 	if err != nil {
-		return fmt.Errorf("update device tag with key: %v", err)
+		return 0, fmt.Errorf("update device tag with key: %w", err)
 	}
-	return nil
+	return n, nil
 }
 
 // DeleteWithKey deletes a device tag from a given device ID/UUID and key.
-// No error is returned if the tag does not exist.
+// No error is returned if the tag does not exist, unless the client uses WithStrictMatching.
 func (s *DeviceTagService) DeleteWithKey(ctx context.Context, deviceID IDOrUUID, key string) error {
-	// Get the variable ID
-	query := "%24filter=device/id+eq+%27" + deviceID.id + "%27"
-	if deviceID.isUUID {
-		query = "%24filter=device/uuid+eq+%27" + deviceID.id + "%27"
-	}
-	query = query + "+and+tag_key+eq+%27" + key + "%27"
-	req, err := s.client.NewRequest(ctx, http.MethodDelete, deviceTagBasePath, query, nil)
-	if err != nil {
-		return fmt.Errorf("delete device tag with key NewRequest: %v", err)
-	}
-	buf := &bytes.Buffer{}
-	err = s.client.Do(req, buf)
+	_, err := s.deleteWithKey(ctx, deviceID, key, false)
+	return err
+}
+
+// DeleteWithKeyCount is like DeleteWithKey, but returns the number of deleted device tags, which is zero if the tag
+// does not exist. WithStrictMatching does not apply.
+func (s *DeviceTagService) DeleteWithKeyCount(ctx context.Context, deviceID IDOrUUID, key string) (int, error) {
+	return s.deleteWithKey(ctx, deviceID, key, true)
+}
+
+func (s *DeviceTagService) deleteWithKey(ctx context.Context, deviceID IDOrUUID, key string, count bool) (int, error) {
+	filter := odata.And(deviceID.filter("device/id", "device/uuid"), odata.Eq("tag_key", key))
+	n, err := s.client.doFilterRequest(ctx, http.MethodDelete, deviceTagBasePath, filter, nil, count)
 	if err != nil {
-		return fmt.Errorf("delete device tag with key: %v", err)
+		return 0, fmt.Errorf("delete device tag with key: %w", err)
 	}
-	return nil
+	return n, nil
 }
 
 // GetWithQuery allows querying for device tags using a custom Open Data Protocol query.
 // The query should be a valid, escaped OData query such as `%24filter=uuid+eq+%2712333422%27`.
 //
 // Forward slash in filter keys should not be escaped (So `device/uuid` should not be escaped).
+// Use odata.Query to build a correctly escaped query.
 func (s *DeviceTagService) GetWithQuery(ctx context.Context, query string) ([]*DeviceTagResponse, error) {
 	req, err := s.client.NewRequest(ctx, http.MethodGet, deviceTagBasePath, query, nil)
 	if err != nil {
-		return nil, fmt.Errorf("get device tag with query NewRequest: %v", err)
+		return nil, fmt.Errorf("get device tag with query NewRequest: %w", err)
 	}
 	type Response struct {
 		D []*DeviceTagResponse `json:"d,omitempty"`
@@ -175,7 +180,7 @@ func (s *DeviceTagService) GetWithQuery(ctx context.Context, query string) ([]*D
 	resp := &Response{}
 	err = s.client.Do(req, resp)
 	if err != nil {
-		return nil, fmt.Errorf("get device tag with query: %v", err)
+		return nil, fmt.Errorf("get device tag with query: %w", err)
 	}
 	return resp.D, nil
 }
@@ -3,6 +3,7 @@ package balena
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -518,3 +519,77 @@ func TestDeviceTagService_GetWithQuery(t *testing.T) {
 	assert.NilError(t, err)
 	assert.DeepEqual(t, expected, actual)
 }
+
+func TestDeviceTagService_GetWithKey_EscapesKey(t *testing.T) {
+	// Given
+	deviceUUID := "1234567890"
+	key := "it's a key"
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/"+deviceTagBasePath, func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		expected := "%24filter=device/uuid+eq+%27" + deviceUUID + "%27+and+tag_key+eq+%27it%27%27s+a+key%27"
+		if r.URL.RawQuery != expected {
+			http.Error(w, fmt.Sprintf("query = %s ; expected %s", r.URL.RawQuery, expected), http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, `{"d":[]}`)
+	})
+	// When
+	actual, err := client.DeviceTag.GetWithKey(context.Background(), DeviceUUID(deviceUUID), key)
+	// Then
+	assert.NilError(t, err)
+	assert.Assert(t, actual == nil)
+}
+
+func TestDeviceTagService_UpdateWithKey_InvalidUUID(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/"+deviceTagBasePath, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("unexpected request")
+	})
+	// When
+	err := client.DeviceTag.UpdateWithKey(context.Background(), DeviceUUID("abc\x00"), "key", "value")
+	// Then
+	assert.ErrorContains(t, err, "invalid OData literal")
+}
+
+func TestDeviceTagService_UpdateWithKeyCount(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	var methods []string
+	mux.HandleFunc("/"+deviceTagBasePath, func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		switch r.Method {
+		case http.MethodGet:
+			assert.Equal(t, r.URL.RawQuery, "%24select=id&%24filter=device/id+eq+%27123%27+and+tag_key+eq+%27key%27")
+			fmt.Fprint(w, `{"d":[{"id":1}]}`)
+		case http.MethodPatch:
+			assert.Equal(t, r.URL.RawQuery, "%24filter=id+eq+1")
+			fmt.Fprint(w, "OK")
+		}
+	})
+	// When
+	n, err := client.DeviceTag.UpdateWithKeyCount(context.Background(), DeviceID(123), "key", "value")
+	// Then
+	assert.NilError(t, err)
+	assert.Equal(t, n, 1)
+	assert.DeepEqual(t, methods, []string{http.MethodGet, http.MethodPatch})
+}
+
+func TestDeviceTagService_DeleteWithKey_StrictMatching(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	assert.NilError(t, WithStrictMatching()(client))
+	mux.HandleFunc("/"+deviceTagBasePath, func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		fmt.Fprint(w, `{"d":[]}`)
+	})
+	// When
+	err := client.DeviceTag.DeleteWithKey(context.Background(), DeviceUUID("abc"), "missing")
+	// Then
+	assert.Assert(t, errors.Is(err, ErrNotFound))
+}
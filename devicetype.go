@@ -55,6 +55,7 @@ func (s *DeviceTypeService) Get(ctx context.Context, id int64) (*DeviceTypeRespo
 // The query should be a valid, escaped OData query such as `%24filter=slug+eq+'jetson-tx2'`
 //
 // Forward slash in filter keys should not be escaped (So `device_type/slug` should not be escaped).
+// Use odata.Query to build a correctly escaped query.
 func (s *DeviceTypeService) GetWithQuery(ctx context.Context, query string) ([]*DeviceTypeResponse, error) {
 	req, err := s.client.NewRequest(ctx, http.MethodGet, deviceTypeBasePath, query, nil)
 	if err != nil {
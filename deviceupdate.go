@@ -0,0 +1,95 @@
+package balena
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// DeviceUpdate holds the fields of a device to change with DeviceService.Update.
+// Only fields that are set, i.e. non-nil, are sent. Use String, Bool and Time to set fields inline.
+type DeviceUpdate struct {
+	// DeviceName renames the device. It must not be empty.
+	DeviceName *string
+	// Note sets the note of the device. An empty note clears it.
+	Note *string
+	// CustomLatitude and CustomLongitude set a custom location of the device, overriding the location given by
+	// its IP address. Empty values clear the custom location.
+	CustomLatitude  *string
+	CustomLongitude *string
+	// IsWebAccessible enables or disables the public device URL.
+	IsWebAccessible *bool
+	// IsLockedUntil locks the device from updates until the given time. The zero time clears the lock.
+	IsLockedUntil *time.Time
+	// IsAccessibleBySupportUntil grants balena support access to the device until the given time.
+	// The zero time revokes access.
+	IsAccessibleBySupportUntil *time.Time
+}
+
+// fields returns the fields of the update that are set, keyed by the name of the device field.
+func (u *DeviceUpdate) fields() map[string]interface{} {
+	fields := map[string]interface{}{}
+	if u.DeviceName != nil {
+		fields["device_name"] = *u.DeviceName
+	}
+	if u.Note != nil {
+		fields["note"] = *u.Note
+	}
+	if u.CustomLatitude != nil {
+		fields["custom_latitude"] = *u.CustomLatitude
+	}
+	if u.CustomLongitude != nil {
+		fields["custom_longitude"] = *u.CustomLongitude
+	}
+	if u.IsWebAccessible != nil {
+		fields["is_web_accessible"] = *u.IsWebAccessible
+	}
+	if u.IsLockedUntil != nil {
+		fields["is_locked_until__date"] = nullableTime(*u.IsLockedUntil)
+	}
+	if u.IsAccessibleBySupportUntil != nil {
+		fields["is_accessible_by_support_until__date"] = nullableTime(*u.IsAccessibleBySupportUntil)
+	}
+	return fields
+}
+
+// Update changes the fields of the device with given ID/UUID that are set in update. No request is sent if no
+// field is set. An error wrapping ErrNotFound is returned if no device has the given UUID, or if the API responds
+// that no device has the given ID.
+func (s *DeviceService) Update(ctx context.Context, deviceID IDOrUUID, update DeviceUpdate) error {
+	if update.DeviceName != nil && *update.DeviceName == "" {
+		return fmt.Errorf("unable to update device %s: device name must not be empty", deviceID.id)
+	}
+	fields := update.fields()
+	if len(fields) == 0 {
+		return nil
+	}
+	if err := s.doDeviceRequest(ctx, http.MethodPatch, deviceID, fields); err != nil {
+		return fmt.Errorf("unable to update device %s: %w", deviceID.id, err)
+	}
+	return nil
+}
+
+// nullableTime returns t in UTC, or nil for the zero time.
+func nullableTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC()
+}
+
+// String returns a pointer to v, for setting optional fields such as those of DeviceUpdate.
+func String(v string) *string {
+	return &v
+}
+
+// Bool returns a pointer to v, for setting optional fields such as those of DeviceUpdate.
+func Bool(v bool) *bool {
+	return &v
+}
+
+// Time returns a pointer to v, for setting optional fields such as those of DeviceUpdate.
+func Time(v time.Time) *time.Time {
+	return &v
+}
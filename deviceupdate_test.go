@@ -0,0 +1,108 @@
+package balena
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestDeviceService_Update(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	var methods []string
+	mux.HandleFunc("/"+deviceBasePath, func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		switch r.Method {
+		case http.MethodGet:
+			assert.Equal(t, r.URL.RawQuery, "%24select=id&%24filter=uuid+eq+%27abc%27")
+			fmt.Fprint(w, `{"d":[{"id":1}]}`)
+		case http.MethodPatch:
+			assert.Equal(t, r.URL.RawQuery, "%24filter=id+eq+1")
+			b, err := io.ReadAll(r.Body)
+			assert.NilError(t, err)
+			assert.Equal(
+				t,
+				string(b),
+				`{"custom_latitude":"57.7","device_name":"new","is_locked_until__date":"2021-01-02T03:04:05Z","note":""}`+"\n",
+			)
+			fmt.Fprint(w, "OK")
+		}
+	})
+	// When
+	err := client.Device.Update(context.Background(), DeviceUUID("abc"), DeviceUpdate{
+		DeviceName:     String("new"),
+		Note:           String(""),
+		CustomLatitude: String("57.7"),
+		IsLockedUntil:  Time(time.Date(2021, 1, 2, 4, 4, 5, 0, time.FixedZone("CET", 3600))),
+	})
+	// Then
+	assert.NilError(t, err)
+	assert.DeepEqual(t, methods, []string{http.MethodGet, http.MethodPatch})
+}
+
+func TestDeviceService_Update_ID(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	var methods []string
+	mux.HandleFunc("/"+deviceBasePath+"(1)", func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		assert.Equal(t, r.URL.RawQuery, "")
+		fmt.Fprint(w, "OK")
+	})
+	// When
+	err := client.Device.Update(context.Background(), DeviceID(1), DeviceUpdate{Note: String("note")})
+	// Then
+	assert.NilError(t, err)
+	assert.DeepEqual(t, methods, []string{http.MethodPatch})
+}
+
+func TestDeviceService_Update_NotFound(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/"+deviceBasePath, func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		fmt.Fprint(w, `{"d":[]}`)
+	})
+	// When
+	err := client.Device.Update(context.Background(), DeviceUUID("missing"), DeviceUpdate{Note: String("")})
+	// Then
+	assert.Assert(t, errors.Is(err, ErrNotFound))
+}
+
+func TestDeviceUpdate_ClearLock(t *testing.T) {
+	// Given
+	update := DeviceUpdate{IsLockedUntil: Time(time.Time{})}
+	// When
+	fields := update.fields()
+	// Then
+	assert.DeepEqual(t, fields, map[string]interface{}{"is_locked_until__date": nil})
+}
+
+func TestDeviceService_Update_NoFields(t *testing.T) {
+	// Given
+	client, _, cleanup := newFixture()
+	defer cleanup()
+	// When
+	err := client.Device.Update(context.Background(), DeviceID(1), DeviceUpdate{})
+	// Then
+	assert.NilError(t, err)
+}
+
+func TestDeviceService_Update_EmptyName(t *testing.T) {
+	// Given
+	client, _, cleanup := newFixture()
+	defer cleanup()
+	// When
+	err := client.Device.Update(context.Background(), DeviceID(1), DeviceUpdate{DeviceName: String("")})
+	// Then
+	assert.ErrorContains(t, err, "device name must not be empty")
+}
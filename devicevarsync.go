@@ -0,0 +1,305 @@
+package balena
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"go.einride.tech/balena/odata"
+)
+
+// DeviceVarKind is the kind of a device variable.
+type DeviceVarKind int
+
+const (
+	// DeviceVarKindEnv is a device environment variable, see DeviceEnvVarService.
+	DeviceVarKindEnv DeviceVarKind = iota + 1
+	// DeviceVarKindConfig is a device configuration variable, see DeviceConfVarService.
+	DeviceVarKindConfig
+	// DeviceVarKindService is a device service environment variable, see DeviceServVarService.
+	DeviceVarKindService
+)
+
+// String returns the name of the kind.
+func (k DeviceVarKind) String() string {
+	switch k {
+	case DeviceVarKindEnv:
+		return "env"
+	case DeviceVarKindConfig:
+		return "config"
+	case DeviceVarKindService:
+		return "service"
+	}
+	return fmt.Sprintf("DeviceVarKind(%d)", int(k))
+}
+
+// DeviceVarChangeType is the type of a change to a device variable.
+type DeviceVarChangeType int
+
+const (
+	// DeviceVarAdd creates a variable that does not exist.
+	DeviceVarAdd DeviceVarChangeType = iota + 1
+	// DeviceVarChange updates the value of an existing variable.
+	DeviceVarChange
+	// DeviceVarRemove deletes an existing variable.
+	DeviceVarRemove
+)
+
+// String returns the name of the change type.
+func (t DeviceVarChangeType) String() string {
+	switch t {
+	case DeviceVarAdd:
+		return "add"
+	case DeviceVarChange:
+		return "change"
+	case DeviceVarRemove:
+		return "remove"
+	}
+	return fmt.Sprintf("DeviceVarChangeType(%d)", int(t))
+}
+
+// DeviceVars is the desired set of variables of a device.
+// A nil map leaves variables of that kind untouched, while a non-nil map removes all variables of that kind
+// not contained in it.
+type DeviceVars struct {
+	Env    map[string]string
+	Config map[string]string
+	// Service maps service names to the desired variables of the service. Services not contained in the map are
+	// left untouched.
+	Service map[string]map[string]string
+}
+
+// SyncVarsOptions configures DeviceService.SyncVars.
+type SyncVarsOptions struct {
+	// DryRun computes the changes without applying them.
+	DryRun bool
+}
+
+// DeviceVarSyncChange is a change to a device variable computed by DeviceService.SyncVars.
+type DeviceVarSyncChange struct {
+	Kind DeviceVarKind
+	Type DeviceVarChangeType
+	// ServiceName is the name of the service of a DeviceVarKindService variable.
+	ServiceName string
+	Name        string
+	// OldValue is the current value, empty for DeviceVarAdd.
+	OldValue string
+	// NewValue is the desired value, empty for DeviceVarRemove.
+	NewValue string
+	// ID is the ID of the existing variable, zero for DeviceVarAdd.
+	ID int64
+	// Applied is true if the change was applied successfully.
+	Applied bool
+	// Err is the error from applying the change, if any.
+	Err error
+}
+
+// SyncVars reconciles the variables of the device with given ID/UUID with the desired variables, and returns the
+// changes in deterministic order. Changes are applied one by one with DeviceEnvVarService,
+// DeviceConfVarService and DeviceServVarService, continuing after failures, and the result of each is reported
+// in the change. The returned error is non-nil if the changes could not be computed or any
+// change failed to apply.
+//
+// An error wrapping ErrNotFound is returned if the device or a desired service does not exist, and an error
+// wrapping ErrInvalidConfigVarName if a desired configuration variable is not prefixed with BALENA_ or RESIN_.
+func (s *DeviceService) SyncVars(
+	ctx context.Context,
+	deviceID IDOrUUID,
+	desired DeviceVars,
+	opts SyncVarsOptions,
+) ([]*DeviceVarSyncChange, error) {
+	for name := range desired.Config {
+		if err := validateConfigVarName(name); err != nil {
+			return nil, err
+		}
+	}
+	device, err := s.Get(ctx, deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get device: %w", err)
+	}
+	if device == nil {
+		return nil, fmt.Errorf("device %s: %w", deviceID.id, ErrNotFound)
+	}
+	id := DeviceID(device.ID)
+	var changes []*DeviceVarSyncChange
+	if desired.Env != nil {
+		envVars, err := s.client.DeviceEnvVar.List(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list device environment variables: %w", err)
+		}
+		current := make(map[string]existingVar, len(envVars))
+		for _, v := range envVars {
+			current[v.Name] = existingVar{id: v.ID, value: v.Value}
+		}
+		changes = append(changes, diffVars(DeviceVarKindEnv, "", current, desired.Env)...)
+	}
+	if desired.Config != nil {
+		confVars, err := s.client.DeviceConfVar.List(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list device configuration variables: %w", err)
+		}
+		current := make(map[string]existingVar, len(confVars))
+		for _, v := range confVars {
+			current[v.Name] = existingVar{id: v.ID, value: v.Value}
+		}
+		changes = append(changes, diffVars(DeviceVarKindConfig, "", current, desired.Config)...)
+	}
+	serviceInstallIDs := map[string]int64{}
+	if len(desired.Service) > 0 {
+		installs, err := s.client.ServiceInstall.List(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list service installs: %w", err)
+		}
+		for _, install := range installs {
+			for _, installsService := range install.InstallsService {
+				serviceInstallIDs[installsService.ServiceName] = install.ID
+			}
+		}
+		servVars, err := s.client.DeviceServVar.List(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list device service environment variables: %w", err)
+		}
+		current := map[string]map[string]existingVar{}
+		for _, v := range servVars {
+			for _, serviceName := range v.ServiceInstall.ServiceNames() {
+				if current[serviceName] == nil {
+					current[serviceName] = map[string]existingVar{}
+				}
+				current[serviceName][v.Name] = existingVar{id: v.ID, value: v.Value}
+			}
+		}
+		serviceNames := make([]string, 0, len(desired.Service))
+		for serviceName := range desired.Service {
+			if _, ok := serviceInstallIDs[serviceName]; !ok {
+				return nil, fmt.Errorf("service %s of device %s: %w", serviceName, deviceID.id, ErrNotFound)
+			}
+			serviceNames = append(serviceNames, serviceName)
+		}
+		sort.Strings(serviceNames)
+		for _, serviceName := range serviceNames {
+			vars := desired.Service[serviceName]
+			if vars == nil {
+				vars = map[string]string{}
+			}
+			changes = append(changes, diffVars(DeviceVarKindService, serviceName, current[serviceName], vars)...)
+		}
+	}
+	if opts.DryRun {
+		return changes, nil
+	}
+	var failed int
+	var firstErr error
+	for _, change := range changes {
+		change.Err = s.applyVarChange(ctx, id, serviceInstallIDs, change)
+		change.Applied = change.Err == nil
+		if change.Err != nil {
+			if firstErr == nil {
+				firstErr = change.Err
+			}
+			failed++
+		}
+	}
+	if firstErr != nil {
+		return changes, fmt.Errorf("%d of %d changes failed: %w", failed, len(changes), firstErr)
+	}
+	return changes, nil
+}
+
+// applyVarChange applies a change with the service of the kind of the variable. Changing a variable that has been
+// removed concurrently fails with an error wrapping ErrNotFound, while removing it succeeds.
+func (s *DeviceService) applyVarChange(
+	ctx context.Context,
+	deviceID IDOrUUID,
+	serviceInstallIDs map[string]int64,
+	change *DeviceVarSyncChange,
+) error {
+	var err error
+	n := 1
+	switch change.Type {
+	case DeviceVarAdd:
+		switch change.Kind {
+		case DeviceVarKindEnv:
+			_, err = s.client.DeviceEnvVar.Create(ctx, deviceID, change.Name, change.NewValue)
+		case DeviceVarKindConfig:
+			_, err = s.client.DeviceConfVar.Create(ctx, deviceID, change.Name, change.NewValue)
+		case DeviceVarKindService:
+			_, err = s.client.DeviceServVar.Create(ctx, serviceInstallIDs[change.ServiceName], change.Name, change.NewValue)
+		}
+	case DeviceVarChange:
+		switch change.Kind {
+		case DeviceVarKindEnv:
+			n, err = s.client.DeviceEnvVar.UpdateCount(ctx, deviceID, change.Name, change.NewValue)
+		case DeviceVarKindConfig:
+			n, err = s.client.DeviceConfVar.UpdateCount(ctx, deviceID, change.Name, change.NewValue)
+		case DeviceVarKindService:
+			filter := serviceInstallFilter(deviceID, change.ServiceName, odata.Eq("name", change.Name))
+			n, err = s.client.DeviceServVar.updateWithFilter(ctx, filter, change.NewValue, true)
+		}
+	case DeviceVarRemove:
+		switch change.Kind {
+		case DeviceVarKindEnv:
+			_, err = s.client.DeviceEnvVar.DeleteWithNameCount(ctx, deviceID, change.Name)
+		case DeviceVarKindConfig:
+			_, err = s.client.DeviceConfVar.DeleteWithNameCount(ctx, deviceID, change.Name)
+		case DeviceVarKindService:
+			filter := serviceInstallFilter(deviceID, change.ServiceName, odata.Eq("name", change.Name))
+			_, err = s.client.DeviceServVar.deleteWithFilter(ctx, filter, true)
+		}
+	}
+	if err == nil && n == 0 {
+		err = ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("unable to %s %s variable %s: %w", change.Type, change.Kind, change.Name, err)
+	}
+	return nil
+}
+
+type existingVar struct {
+	id    int64
+	value string
+}
+
+// diffVars returns the changes needed to turn the current variables into the desired ones, ordered by name.
+func diffVars(
+	kind DeviceVarKind,
+	serviceName string,
+	current map[string]existingVar,
+	desired map[string]string,
+) []*DeviceVarSyncChange {
+	names := make([]string, 0, len(current)+len(desired))
+	for name := range current {
+		names = append(names, name)
+	}
+	for name := range desired {
+		if _, ok := current[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var changes []*DeviceVarSyncChange
+	for _, name := range names {
+		existing, exists := current[name]
+		value, wanted := desired[name]
+		change := &DeviceVarSyncChange{
+			Kind:        kind,
+			ServiceName: serviceName,
+			Name:        name,
+			OldValue:    existing.value,
+			NewValue:    value,
+			ID:          existing.id,
+		}
+		switch {
+		case !exists:
+			change.Type = DeviceVarAdd
+		case !wanted:
+			change.Type = DeviceVarRemove
+		case existing.value != value:
+			change.Type = DeviceVarChange
+		default:
+			continue
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
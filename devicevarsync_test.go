@@ -0,0 +1,183 @@
+package balena
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestDeviceService_SyncVars_DryRun(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/"+deviceBasePath, func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		fmt.Fprint(w, `{"d":[{"id":1}]}`)
+	})
+	mux.HandleFunc("/"+deviceEnvVarBasePath, func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		assert.Equal(t, r.URL.RawQuery, "%24filter=device+eq+%271%27")
+		fmt.Fprint(w, `{"d":[
+			{"id":11,"name":"KEEP","value":"same"},
+			{"id":12,"name":"CHANGE","value":"old"},
+			{"id":13,"name":"REMOVE","value":"old"}
+		]}`)
+	})
+	// When
+	actual, err := client.Device.SyncVars(context.Background(), DeviceUUID("abc"), DeviceVars{
+		Env: map[string]string{"KEEP": "same", "CHANGE": "new", "ADD": "new"},
+	}, SyncVarsOptions{DryRun: true})
+	// Then
+	assert.NilError(t, err)
+	assert.DeepEqual(t, actual, []*DeviceVarSyncChange{
+		{Kind: DeviceVarKindEnv, Type: DeviceVarAdd, Name: "ADD", NewValue: "new"},
+		{Kind: DeviceVarKindEnv, Type: DeviceVarChange, Name: "CHANGE", OldValue: "old", NewValue: "new", ID: 12},
+		{Kind: DeviceVarKindEnv, Type: DeviceVarRemove, Name: "REMOVE", OldValue: "old", ID: 13},
+	})
+}
+
+func TestDeviceService_SyncVars_ApplyFailure(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/"+deviceBasePath+"(1)", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"d":[{"id":1}]}`)
+	})
+	var requests []string
+	mux.HandleFunc("/"+deviceEnvVarBasePath, func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.RawQuery)
+		switch {
+		case r.Method == http.MethodGet && r.URL.RawQuery == "%24filter=device+eq+%271%27":
+			fmt.Fprint(w, `{"d":[{"id":12,"name":"CHANGE","value":"old"},{"id":13,"name":"REMOVE","value":"old"}]}`)
+		case r.Method == http.MethodGet && strings.Contains(r.URL.RawQuery, "CHANGE"):
+			fmt.Fprint(w, `{"d":[{"id":12}]}`)
+		case r.Method == http.MethodGet:
+			fmt.Fprint(w, `{"d":[{"id":13}]}`)
+		case r.Method == http.MethodPatch:
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			fmt.Fprint(w, "OK")
+		}
+	})
+	// When
+	actual, err := client.Device.SyncVars(context.Background(), DeviceID(1), DeviceVars{
+		Env: map[string]string{"CHANGE": "new"},
+	}, SyncVarsOptions{})
+	// Then
+	assert.Assert(t, errors.Is(err, ErrForbidden))
+	assert.Equal(t, len(actual), 2)
+	assert.Assert(t, !actual[0].Applied && errors.Is(actual[0].Err, ErrForbidden))
+	assert.Assert(t, actual[1].Applied && actual[1].Err == nil)
+	assert.DeepEqual(t, requests, []string{
+		"GET %24filter=device+eq+%271%27",
+		"GET %24select=id&%24filter=device+eq+%271%27+and+name+eq+%27CHANGE%27",
+		"PATCH %24filter=id+eq+12",
+		"GET %24select=id&%24filter=device+eq+%271%27+and+name+eq+%27REMOVE%27",
+		"DELETE %24filter=id+eq+13",
+	})
+}
+
+func TestDeviceService_SyncVars_Apply(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/"+deviceBasePath+"(1)", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"d":[{"id":1}]}`)
+	})
+	mux.HandleFunc("/"+serviceInstallBasePath, func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		fmt.Fprint(w, `{"d":[
+			{"id":31,"installs__service":[{"id":7,"service_name":"main"}]},
+			{"id":32,"installs__service":[{"id":8,"service_name":"sidecar"}]}
+		]}`)
+	})
+	var requests []string
+	record := func(basePath string, respond func(w http.ResponseWriter, r *http.Request)) {
+		mux.HandleFunc("/"+basePath, func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r.Method+" "+basePath+" "+r.URL.RawQuery)
+			respond(w, r)
+		})
+	}
+	record(deviceEnvVarBasePath, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.RawQuery == "%24filter=device+eq+%271%27":
+			fmt.Fprint(w, `{"d":[{"id":11,"name":"OLD","value":"value"}]}`)
+		case r.Method == http.MethodGet:
+			fmt.Fprint(w, `{"d":[{"id":11}]}`)
+		case r.Method == http.MethodPost:
+			b, err := io.ReadAll(r.Body)
+			assert.NilError(t, err)
+			assert.Equal(t, string(b), `{"device":"1","name":"NEW","value":"value"}`+"\n")
+			fmt.Fprint(w, `{"id":12,"name":"NEW","value":"value"}`)
+		default:
+			fmt.Fprint(w, "OK")
+		}
+	})
+	record(deviceConfVarBasePath, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			fmt.Fprint(w, `{"d":[]}`)
+		default:
+			fmt.Fprint(w, `{"id":21,"name":"BALENA_HOST_CONFIG_gpu_mem","value":"16"}`)
+		}
+	})
+	record(deviceServVarBasePath, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && strings.Contains(r.URL.RawQuery, "%24select=id"):
+			fmt.Fprint(w, `{"d":[{"id":42}]}`)
+		case r.Method == http.MethodGet:
+			fmt.Fprint(w, `{"d":[
+				{"id":41,"name":"KEY","value":"main","service_install":[
+					{"id":31,"installs__service":[{"id":7,"service_name":"main"}]}
+				]},
+				{"id":42,"name":"KEY","value":"sidecar","service_install":[
+					{"id":32,"installs__service":[{"id":8,"service_name":"sidecar"}]}
+				]}
+			]}`)
+		default:
+			fmt.Fprint(w, "OK")
+		}
+	})
+	// When
+	actual, err := client.Device.SyncVars(context.Background(), DeviceID(1), DeviceVars{
+		Env:     map[string]string{"NEW": "value"},
+		Config:  map[string]string{"BALENA_HOST_CONFIG_gpu_mem": "16"},
+		Service: map[string]map[string]string{"sidecar": {"KEY": "changed"}},
+	}, SyncVarsOptions{})
+	// Then
+	assert.NilError(t, err)
+	assert.Equal(t, len(actual), 4)
+	for _, change := range actual {
+		assert.Assert(t, change.Applied && change.Err == nil, "%s %s", change.Type, change.Name)
+	}
+	//nolint:lll
+	assert.DeepEqual(t, requests, []string{
+		"GET " + deviceEnvVarBasePath + " %24filter=device+eq+%271%27",
+		"GET " + deviceConfVarBasePath + " %24filter=device+eq+%271%27",
+		"GET " + deviceServVarBasePath + " %24filter=service_install/device+eq+%271%27&$expand=service_install($select=id,device,created_at;$expand=installs__service($select=id,service_name,created_at,application))",
+		"POST " + deviceEnvVarBasePath + " ",
+		"GET " + deviceEnvVarBasePath + " %24select=id&%24filter=device+eq+%271%27+and+name+eq+%27OLD%27",
+		"DELETE " + deviceEnvVarBasePath + " %24filter=id+eq+11",
+		"POST " + deviceConfVarBasePath + " ",
+		"GET " + deviceServVarBasePath + " %24select=id&%24filter=service_install/device+eq+%271%27+and+service_install/installs__service/service_name+eq+%27sidecar%27+and+name+eq+%27KEY%27",
+		"PATCH " + deviceServVarBasePath + " %24filter=id+eq+42",
+	})
+}
+
+func TestDeviceService_SyncVars_InvalidConfigVarName(t *testing.T) {
+	// Given
+	client, _, cleanup := newFixture()
+	defer cleanup()
+	// When
+	_, err := client.Device.SyncVars(context.Background(), DeviceID(1), DeviceVars{
+		Config: map[string]string{"KEY": "value"},
+	}, SyncVarsOptions{})
+	// Then
+	assert.Assert(t, errors.Is(err, ErrInvalidConfigVarName))
+}
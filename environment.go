@@ -0,0 +1,146 @@
+package balena
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// NewFromEnvironment returns a new Balena API client configured the same way as the balena CLI.
+//
+// The auth token is read from the BALENA_API_KEY or BALENARC_API_KEY environment variables, or else from the
+// token stored by `balena login` in the CLI data directory (`~/.balena/token` by default).
+//
+// The API URL is derived from the balenaUrl setting, e.g. `balena-cloud.com`, given by the BALENARC_BALENA_URL
+// environment variable or `~/.balenarc.yml`. The data directory can be changed the same way with
+// BALENARC_DATA_DIRECTORY or the dataDirectory setting.
+//
+// Session tokens are refreshed before they expire, see SessionTokenSource.
+// The given options are applied after the ones resolved from the environment.
+func NewFromEnvironment(opts ...Option) (*Client, error) {
+	settings, err := readBalenaSettings()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read balena CLI settings: %w", err)
+	}
+	token, err := settings.token()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read balena CLI token: %w", err)
+	}
+	var envOpts []Option
+	if settings.BalenaURL != "" && settings.BalenaURL != "balena-cloud.com" {
+		envOpts = append(envOpts, WithBaseURL("https://api."+settings.BalenaURL+"/"))
+	}
+	c, err := NewClient(token, append(envOpts, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+	if claims, err := parseTokenClaims(token); err == nil && claims.Expires != 0 && c.tokenSource == nil {
+		// Session tokens stored by `balena login` expire, so keep them fresh.
+		c.tokenSource = c.SessionTokenSource(token)
+	}
+	return c, nil
+}
+
+// balenaSettings are the settings of the balena CLI relevant to the client.
+type balenaSettings struct {
+	BalenaURL     string
+	DataDirectory string
+	APIKey        string
+}
+
+// readBalenaSettings reads the balena CLI settings from `~/.balenarc.yml`, overridden by
+// environment variables.
+func readBalenaSettings() (*balenaSettings, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	settings := &balenaSettings{DataDirectory: filepath.Join(home, ".balena")}
+	values, err := readBalenaRC(filepath.Join(home, ".balenarc.yml"))
+	if err != nil {
+		return nil, err
+	}
+	if v, ok := values["balenaUrl"]; ok {
+		settings.BalenaURL = v
+	}
+	if v, ok := values["dataDirectory"]; ok {
+		settings.DataDirectory = v
+	}
+	if v, ok := os.LookupEnv("BALENARC_BALENA_URL"); ok {
+		settings.BalenaURL = v
+	}
+	if v, ok := os.LookupEnv("BALENARC_DATA_DIRECTORY"); ok {
+		settings.DataDirectory = v
+	}
+	for _, key := range []string{"BALENA_API_KEY", "BALENARC_API_KEY"} {
+		if v := os.Getenv(key); v != "" {
+			settings.APIKey = v
+			break
+		}
+	}
+	return settings, nil
+}
+
+// token returns the API key from the environment, or else the token stored by the balena CLI.
+func (s *balenaSettings) token() (string, error) {
+	if s.APIKey != "" {
+		return s.APIKey, nil
+	}
+	b, err := os.ReadFile(filepath.Join(s.DataDirectory, "token"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", errors.New("not logged in, set BALENA_API_KEY or run `balena login`")
+		}
+		return "", err
+	}
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return "", errors.New("stored token is empty, run `balena login`")
+	}
+	return token, nil
+}
+
+// readBalenaRC reads the top-level `key: value` settings of a balenarc YAML file.
+// A missing file results in no settings. Nested structures are not supported and skipped.
+func readBalenaRC(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return map[string]string{}, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	values := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || line[0] == ' ' || line[0] == '\t' || line[0] == '#' {
+			continue
+		}
+		i := strings.IndexByte(line, ':')
+		if i < 0 {
+			continue
+		}
+		key := strings.TrimSpace(line[:i])
+		value := strings.TrimSpace(line[i+1:])
+		if value == "" {
+			continue
+		}
+		if value[0] == '"' || value[0] == '\'' {
+			if j := strings.IndexByte(value[1:], value[0]); j >= 0 {
+				value = value[1 : j+1]
+			}
+		} else if j := strings.Index(value, " #"); j >= 0 {
+			value = strings.TrimSpace(value[:j])
+		}
+		values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
@@ -0,0 +1,118 @@
+package balena
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func setupBalenaHome(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	for _, key := range []string{
+		"BALENA_API_KEY",
+		"BALENARC_API_KEY",
+		"BALENARC_BALENA_URL",
+		"BALENARC_DATA_DIRECTORY",
+	} {
+		t.Setenv(key, "")
+		assert.NilError(t, os.Unsetenv(key))
+	}
+	return home
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	assert.NilError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+	assert.NilError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+func TestNewFromEnvironment_APIKey(t *testing.T) {
+	// Given
+	home := setupBalenaHome(t)
+	writeFile(t, filepath.Join(home, ".balena", "token"), "stored-token")
+	t.Setenv("BALENA_API_KEY", "env-token")
+	// When
+	client, err := NewFromEnvironment()
+	// Then
+	assert.NilError(t, err)
+	assert.Equal(t, "env-token", client.authToken)
+	assert.Equal(t, defaultBaseURL, client.BaseURL.String())
+}
+
+func TestNewFromEnvironment_StoredToken(t *testing.T) {
+	// Given
+	home := setupBalenaHome(t)
+	writeFile(t, filepath.Join(home, ".balena", "token"), "stored-token\n")
+	// When
+	client, err := NewFromEnvironment()
+	// Then
+	assert.NilError(t, err)
+	assert.Equal(t, "stored-token", client.authToken)
+}
+
+func TestNewFromEnvironment_BalenaRC(t *testing.T) {
+	// Given
+	home := setupBalenaHome(t)
+	dataDir := filepath.Join(home, "balena-data")
+	writeFile(t, filepath.Join(home, ".balenarc.yml"), `# balena CLI settings
+balenaUrl: 'openbalena.example.com'
+dataDirectory: "`+dataDir+`"
+proxy:
+  host: proxy.example.com
+`)
+	writeFile(t, filepath.Join(dataDir, "token"), "custom-token")
+	// When
+	client, err := NewFromEnvironment()
+	// Then
+	assert.NilError(t, err)
+	assert.Equal(t, "custom-token", client.authToken)
+	assert.Equal(t, "https://api.openbalena.example.com/", client.BaseURL.String())
+}
+
+func TestNewFromEnvironment_EnvOverridesBalenaRC(t *testing.T) {
+	// Given
+	home := setupBalenaHome(t)
+	writeFile(t, filepath.Join(home, ".balenarc.yml"), "balenaUrl: openbalena.example.com # staging\n")
+	t.Setenv("BALENARC_BALENA_URL", "balena.example.org")
+	t.Setenv("BALENARC_API_KEY", "rc-token")
+	// When
+	client, err := NewFromEnvironment(WithUserAgentSuffix("test"))
+	// Then
+	assert.NilError(t, err)
+	assert.Equal(t, "rc-token", client.authToken)
+	assert.Equal(t, "https://api.balena.example.org/", client.BaseURL.String())
+	assert.Equal(t, userAgent+" test", client.UserAgent)
+}
+
+func TestNewFromEnvironment_NotLoggedIn(t *testing.T) {
+	// Given
+	setupBalenaHome(t)
+	// When
+	_, err := NewFromEnvironment()
+	// Then
+	assert.ErrorContains(t, err, "not logged in")
+}
+
+func TestReadBalenaRC(t *testing.T) {
+	// Given
+	path := filepath.Join(t.TempDir(), ".balenarc.yml")
+	writeFile(t, path, `balenaUrl: balena-cloud.com
+  nested: ignored
+empty:
+quoted: "a # b"
+comment: value # trailing
+`)
+	// When
+	values, err := readBalenaRC(path)
+	// Then
+	assert.NilError(t, err)
+	assert.DeepEqual(t, map[string]string{
+		"balenaUrl": "balena-cloud.com",
+		"quoted":    "a # b",
+		"comment":   "value",
+	}, values)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"go.einride.tech/balena"
+	"go.einride.tech/balena/odata"
 )
 
 func ExampleDeviceService_Get() {
@@ -27,3 +28,23 @@ func ExampleDeviceService_GetWithQuery() {
 
 	_, _ = client.Device.GetWithQuery(context.Background(), query)
 }
+
+func ExampleDeviceService_GetWithQuery_builder() {
+	token := "mytoken"
+	// We supply a nil http client to make use of http.DefaultClient
+	client := balena.New(nil, token)
+
+	// We want online devices with a given name, values are escaped by the builder
+	query, err := odata.NewQuery().
+		Filter(odata.And(
+			odata.Eq("device_name", "my device"),
+			odata.Eq("is_online", true),
+		)).
+		Select("id", "uuid", "device_name").
+		Encode()
+	if err != nil {
+		panic(err)
+	}
+
+	_, _ = client.Device.GetWithQuery(context.Background(), query)
+}
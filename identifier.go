@@ -1,6 +1,10 @@
 package balena
 
-import "strconv"
+import (
+	"strconv"
+
+	"go.einride.tech/balena/odata"
+)
 
 // IDOrUUID represents an ID which can be an Entity ID or an UUID.
 type IDOrUUID struct {
@@ -21,3 +25,46 @@ func DeviceID(id int64) IDOrUUID {
 		isUUID: false,
 	}
 }
+
+// filter returns an OData filter matching the device, where idField and uuidField are the
+// paths to the device ID and UUID of the filtered resource, e.g. `device` and `device/uuid`.
+func (d IDOrUUID) filter(idField, uuidField string) odata.Filter {
+	if d.isUUID {
+		return odata.Eq(uuidField, d.id)
+	}
+	return odata.Eq(idField, d.id)
+}
+
+// IDOrSlug represents an application by its ID or its slug, such as `myorg/myapp`.
+type IDOrSlug struct {
+	id     string
+	isSlug bool
+}
+
+func ApplicationID(id int64) IDOrSlug {
+	return IDOrSlug{
+		id:     strconv.FormatInt(id, 10),
+		isSlug: false,
+	}
+}
+
+func ApplicationSlug(slug string) IDOrSlug {
+	return IDOrSlug{
+		id:     slug,
+		isSlug: true,
+	}
+}
+
+// filter returns an OData filter matching the application, where idField and slugField are the
+// paths to the application ID and slug of the filtered resource, e.g. `application` and `application/slug`.
+func (a IDOrSlug) filter(idField, slugField string) odata.Filter {
+	if a.isSlug {
+		return odata.Eq(slugField, a.id)
+	}
+	return odata.Eq(idField, a.id)
+}
+
+// filterQuery returns an escaped raw query containing only the $filter option given by f.
+func filterQuery(f odata.Filter) (string, error) {
+	return odata.NewQuery().Filter(f).Encode()
+}
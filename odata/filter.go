@@ -0,0 +1,122 @@
+package odata
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// fieldPattern matches a property name or a navigation path such as `device/uuid`.
+var fieldPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(/[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// Filter is a boolean OData expression used as the value of the $filter query option.
+// The zero value is an empty filter which matches everything.
+type Filter struct {
+	expr     string
+	compound bool
+	err      error
+}
+
+// IsZero reports whether f is the empty filter.
+func (f Filter) IsZero() bool {
+	return f.expr == "" && f.err == nil
+}
+
+// Encode returns the filter expression escaped for use in a raw URL query.
+func (f Filter) Encode() (string, error) {
+	if f.err != nil {
+		return "", f.err
+	}
+	return f.expr, nil
+}
+
+// Eq returns a filter matching entities where field equals value.
+func Eq(field string, value interface{}) Filter {
+	return compare(field, "eq", value)
+}
+
+// Ne returns a filter matching entities where field does not equal value.
+func Ne(field string, value interface{}) Filter {
+	return compare(field, "ne", value)
+}
+
+// Gt returns a filter matching entities where field is greater than value.
+func Gt(field string, value interface{}) Filter {
+	return compare(field, "gt", value)
+}
+
+// Ge returns a filter matching entities where field is greater than or equal to value.
+func Ge(field string, value interface{}) Filter {
+	return compare(field, "ge", value)
+}
+
+// Lt returns a filter matching entities where field is less than value.
+func Lt(field string, value interface{}) Filter {
+	return compare(field, "lt", value)
+}
+
+// Le returns a filter matching entities where field is less than or equal to value.
+func Le(field string, value interface{}) Filter {
+	return compare(field, "le", value)
+}
+
+// And returns a filter matching entities matched by all of the given filters.
+// Empty filters are ignored.
+func And(filters ...Filter) Filter {
+	return join("and", filters)
+}
+
+// Or returns a filter matching entities matched by any of the given filters.
+// Empty filters are ignored.
+func Or(filters ...Filter) Filter {
+	return join("or", filters)
+}
+
+// Not returns a filter matching entities not matched by f.
+func Not(f Filter) Filter {
+	if f.err != nil {
+		return f
+	}
+	if f.IsZero() {
+		return Filter{err: fmt.Errorf("not: empty filter")}
+	}
+	return Filter{expr: "not+(" + f.expr + ")"}
+}
+
+func compare(field, op string, value interface{}) Filter {
+	if !fieldPattern.MatchString(field) {
+		return Filter{err: fmt.Errorf("%s: invalid field name %q", op, field)}
+	}
+	lit, err := Literal(value)
+	if err != nil {
+		return Filter{err: fmt.Errorf("%s %s: %w", field, op, err)}
+	}
+	return Filter{expr: field + "+" + op + "+" + lit}
+}
+
+func join(op string, filters []Filter) Filter {
+	nonEmpty := make([]Filter, 0, len(filters))
+	for _, f := range filters {
+		if f.err != nil {
+			return f
+		}
+		if !f.IsZero() {
+			nonEmpty = append(nonEmpty, f)
+		}
+	}
+	switch len(nonEmpty) {
+	case 0:
+		return Filter{}
+	case 1:
+		return nonEmpty[0]
+	}
+	parts := make([]string, 0, len(nonEmpty))
+	for _, f := range nonEmpty {
+		if f.compound {
+			parts = append(parts, "("+f.expr+")")
+		} else {
+			parts = append(parts, f.expr)
+		}
+	}
+	return Filter{expr: strings.Join(parts, "+"+op+"+"), compound: true}
+}
@@ -0,0 +1,79 @@
+package odata
+
+import (
+	"errors"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestFilter_Encode(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		filter   Filter
+		expected string
+	}{
+		{
+			name:     "empty",
+			filter:   Filter{},
+			expected: "",
+		},
+		{
+			name:     "eq",
+			filter:   Eq("device/uuid", "123"),
+			expected: "device/uuid+eq+%27123%27",
+		},
+		{
+			name:     "comparisons",
+			filter:   And(Ne("a", 1), Gt("b", 2), Ge("c", 3), Lt("d", 4), Le("e", 5)),
+			expected: "a+ne+1+and+b+gt+2+and+c+ge+3+and+d+lt+4+and+e+le+5",
+		},
+		{
+			name:     "nested",
+			filter:   And(Eq("is_online", true), Or(Eq("status", "idle"), Eq("status", "updating"))),
+			expected: "is_online+eq+true+and+(status+eq+%27idle%27+or+status+eq+%27updating%27)",
+		},
+		{
+			name:     "not",
+			filter:   Not(Or(Eq("a", nil), Eq("b", nil))),
+			expected: "not+(a+eq+null+or+b+eq+null)",
+		},
+		{
+			name:     "single operand",
+			filter:   And(Filter{}, Eq("a", "b"), Filter{}),
+			expected: "a+eq+%27b%27",
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// When
+			actual, err := tt.filter.Encode()
+			// Then
+			assert.NilError(t, err)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestFilter_Encode_Errors(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		filter Filter
+	}{
+		{name: "invalid field", filter: Eq("name eq 'x' or id", 1)},
+		{name: "invalid literal", filter: Eq("name", "\xff")},
+		{name: "propagated through and", filter: And(Eq("a", 1), Eq("b", struct{}{}))},
+		{name: "propagated through or", filter: Or(Eq("a", 1), Eq("b", struct{}{}))},
+		{name: "empty not", filter: Not(Filter{})},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// When
+			_, err := tt.filter.Encode()
+			// Then
+			assert.Assert(t, err != nil)
+		})
+	}
+	_, err := Eq("name", "\xff").Encode()
+	assert.Assert(t, errors.Is(err, ErrInvalidLiteral))
+}
@@ -0,0 +1,81 @@
+package odata
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrInvalidLiteral is returned when a value cannot be represented as an OData literal.
+var ErrInvalidLiteral = errors.New("invalid OData literal")
+
+// QuoteString returns s as a single-quoted OData string literal, escaped for use in a raw URL query.
+// Single quotes within s are doubled as required by OData and the result is percent-encoded,
+// so `it's` becomes `%27it%27%27s%27`.
+//
+// An error wrapping ErrInvalidLiteral is returned if s is not valid UTF-8 or contains a NUL byte.
+func QuoteString(s string) (string, error) {
+	if !utf8.ValidString(s) {
+		return "", fmt.Errorf("%w: %q is not valid UTF-8", ErrInvalidLiteral, s)
+	}
+	if strings.IndexByte(s, 0) >= 0 {
+		return "", fmt.Errorf("%w: %q contains a NUL byte", ErrInvalidLiteral, s)
+	}
+	return url.QueryEscape("'" + strings.ReplaceAll(s, "'", "''") + "'"), nil
+}
+
+// Literal returns v as an OData literal, escaped for use in a raw URL query.
+//
+// Supported types are nil, bool, string, all integer and floating point types and time.Time, which is
+// rendered as a quoted RFC 3339 timestamp in UTC.
+func Literal(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "null", nil
+	case string:
+		return QuoteString(v)
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int8:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int16:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int32:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint8:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float32:
+		return formatFloat(float64(v), 32)
+	case float64:
+		return formatFloat(v, 64)
+	case time.Time:
+		return QuoteString(v.UTC().Format(time.RFC3339Nano))
+	default:
+		return "", fmt.Errorf("%w: unsupported type %T", ErrInvalidLiteral, v)
+	}
+}
+
+func formatFloat(f float64, bitSize int) (string, error) {
+	// Inf and NaN have no portable OData representation.
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return "", fmt.Errorf("%w: %v", ErrInvalidLiteral, f)
+	}
+	return strconv.FormatFloat(f, 'f', -1, bitSize), nil
+}
@@ -0,0 +1,75 @@
+package odata
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestQuoteString(t *testing.T) {
+	for _, tt := range []struct {
+		in       string
+		expected string
+	}{
+		{in: "mydevice", expected: "%27mydevice%27"},
+		{in: "", expected: "%27%27"},
+		{in: "it's", expected: "%27it%27%27s%27"},
+		{in: "a b", expected: "%27a+b%27"},
+		{in: "a+b", expected: "%27a%2Bb%27"},
+		{in: "x' or '1' eq '1", expected: "%27x%27%27+or+%27%271%27%27+eq+%27%271%27"},
+		{in: "k&%24filter=id", expected: "%27k%26%2524filter%3Did%27"},
+		{in: "Västra", expected: "%27V%C3%A4stra%27"},
+	} {
+		tt := tt
+		t.Run(tt.in, func(t *testing.T) {
+			// When
+			actual, err := QuoteString(tt.in)
+			// Then
+			assert.NilError(t, err)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestQuoteString_Invalid(t *testing.T) {
+	for _, in := range []string{"\xff", "a\x00b"} {
+		// When
+		_, err := QuoteString(in)
+		// Then
+		assert.Assert(t, errors.Is(err, ErrInvalidLiteral))
+	}
+}
+
+func TestLiteral(t *testing.T) {
+	for _, tt := range []struct {
+		in       interface{}
+		expected string
+	}{
+		{in: nil, expected: "null"},
+		{in: true, expected: "true"},
+		{in: 42, expected: "42"},
+		{in: int64(-7), expected: "-7"},
+		{in: uint8(8), expected: "8"},
+		{in: 1.5, expected: "1.5"},
+		{in: "42", expected: "%2742%27"},
+		{in: time.Date(2021, 5, 11, 8, 5, 16, 0, time.UTC), expected: "%272021-05-11T08%3A05%3A16Z%27"},
+	} {
+		// When
+		actual, err := Literal(tt.in)
+		// Then
+		assert.NilError(t, err)
+		assert.Equal(t, tt.expected, actual)
+	}
+}
+
+func TestLiteral_Invalid(t *testing.T) {
+	for _, in := range []interface{}{math.NaN(), math.Inf(1), struct{}{}, []string{"a"}} {
+		// When
+		_, err := Literal(in)
+		// Then
+		assert.Assert(t, errors.Is(err, ErrInvalidLiteral))
+	}
+}
@@ -0,0 +1,169 @@
+package odata
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Order is the sort direction used by the $orderby query option.
+type Order string
+
+const (
+	Asc  Order = "asc"
+	Desc Order = "desc"
+)
+
+// Query builds a set of OData query options such as $filter, $select and $expand.
+// The zero value is an empty query. Methods modify and return the receiver so that calls can be chained:
+//
+//	query, err := odata.NewQuery().
+//		Filter(odata.Eq("device_name", "my device")).
+//		Select("id", "uuid").
+//		Top(10).
+//		Encode()
+type Query struct {
+	filter  Filter
+	selects []string
+	expands []expand
+	orderBy []string
+	top     int
+	hasTop  bool
+	skip    int
+	hasSkip bool
+	count   bool
+	err     error
+}
+
+type expand struct {
+	field   string
+	options *Query
+}
+
+// NewQuery returns a new empty query.
+func NewQuery() *Query {
+	return &Query{}
+}
+
+// Filter sets the $filter query option. Calling Filter again combines the filters with And.
+func (q *Query) Filter(f Filter) *Query {
+	q.filter = And(q.filter, f)
+	return q
+}
+
+// Select adds fields to the $select query option.
+func (q *Query) Select(fields ...string) *Query {
+	for _, field := range fields {
+		q.validateField("$select", field)
+	}
+	q.selects = append(q.selects, fields...)
+	return q
+}
+
+// Expand adds a navigation property to the $expand query option.
+// Nested query options for the expanded property can be given by options, which may be nil.
+func (q *Query) Expand(field string, options *Query) *Query {
+	q.validateField("$expand", field)
+	q.expands = append(q.expands, expand{field: field, options: options})
+	return q
+}
+
+// OrderBy adds a field to the $orderby query option.
+func (q *Query) OrderBy(field string, order Order) *Query {
+	q.validateField("$orderby", field)
+	if order != Asc && order != Desc {
+		q.setErr(fmt.Errorf("$orderby: invalid order %q", order))
+	}
+	q.orderBy = append(q.orderBy, field+"+"+string(order))
+	return q
+}
+
+// Top sets the $top query option, limiting the number of returned entities.
+func (q *Query) Top(n int) *Query {
+	if n < 0 {
+		q.setErr(fmt.Errorf("$top: negative value %d", n))
+	}
+	q.top, q.hasTop = n, true
+	return q
+}
+
+// Skip sets the $skip query option, skipping the given number of entities.
+func (q *Query) Skip(n int) *Query {
+	if n < 0 {
+		q.setErr(fmt.Errorf("$skip: negative value %d", n))
+	}
+	q.skip, q.hasSkip = n, true
+	return q
+}
+
+// Count sets the $count query option, requesting the total number of matching entities.
+func (q *Query) Count() *Query {
+	q.count = true
+	return q
+}
+
+// Encode returns the query options as an escaped raw query string,
+// suitable for any of the GetWithQuery methods of the balena client.
+func (q *Query) Encode() (string, error) {
+	return q.encode("&")
+}
+
+func (q *Query) encode(sep string) (string, error) {
+	if q == nil {
+		return "", nil
+	}
+	if q.err != nil {
+		return "", q.err
+	}
+	var opts []string
+	if len(q.selects) > 0 {
+		opts = append(opts, "%24select="+strings.Join(q.selects, ","))
+	}
+	if len(q.expands) > 0 {
+		expands := make([]string, 0, len(q.expands))
+		for _, e := range q.expands {
+			nested, err := e.options.encode(";")
+			if err != nil {
+				return "", fmt.Errorf("$expand %s: %w", e.field, err)
+			}
+			if nested != "" {
+				expands = append(expands, e.field+"("+nested+")")
+			} else {
+				expands = append(expands, e.field)
+			}
+		}
+		opts = append(opts, "%24expand="+strings.Join(expands, ","))
+	}
+	if !q.filter.IsZero() {
+		filter, err := q.filter.Encode()
+		if err != nil {
+			return "", fmt.Errorf("$filter: %w", err)
+		}
+		opts = append(opts, "%24filter="+filter)
+	}
+	if len(q.orderBy) > 0 {
+		opts = append(opts, "%24orderby="+strings.Join(q.orderBy, ","))
+	}
+	if q.hasTop {
+		opts = append(opts, "%24top="+strconv.Itoa(q.top))
+	}
+	if q.hasSkip {
+		opts = append(opts, "%24skip="+strconv.Itoa(q.skip))
+	}
+	if q.count {
+		opts = append(opts, "%24count=true")
+	}
+	return strings.Join(opts, sep), nil
+}
+
+func (q *Query) validateField(option, field string) {
+	if !fieldPattern.MatchString(field) {
+		q.setErr(fmt.Errorf("%s: invalid field name %q", option, field))
+	}
+}
+
+func (q *Query) setErr(err error) {
+	if q.err == nil {
+		q.err = err
+	}
+}
@@ -0,0 +1,98 @@
+package odata
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestQuery_Encode(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		query    *Query
+		expected string
+	}{
+		{
+			name:     "nil",
+			query:    nil,
+			expected: "",
+		},
+		{
+			name:     "empty",
+			query:    NewQuery(),
+			expected: "",
+		},
+		{
+			name:     "filter",
+			query:    NewQuery().Filter(Eq("device_name", "it's mine")),
+			expected: "%24filter=device_name+eq+%27it%27%27s+mine%27",
+		},
+		{
+			name:     "filters are combined",
+			query:    NewQuery().Filter(Eq("a", 1)).Filter(Eq("b", 2)),
+			expected: "%24filter=a+eq+1+and+b+eq+2",
+		},
+		{
+			name: "all options",
+			query: NewQuery().
+				Select("id", "uuid").
+				Filter(Eq("is_online", true)).
+				OrderBy("id", Asc).
+				OrderBy("device_name", Desc).
+				Top(10).
+				Skip(20).
+				Count(),
+			expected: "%24select=id,uuid&%24filter=is_online+eq+true&%24orderby=id+asc,device_name+desc" +
+				"&%24top=10&%24skip=20&%24count=true",
+		},
+		{
+			name: "nested expand",
+			query: NewQuery().
+				Filter(Eq("service_install/device/uuid", "abc")).
+				Expand("service_install", NewQuery().
+					Select("id", "device", "created_at").
+					Expand("installs__service", NewQuery().Select("id", "service_name"))),
+			expected: "%24expand=service_install(%24select=id,device,created_at;" +
+				"%24expand=installs__service(%24select=id,service_name))" +
+				"&%24filter=service_install/device/uuid+eq+%27abc%27",
+		},
+		{
+			name:     "expand without options",
+			query:    NewQuery().Expand("device_tag", nil).Expand("device_type", NewQuery()),
+			expected: "%24expand=device_tag,device_type",
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// When
+			actual, err := tt.query.Encode()
+			// Then
+			assert.NilError(t, err)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestQuery_Encode_Errors(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		query *Query
+	}{
+		{name: "invalid select", query: NewQuery().Select("id&x=y")},
+		{name: "invalid expand", query: NewQuery().Expand("a(b)", nil)},
+		{name: "invalid nested", query: NewQuery().Expand("a", NewQuery().Filter(Eq("b", "\xff")))},
+		{name: "invalid orderby field", query: NewQuery().OrderBy("a b", Asc)},
+		{name: "invalid order", query: NewQuery().OrderBy("a", "up")},
+		{name: "negative top", query: NewQuery().Top(-1)},
+		{name: "negative skip", query: NewQuery().Skip(-1)},
+		{name: "invalid filter", query: NewQuery().Filter(Eq("a", struct{}{}))},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// When
+			_, err := tt.query.Encode()
+			// Then
+			assert.Assert(t, err != nil)
+		})
+	}
+}
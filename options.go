@@ -0,0 +1,150 @@
+package balena
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Option configures a Client created by New or NewClient.
+type Option func(*Client) error
+
+// Logger is used by a Client to log requests. It is implemented by *log.Logger.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// WithHTTPClient sets the HTTP client used to communicate with the API. Defaults to a new http.Client.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) error {
+		if httpClient == nil {
+			return errors.New("http client must not be nil")
+		}
+		c.client = httpClient
+		return nil
+	}
+}
+
+// WithBaseURL sets the base URL for API requests, which must have a trailing slash.
+// Defaults to the public balena cloud API.
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) error {
+		u, err := url.Parse(baseURL)
+		if err != nil {
+			return fmt.Errorf("invalid base URL: %w", err)
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("base URL must be absolute, but %q is not", baseURL)
+		}
+		if !strings.HasSuffix(u.Path, "/") {
+			return fmt.Errorf("BaseURL must have a trailing slash, but %q does not", baseURL)
+		}
+		c.BaseURL = u
+		return nil
+	}
+}
+
+// WithUserAgentSuffix appends suffix to the default User-Agent header, identifying the calling application.
+func WithUserAgentSuffix(suffix string) Option {
+	return func(c *Client) error {
+		c.UserAgent = userAgent + " " + suffix
+		return nil
+	}
+}
+
+// WithRetryPolicy sets the policy for retrying failed requests. See DefaultRetryPolicy.
+func WithRetryPolicy(policy *RetryPolicy) Option {
+	return func(c *Client) error {
+		c.RetryPolicy = policy
+		return nil
+	}
+}
+
+// WithRateLimiter sets a rate limiter that every request waits on. See NewRateLimiter.
+func WithRateLimiter(limiter *RateLimiter) Option {
+	return func(c *Client) error {
+		c.RateLimiter = limiter
+		return nil
+	}
+}
+
+// WithPageSize sets the number of entities fetched per request by paginated methods.
+func WithPageSize(size int) Option {
+	return func(c *Client) error {
+		if size <= 0 {
+			return fmt.Errorf("page size must be positive, got %d", size)
+		}
+		c.PageSize = size
+		return nil
+	}
+}
+
+// WithLogger sets a logger for requests made by the client.
+// Only methods and paths are logged, query strings and headers are left out as they may contain credentials.
+func WithLogger(logger Logger) Option {
+	return func(c *Client) error {
+		c.logger = logger
+		return nil
+	}
+}
+
+// WithTimeout sets a default timeout for requests sent with Client.Do, including retries and reading the
+// response. A deadline set on the request context takes precedence if it is earlier.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) error {
+		if timeout <= 0 {
+			return fmt.Errorf("timeout must be positive, got %v", timeout)
+		}
+		c.timeout = timeout
+		return nil
+	}
+}
+
+// WithStrictMatching makes filter-based updates and deletes, such as DeviceEnvVarService.Update and
+// DeviceTagService.DeleteWithKey, return an error wrapping ErrNotFound when no entity matches, instead of
+// silently doing nothing. The matching entities are listed with an additional request, and only they are updated
+// or deleted. Variants reporting the number of matching entities, such as DeviceEnvVarService.UpdateCount, report
+// no match as zero instead.
+func WithStrictMatching() Option {
+	return func(c *Client) error {
+		c.strictMatching = true
+		return nil
+	}
+}
+
+// WithMiddleware wraps the transport of the HTTP client with the given middlewares. The first middleware is
+// the outermost one, seeing requests first. The HTTP client given with WithHTTPClient is not modified.
+func WithMiddleware(middlewares ...func(http.RoundTripper) http.RoundTripper) Option {
+	return func(c *Client) error {
+		c.middlewares = append(c.middlewares, middlewares...)
+		return nil
+	}
+}
+
+// applyMiddlewares replaces the HTTP client of c with a copy using a transport wrapped by the configured
+// middlewares.
+func (c *Client) applyMiddlewares() {
+	if len(c.middlewares) == 0 {
+		return
+	}
+	httpClient := *c.client
+	transport := httpClient.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		transport = c.middlewares[i](transport)
+	}
+	httpClient.Transport = transport
+	c.client = &httpClient
+	c.middlewares = nil
+}
+
+func (c *Client) logf(format string, v ...interface{}) {
+	if c.logger != nil {
+		c.logger.Printf(format, v...)
+	}
+}
@@ -0,0 +1,188 @@
+package balena
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestNewClient_Defaults(t *testing.T) {
+	// When
+	client, err := NewClient("token")
+	// Then
+	assert.NilError(t, err)
+	assert.Equal(t, defaultBaseURL, client.BaseURL.String())
+	assert.Equal(t, userAgent, client.UserAgent)
+	assert.Assert(t, client.RetryPolicy == nil)
+	assert.Assert(t, client.RateLimiter == nil)
+}
+
+func TestNewClient_Options(t *testing.T) {
+	// Given
+	policy := DefaultRetryPolicy()
+	limiter := NewRateLimiter(10, 10)
+	// When
+	client, err := NewClient(
+		"token",
+		WithBaseURL("https://api.balena.example.com/"),
+		WithUserAgentSuffix("my-tool/1.0"),
+		WithRetryPolicy(policy),
+		WithRateLimiter(limiter),
+		WithPageSize(50),
+		WithTimeout(time.Minute),
+	)
+	// Then
+	assert.NilError(t, err)
+	assert.Equal(t, "https://api.balena.example.com/", client.BaseURL.String())
+	assert.Equal(t, userAgent+" my-tool/1.0", client.UserAgent)
+	assert.Equal(t, policy, client.RetryPolicy)
+	assert.Equal(t, limiter, client.RateLimiter)
+	assert.Equal(t, 50, client.PageSize)
+	assert.Equal(t, time.Minute, client.timeout)
+}
+
+func TestNewClient_InvalidOptions(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		opt  Option
+	}{
+		{name: "missing trailing slash", opt: WithBaseURL("https://api.balena.example.com/v6")},
+		{name: "relative base URL", opt: WithBaseURL("api.balena.example.com/")},
+		{name: "unparsable base URL", opt: WithBaseURL(":/")},
+		{name: "nil http client", opt: WithHTTPClient(nil)},
+		{name: "zero page size", opt: WithPageSize(0)},
+		{name: "negative timeout", opt: WithTimeout(-time.Second)},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// When
+			_, err := NewClient("token", tt.opt)
+			// Then
+			assert.ErrorContains(t, err, "invalid client option")
+		})
+	}
+}
+
+func TestWithMiddleware(t *testing.T) {
+	// Given
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Header.Get("X-Order"))
+	}))
+	defer server.Close()
+	httpClient := &http.Client{}
+	header := func(value string) func(http.RoundTripper) http.RoundTripper {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				r.Header.Add("X-Order", value)
+				return next.RoundTrip(r)
+			})
+		}
+	}
+	client, err := NewClient(
+		"token",
+		WithHTTPClient(httpClient),
+		WithBaseURL(server.URL+"/"),
+		WithMiddleware(header("first"), header("second")),
+	)
+	assert.NilError(t, err)
+	req, err := client.NewRequest(context.Background(), http.MethodGet, "foo", "", nil)
+	assert.NilError(t, err)
+	var buf bytes.Buffer
+	// When
+	err = client.Do(req, &buf)
+	// Then
+	assert.NilError(t, err)
+	assert.Equal(t, "first", buf.String())
+	assert.Assert(t, httpClient.Transport == nil, "given http client must not be modified")
+}
+
+func TestWithTimeout(t *testing.T) {
+	// Given
+	done := make(chan struct{})
+	defer close(done)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	client, err := NewClient("token", WithBaseURL(server.URL+"/"), WithTimeout(10*time.Millisecond))
+	assert.NilError(t, err)
+	req, err := client.NewRequest(context.Background(), http.MethodGet, "foo", "", nil)
+	assert.NilError(t, err)
+	// When
+	err = client.Do(req, nil)
+	// Then
+	assert.Assert(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestWithLogger(t *testing.T) {
+	// Given
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "OK")
+	}))
+	defer server.Close()
+	var buf bytes.Buffer
+	client, err := NewClient("token", WithBaseURL(server.URL+"/"), WithLogger(log.New(&buf, "", 0)))
+	assert.NilError(t, err)
+	req, err := client.NewRequest(context.Background(), http.MethodGet, "foo", "apikey=secret", nil)
+	assert.NilError(t, err)
+	// When
+	err = client.Do(req, nil)
+	// Then
+	assert.NilError(t, err)
+	assert.Assert(t, bytes.HasPrefix(buf.Bytes(), []byte("GET /foo: 200 after")), buf.String())
+	assert.Assert(t, !bytes.Contains(buf.Bytes(), []byte("secret")))
+}
+
+func TestWithLogger_TransportError(t *testing.T) {
+	// Given
+	var buf bytes.Buffer
+	client, err := NewClient(
+		"token",
+		WithBaseURL("https://api.balena.example.com/"),
+		WithHTTPClient(&http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+			return nil, errors.New("connection refused")
+		})}),
+		WithLogger(log.New(&buf, "", 0)),
+	)
+	assert.NilError(t, err)
+	req, err := client.NewRequest(context.Background(), http.MethodGet, "foo", "apikey=secret", nil)
+	assert.NilError(t, err)
+	// When
+	err = client.Do(req, nil)
+	// Then
+	assert.ErrorContains(t, err, "connection refused")
+	assert.Assert(t, bytes.Contains(buf.Bytes(), []byte("GET /foo: attempt 1 failed after")), buf.String())
+	assert.Assert(t, bytes.Contains(buf.Bytes(), []byte(": connection refused")), buf.String())
+	assert.Assert(t, !bytes.Contains(buf.Bytes(), []byte("secret")), buf.String())
+}
+
+func TestNew_Options(t *testing.T) {
+	// Given
+	httpClient := &http.Client{}
+	// When
+	client := New(httpClient, "token", WithBaseURL("https://api.balena.example.com/"), WithPageSize(50))
+	// Then
+	assert.Equal(t, httpClient, client.client)
+	assert.Equal(t, "https://api.balena.example.com/", client.BaseURL.String())
+	assert.Equal(t, 50, client.PageSize)
+}
+
+func TestNew_InvalidOption(t *testing.T) {
+	// Given
+	client := New(nil, "token", WithBaseURL("https://api.balena.example.com/v6"))
+	// When
+	_, err := client.NewRequest(context.Background(), http.MethodGet, "foo", "", nil)
+	// Then
+	assert.ErrorContains(t, err, "invalid client option")
+}
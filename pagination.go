@@ -0,0 +1,68 @@
+package balena
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// defaultPageSize is the number of entities fetched per request by paginated methods
+// unless Client.PageSize is set.
+const defaultPageSize = 1000
+
+// pager fetches consecutive pages of a resource using $top and $skip.
+// Entities are ordered by ID so that pages are stable between requests.
+type pager struct {
+	client *Client
+	path   string
+	query  string
+	size   int
+	skip   int
+}
+
+func (c *Client) newPager(path string, query string) (*pager, error) {
+	for _, opt := range strings.Split(query, "&") {
+		key := opt
+		if i := strings.IndexByte(opt, '='); i >= 0 {
+			key = opt[:i]
+		}
+		key, err := url.QueryUnescape(key)
+		if err != nil {
+			return nil, err
+		}
+		switch key {
+		case "$top", "$skip", "$orderby":
+			return nil, fmt.Errorf("paginated query must not contain %s", key)
+		}
+	}
+	size := c.PageSize
+	if size <= 0 {
+		size = defaultPageSize
+	}
+	return &pager{client: c, path: path, query: query, size: size}, nil
+}
+
+// next fetches the next page and decodes it into v.
+func (p *pager) next(ctx context.Context, v interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	query := "%24orderby=id+asc&%24top=" + strconv.Itoa(p.size) + "&%24skip=" + strconv.Itoa(p.skip)
+	if p.query != "" {
+		query = p.query + "&" + query
+	}
+	req, err := p.client.NewRequest(ctx, http.MethodGet, p.path, query, nil)
+	if err != nil {
+		return err
+	}
+	return p.client.Do(req, v)
+}
+
+// more advances the pager past a page containing n entities and reports whether more pages may follow.
+func (p *pager) more(n int) bool {
+	p.skip += n
+	return n >= p.size
+}
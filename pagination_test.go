@@ -0,0 +1,131 @@
+package balena
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestNewPager_RejectsPaginationOptions(t *testing.T) {
+	client := New(nil, "")
+	for _, query := range []string{
+		"%24top=10",
+		"$skip=10",
+		"%24filter=id+eq+1&%24orderby=id+desc",
+	} {
+		// When
+		_, err := client.newPager(deviceBasePath, query)
+		// Then
+		assert.ErrorContains(t, err, "paginated query must not contain")
+	}
+}
+
+func TestPager_Pages(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	client.PageSize = 2
+	var queries []string
+	mux.HandleFunc("/"+deviceBasePath, func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		queries = append(queries, r.URL.RawQuery)
+		switch r.URL.Query().Get("$skip") {
+		case "0":
+			fmt.Fprint(w, `{"d":[{"id":1},{"id":2}]}`)
+		case "2":
+			fmt.Fprint(w, `{"d":[{"id":3}]}`)
+		default:
+			http.Error(w, "unexpected page", http.StatusInternalServerError)
+		}
+	})
+	var ids []int64
+	// When
+	err := client.Device.ForEachPage(
+		context.Background(),
+		"%24filter=is_online+eq+true",
+		func(page []*DeviceResponse) error {
+			for _, device := range page {
+				ids = append(ids, device.ID)
+			}
+			return nil
+		},
+	)
+	// Then
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []int64{1, 2, 3}, ids)
+	assert.DeepEqual(t, []string{
+		"%24filter=is_online+eq+true&%24orderby=id+asc&%24top=2&%24skip=0",
+		"%24filter=is_online+eq+true&%24orderby=id+asc&%24top=2&%24skip=2",
+	}, queries)
+}
+
+func TestPager_FullLastPage(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	client.PageSize = 1
+	requests := 0
+	mux.HandleFunc("/"+releaseBasePath, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Query().Get("$skip") == "0" {
+			fmt.Fprint(w, `{"d":[{"id":1}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"d":[]}`)
+	})
+	// When
+	err := client.Release.ForEach(context.Background(), "", func(*ReleaseResponse) error {
+		return nil
+	})
+	// Then
+	assert.NilError(t, err)
+	assert.Equal(t, 2, requests)
+}
+
+func TestPager_StopsOnError(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	client.PageSize = 2
+	requests := 0
+	mux.HandleFunc("/"+applicationBasePath, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprint(w, `{"d":[{"id":1},{"id":2}]}`)
+	})
+	errStop := errors.New("stop")
+	// When
+	err := client.Application.ForEach(context.Background(), "", func(app *ApplicationsResponse) error {
+		if app.ID == 2 {
+			return errStop
+		}
+		return nil
+	})
+	// Then
+	assert.Assert(t, errors.Is(err, errStop))
+	assert.Equal(t, 1, requests)
+}
+
+func TestPager_ContextCanceled(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	client.PageSize = 2
+	mux.HandleFunc("/"+deviceBasePath, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"d":[{"id":1},{"id":2}]}`)
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	var ids []int64
+	// When
+	err := client.Device.ForEach(ctx, "", func(device *DeviceResponse) error {
+		ids = append(ids, device.ID)
+		cancel()
+		return nil
+	})
+	// Then
+	assert.Assert(t, errors.Is(err, context.Canceled))
+	assert.DeepEqual(t, []int64{1}, ids)
+}
@@ -0,0 +1,97 @@
+package balena
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket limiting the rate of requests sent by a Client.
+// Tokens are replenished at a fixed rate up to the burst size, and each request consumes a number
+// of tokens given by the weight of its method.
+//
+// A RateLimiter is safe for concurrent use and may be shared between several clients.
+type RateLimiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	tokens  float64
+	last    time.Time
+	weights map[string]float64
+	now     func() time.Time
+}
+
+// NewRateLimiter returns a RateLimiter allowing on average requestsPerSecond requests per second,
+// with bursts of up to burst requests. Requests have a weight of 1 unless set with SetWeight.
+func NewRateLimiter(requestsPerSecond float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:    requestsPerSecond,
+		burst:   float64(burst),
+		tokens:  float64(burst),
+		weights: map[string]float64{},
+		now:     time.Now,
+	}
+}
+
+// SetWeight sets the number of tokens consumed by requests with the given HTTP method.
+func (l *RateLimiter) SetWeight(method string, weight float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.weights[method] = weight
+}
+
+// Wait blocks until a request with the given HTTP method may be sent.
+// An error is returned without waiting if ctx would expire before then, or if ctx is done while waiting.
+func (l *RateLimiter) Wait(ctx context.Context, method string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	weight, ok := l.weights[method]
+	if !ok {
+		weight = 1
+	}
+	now := l.now()
+	l.refill(now)
+	var delay time.Duration
+	if l.tokens < weight {
+		if l.rate <= 0 {
+			l.mu.Unlock()
+			return errors.New("rate limiter does not replenish tokens")
+		}
+		delay = time.Duration((weight - l.tokens) / l.rate * float64(time.Second))
+	}
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(now.Add(delay)) {
+		l.mu.Unlock()
+		return errors.New("rate limit wait would exceed context deadline")
+	}
+	// Reserve the tokens up front so that concurrent waiters queue up behind each other.
+	l.tokens -= weight
+	l.mu.Unlock()
+	if delay == 0 {
+		return nil
+	}
+	if err := sleep(ctx, delay); err != nil {
+		l.mu.Lock()
+		l.tokens = math.Min(l.tokens+weight, l.burst)
+		l.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// refill adds the tokens accumulated since the last refill. Callers must hold l.mu.
+func (l *RateLimiter) refill(now time.Time) {
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+}
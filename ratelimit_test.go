@@ -0,0 +1,110 @@
+package balena
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestRateLimiter_Burst(t *testing.T) {
+	// Given
+	limiter := NewRateLimiter(1, 3)
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+	ctx, cancel := context.WithDeadline(context.Background(), now.Add(500*time.Millisecond))
+	defer cancel()
+	// When
+	for i := 0; i < 3; i++ {
+		assert.NilError(t, limiter.Wait(ctx, http.MethodGet))
+	}
+	err := limiter.Wait(ctx, http.MethodGet)
+	// Then
+	assert.ErrorContains(t, err, "would exceed context deadline")
+}
+
+func TestRateLimiter_Refill(t *testing.T) {
+	// Given
+	limiter := NewRateLimiter(2, 1)
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+	ctx, cancel := context.WithDeadline(context.Background(), now.Add(time.Minute))
+	defer cancel()
+	assert.NilError(t, limiter.Wait(ctx, http.MethodGet))
+	// When
+	now = now.Add(500 * time.Millisecond)
+	err := limiter.Wait(ctx, http.MethodGet)
+	// Then
+	assert.NilError(t, err)
+	assert.Equal(t, 0.0, limiter.tokens)
+}
+
+func TestRateLimiter_Weight(t *testing.T) {
+	// Given
+	limiter := NewRateLimiter(1, 5)
+	limiter.SetWeight(http.MethodPost, 5)
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+	ctx, cancel := context.WithDeadline(context.Background(), now.Add(500*time.Millisecond))
+	defer cancel()
+	assert.NilError(t, limiter.Wait(ctx, http.MethodGet))
+	// When
+	err := limiter.Wait(ctx, http.MethodPost)
+	// Then
+	assert.ErrorContains(t, err, "would exceed context deadline")
+	assert.Equal(t, 4.0, limiter.tokens)
+}
+
+func TestRateLimiter_Wait(t *testing.T) {
+	// Given
+	limiter := NewRateLimiter(100, 1)
+	start := time.Now()
+	// When
+	for i := 0; i < 3; i++ {
+		assert.NilError(t, limiter.Wait(context.Background(), http.MethodGet))
+	}
+	// Then
+	assert.Assert(t, time.Since(start) >= 15*time.Millisecond)
+}
+
+func TestRateLimiter_Canceled(t *testing.T) {
+	// Given
+	limiter := NewRateLimiter(0.001, 1)
+	assert.NilError(t, limiter.Wait(context.Background(), http.MethodGet))
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(time.Millisecond, cancel)
+	// When
+	err := limiter.Wait(ctx, http.MethodGet)
+	// Then
+	assert.Assert(t, errors.Is(err, context.Canceled))
+	assert.Assert(t, limiter.tokens < 1)
+}
+
+func TestDo_RateLimiter(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	client.RateLimiter = NewRateLimiter(0.001, 2)
+	requests := 0
+	mux.HandleFunc("/foo", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprint(w, "OK")
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	// When
+	var err error
+	for i := 0; i < 3 && err == nil; i++ {
+		var req *http.Request
+		req, err = client.NewRequest(ctx, http.MethodGet, "foo", "", nil)
+		assert.NilError(t, err)
+		err = client.Do(req, nil)
+	}
+	// Then
+	assert.ErrorContains(t, err, "would exceed context deadline")
+	assert.Equal(t, 2, requests)
+}
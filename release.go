@@ -44,6 +44,47 @@ func (s *ReleaseService) List(ctx context.Context) ([]*ReleaseResponse, error) {
 	return s.GetWithQuery(ctx, "")
 }
 
+// ForEach calls fn for each release matching the query, fetching releases page by page as they are needed.
+// The query should be a valid, escaped OData query as accepted by GetWithQuery. It must not contain $orderby,
+// $top or $skip, which are used for paginating by release ID.
+// Iteration stops at the first error returned by fn, which is then returned, or when ctx is done.
+func (s *ReleaseService) ForEach(ctx context.Context, query string, fn func(*ReleaseResponse) error) error {
+	return s.ForEachPage(ctx, query, func(page []*ReleaseResponse) error {
+		for _, release := range page {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := fn(release); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ForEachPage is like ForEach, but calls fn once per page of releases.
+func (s *ReleaseService) ForEachPage(ctx context.Context, query string, fn func([]*ReleaseResponse) error) error {
+	p, err := s.client.newPager(releaseBasePath, query)
+	if err != nil {
+		return fmt.Errorf("unable to create release request: %w", err)
+	}
+	for {
+		type Response struct {
+			D []*ReleaseResponse `json:"d,omitempty"`
+		}
+		resp := &Response{}
+		if err := p.next(ctx, resp); err != nil {
+			return fmt.Errorf("unable to query release: %w", err)
+		}
+		if err := fn(resp.D); err != nil {
+			return err
+		}
+		if !p.more(len(resp.D)) {
+			return nil
+		}
+	}
+}
+
 // Get returns a release given a release ID.
 // If no such release exists, both the response and error returned are nil.
 func (s *ReleaseService) Get(ctx context.Context, id int64) (*ReleaseResponse, error) {
@@ -55,7 +96,7 @@ func (s *ReleaseService) Get(ctx context.Context, id int64) (*ReleaseResponse, e
 		nil,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("unable to create get request: %v", err)
+		return nil, fmt.Errorf("unable to create get request: %w", err)
 	}
 	type Response struct {
 		D []ReleaseResponse `json:"d,omitempty"`
@@ -63,7 +104,7 @@ func (s *ReleaseService) Get(ctx context.Context, id int64) (*ReleaseResponse, e
 	resp := &Response{}
 	err = s.client.Do(req, resp)
 	if err != nil {
-		return nil, fmt.Errorf("unable to get release: %v", err)
+		return nil, fmt.Errorf("unable to get release: %w", err)
 	}
 	if len(resp.D) > 1 {
 		return nil, errors.New("received more than 1 release, expected 0 or 1")
@@ -78,10 +119,11 @@ func (s *ReleaseService) Get(ctx context.Context, id int64) (*ReleaseResponse, e
 // The query should be a valid, escaped OData query such as `%24filter=uuid+eq+'12333422'`.
 //
 // Forward slash in filter keys should not be escaped (So `device/uuid` should not be escaped).
+// Use odata.Query to build a correctly escaped query.
 func (s *ReleaseService) GetWithQuery(ctx context.Context, query string) ([]*ReleaseResponse, error) {
 	req, err := s.client.NewRequest(ctx, http.MethodGet, releaseBasePath, query, nil)
 	if err != nil {
-		return nil, fmt.Errorf("unable to create release request: %v", err)
+		return nil, fmt.Errorf("unable to create release request: %w", err)
 	}
 	type Response struct {
 		D []*ReleaseResponse `json:"d,omitempty"`
@@ -89,7 +131,7 @@ func (s *ReleaseService) GetWithQuery(ctx context.Context, query string) ([]*Rel
 	resp := &Response{}
 	err = s.client.Do(req, resp)
 	if err != nil {
-		return nil, fmt.Errorf("unable to query release: %v", err)
+		return nil, fmt.Errorf("unable to query release: %w", err)
 	}
 	return resp.D, nil
 }
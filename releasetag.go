@@ -30,7 +30,10 @@ func (s *ReleaseTagService) List(ctx context.Context, releaseID int64) ([]*Relea
 
 // ListByCommit lists all release tags for a given release commit.
 func (s *ReleaseTagService) ListByCommit(ctx context.Context, commit string) ([]*ReleaseTagResponse, error) {
-	query := "%24filter=release/commit+eq+%27" + commit + "%27"
+	query, err := filterQuery(odata.Eq("release/commit", commit))
+	if err != nil {
+		return nil, fmt.Errorf("list release tag by commit: %w", err)
+	}
 	return s.GetWithQuery(ctx, query)
 }
 
@@ -38,10 +41,11 @@ func (s *ReleaseTagService) ListByCommit(ctx context.Context, commit string) ([]
 // The query should be a valid, escaped OData query such as `%24filter=uuid+eq+%2712333422%27`.
 //
 // Forward slash in filter keys should not be escaped (So `device/uuid` should not be escaped).
+// Use odata.Query to build a correctly escaped query.
 func (s *ReleaseTagService) GetWithQuery(ctx context.Context, query string) ([]*ReleaseTagResponse, error) {
 	req, err := s.client.NewRequest(ctx, http.MethodGet, releaseTagBasePath, query, nil)
 	if err != nil {
-		return nil, fmt.Errorf("get release tag with query NewRequest: %v", err)
+		return nil, fmt.Errorf("get release tag with query NewRequest: %w", err)
 	}
 	type Response struct {
 		D []*ReleaseTagResponse `json:"d,omitempty"`
@@ -49,7 +53,7 @@ func (s *ReleaseTagService) GetWithQuery(ctx context.Context, query string) ([]*
 	resp := &Response{}
 	err = s.client.Do(req, resp)
 	if err != nil {
-		return nil, fmt.Errorf("get release tag with query: %v", err)
+		return nil, fmt.Errorf("get release tag with query: %w", err)
 	}
 	return resp.D, nil
 }
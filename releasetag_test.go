@@ -161,3 +161,24 @@ func TestReleaseTagService_GetWithQuery(t *testing.T) {
 	assert.NilError(t, err)
 	assert.DeepEqual(t, expected, actual)
 }
+
+func TestReleaseTagService_ListByCommit_EscapesCommit(t *testing.T) {
+	// Given
+	commit := "abc' or release/commit ne '"
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/"+releaseTagBasePath, func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		expected := "%24filter=release/commit+eq+%27abc%27%27+or+release%2Fcommit+ne+%27%27%27"
+		if r.URL.RawQuery != expected {
+			http.Error(w, fmt.Sprintf("query = %s ; expected = %s\n", r.URL.RawQuery, expected), http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, `{"d":[]}`)
+	})
+	// When
+	actual, err := client.ReleaseTag.ListByCommit(context.Background(), commit)
+	// Then
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(actual))
+}
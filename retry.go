@@ -0,0 +1,202 @@
+package balena
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy configures how Client.Do retries requests that failed with a transport error or a
+// retryable status code (429 Too Many Requests, 502 Bad Gateway, 503 Service Unavailable and
+// 504 Gateway Timeout).
+//
+// Delays grow exponentially from MinBackoff up to MaxBackoff with random jitter. If the response
+// carries a Retry-After header, its value is used as the delay instead, capped at MaxBackoff.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first one.
+	// Values less than 2 disable retries.
+	MaxAttempts int
+	// MinBackoff is the delay before the first retry. Defaults to 500ms.
+	MinBackoff time.Duration
+	// MaxBackoff is the upper bound of the exponential delay and of delays given by Retry-After headers.
+	// Defaults to 30s.
+	MaxBackoff time.Duration
+	// RetryNonIdempotent enables retries of POST and PATCH requests. By default only idempotent
+	// methods (GET, HEAD, OPTIONS, PUT and DELETE) are retried.
+	RetryNonIdempotent bool
+}
+
+// DefaultRetryPolicy returns a RetryPolicy making up to 4 attempts of idempotent requests.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: 4,
+		MinBackoff:  500 * time.Millisecond,
+		MaxBackoff:  30 * time.Second,
+	}
+}
+
+// retryable reports whether a request that completed its given attempt with resp and err should be retried.
+func (p *RetryPolicy) retryable(req *http.Request, attempt int, resp *http.Response, err error) bool {
+	if p == nil || attempt >= p.MaxAttempts {
+		return false
+	}
+	if req.Context().Err() != nil {
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		// The body has been consumed and cannot be rewound.
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+	default:
+		if !p.RetryNonIdempotent {
+			return false
+		}
+	}
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff returns the delay before the retry following the given attempt.
+func (p *RetryPolicy) backoff(attempt int, resp *http.Response) time.Duration {
+	maxBackoff := p.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = 30 * time.Second
+	}
+	if resp != nil {
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			if d > maxBackoff {
+				return maxBackoff
+			}
+			return d
+		}
+	}
+	minBackoff := p.MinBackoff
+	if minBackoff <= 0 {
+		minBackoff = 500 * time.Millisecond
+	}
+	d := minBackoff
+	for i := 1; i < attempt && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	// Equal jitter: wait at least half of the computed delay.
+	half := d / 2
+	//nolint:gosec // Jitter does not need a cryptographically secure source.
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// parseRetryAfter parses the value of a Retry-After header, given either in seconds or as an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if d := t.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
+
+// doWithRetry sends req, retrying it according to the client's RetryPolicy.
+// Every attempt waits on the client's RateLimiter.
+func (c *Client) doWithRetry(req *http.Request) (*http.Response, error) {
+	r := req
+	for attempt := 1; ; attempt++ {
+		if c.RateLimiter != nil {
+			if err := c.RateLimiter.Wait(req.Context(), req.Method); err != nil {
+				return nil, err
+			}
+		}
+		start := time.Now()
+		resp, err := c.client.Do(r)
+		if err != nil {
+			c.logf(
+				"%s %s: attempt %d failed after %v: %v",
+				req.Method, req.URL.Path, attempt, time.Since(start), unwrapURLError(err),
+			)
+		} else {
+			c.logf("%s %s: %d after %v", req.Method, req.URL.Path, resp.StatusCode, time.Since(start))
+		}
+		if !c.RetryPolicy.retryable(req, attempt, resp, err) {
+			return resp, err
+		}
+		delay := c.RetryPolicy.backoff(attempt, resp)
+		c.logf("%s %s: retrying in %v", req.Method, req.URL.Path, delay)
+		if resp != nil {
+			// Drain the body so that the connection can be reused.
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+		if err := sleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
+		r, err = rewind(req)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// rewind returns a copy of req with a fresh body, so that it can be sent again.
+func rewind(req *http.Request) (*http.Request, error) {
+	r := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = body
+	} else if req.Body != nil && req.Body != http.NoBody {
+		return nil, errors.New("unable to rewind request body")
+	}
+	return r, nil
+}
+
+// sleep waits for d, returning early with the context error if ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// unwrapURLError returns the error wrapped by a *url.Error, which is left out as its message holds the full
+// request URL, including query strings that may contain credentials.
+func unwrapURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
@@ -0,0 +1,271 @@
+package balena
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func testRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: 3,
+		MinBackoff:  time.Millisecond,
+		MaxBackoff:  2 * time.Millisecond,
+	}
+}
+
+func TestDo_Retry_ServiceUnavailable(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	client.RetryPolicy = testRetryPolicy()
+	requests := 0
+	mux.HandleFunc("/foo", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests < 3 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"A":"a"}`)
+	})
+	req, err := client.NewRequest(context.Background(), http.MethodGet, "foo", "", nil)
+	assert.NilError(t, err)
+	body := &struct{ A string }{}
+	// When
+	err = client.Do(req, body)
+	// Then
+	assert.NilError(t, err)
+	assert.Equal(t, 3, requests)
+	assert.Equal(t, "a", body.A)
+}
+
+func TestDo_Retry_GivesUp(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	client.RetryPolicy = testRetryPolicy()
+	requests := 0
+	mux.HandleFunc("/foo", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	})
+	req, err := client.NewRequest(context.Background(), http.MethodGet, "foo", "", nil)
+	assert.NilError(t, err)
+	// When
+	err = client.Do(req, nil)
+	// Then
+	var errorResponse *ErrorResponse
+	assert.Assert(t, errors.As(err, &errorResponse))
+	assert.Equal(t, http.StatusTooManyRequests, errorResponse.Response.StatusCode)
+	assert.Equal(t, 3, requests)
+}
+
+func TestDo_Retry_NotRetryableStatus(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	client.RetryPolicy = testRetryPolicy()
+	requests := 0
+	mux.HandleFunc("/foo", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.Error(w, "not found", http.StatusNotFound)
+	})
+	req, err := client.NewRequest(context.Background(), http.MethodGet, "foo", "", nil)
+	assert.NilError(t, err)
+	// When
+	err = client.Do(req, nil)
+	// Then
+	assert.Assert(t, err != nil)
+	assert.Equal(t, 1, requests)
+}
+
+func TestDo_Retry_Disabled(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	requests := 0
+	mux.HandleFunc("/foo", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+	req, err := client.NewRequest(context.Background(), http.MethodGet, "foo", "", nil)
+	assert.NilError(t, err)
+	// When
+	err = client.Do(req, nil)
+	// Then
+	assert.Assert(t, err != nil)
+	assert.Equal(t, 1, requests)
+}
+
+func TestDo_Retry_NonIdempotent(t *testing.T) {
+	for _, tt := range []struct {
+		name               string
+		retryNonIdempotent bool
+		expectedRequests   int
+	}{
+		{name: "default", retryNonIdempotent: false, expectedRequests: 1},
+		{name: "opt-in", retryNonIdempotent: true, expectedRequests: 2},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			client, mux, cleanup := newFixture()
+			defer cleanup()
+			client.RetryPolicy = testRetryPolicy()
+			client.RetryPolicy.RetryNonIdempotent = tt.retryNonIdempotent
+			var bodies []string
+			mux.HandleFunc("/foo", func(w http.ResponseWriter, r *http.Request) {
+				testMethod(t, r, http.MethodPost)
+				b, err := io.ReadAll(r.Body)
+				assert.NilError(t, err)
+				bodies = append(bodies, string(b))
+				if len(bodies) == 1 {
+					http.Error(w, "bad gateway", http.StatusBadGateway)
+					return
+				}
+				fmt.Fprint(w, "OK")
+			})
+			req, err := client.NewRequest(
+				context.Background(),
+				http.MethodPost,
+				"foo",
+				"",
+				&struct {
+					Name string `json:"name"`
+				}{Name: "value"},
+			)
+			assert.NilError(t, err)
+			// When
+			_ = client.Do(req, nil)
+			// Then
+			assert.Equal(t, tt.expectedRequests, len(bodies))
+			for _, b := range bodies {
+				assert.Equal(t, `{"name":"value"}`+"\n", b)
+			}
+		})
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestDo_Retry_TransportError(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/foo", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "OK")
+	})
+	attempts := 0
+	client.client = &http.Client{
+		Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			attempts++
+			if attempts == 1 {
+				return nil, errors.New("connection reset")
+			}
+			return http.DefaultTransport.RoundTrip(r)
+		}),
+	}
+	client.RetryPolicy = testRetryPolicy()
+	req, err := client.NewRequest(context.Background(), http.MethodGet, "foo", "", nil)
+	assert.NilError(t, err)
+	// When
+	err = client.Do(req, nil)
+	// Then
+	assert.NilError(t, err)
+	assert.Equal(t, 2, attempts)
+}
+
+func TestDo_Retry_ContextCanceled(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	client.RetryPolicy = testRetryPolicy()
+	ctx, cancel := context.WithCancel(context.Background())
+	requests := 0
+	mux.HandleFunc("/foo", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		cancel()
+		w.Header().Set("Retry-After", "3600")
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+	req, err := client.NewRequest(ctx, http.MethodGet, "foo", "", nil)
+	assert.NilError(t, err)
+	// When
+	err = client.Do(req, nil)
+	// Then
+	assert.Assert(t, err != nil)
+	assert.Equal(t, 1, requests)
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	// Given
+	policy := &RetryPolicy{MinBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond}
+	for _, tt := range []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{attempt: 1, max: 100 * time.Millisecond},
+		{attempt: 2, max: 200 * time.Millisecond},
+		{attempt: 3, max: 300 * time.Millisecond},
+		{attempt: 10, max: 300 * time.Millisecond},
+	} {
+		// When
+		d := policy.backoff(tt.attempt, nil)
+		// Then
+		assert.Assert(t, d >= tt.max/2 && d <= tt.max, "attempt %d: %v", tt.attempt, d)
+	}
+}
+
+func TestRetryPolicy_Backoff_RetryAfter(t *testing.T) {
+	// Given
+	policy := DefaultRetryPolicy()
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set("Retry-After", "7")
+	// When
+	d := policy.backoff(1, resp)
+	// Then
+	assert.Equal(t, 7*time.Second, d)
+}
+
+func TestRetryPolicy_Backoff_LargeRetryAfter(t *testing.T) {
+	// Given
+	policy := DefaultRetryPolicy()
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set("Retry-After", "86400")
+	// When
+	d := policy.backoff(1, resp)
+	// Then
+	assert.Equal(t, policy.MaxBackoff, d)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2021, 5, 11, 8, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{value: "", ok: false},
+		{value: "120", expected: 2 * time.Minute, ok: true},
+		{value: "-1", ok: false},
+		{value: "Tue, 11 May 2021 08:00:30 GMT", expected: 30 * time.Second, ok: true},
+		{value: "Tue, 11 May 2021 07:00:00 GMT", expected: 0, ok: true},
+		{value: "soon", ok: false},
+	} {
+		// When
+		d, ok := parseRetryAfter(tt.value, now)
+		// Then
+		assert.Equal(t, tt.ok, ok, tt.value)
+		assert.Equal(t, tt.expected, d, tt.value)
+	}
+}
@@ -0,0 +1,259 @@
+package balena
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.einride.tech/balena/odata"
+)
+
+const (
+	serviceBasePath       = "v6/service"
+	serviceEnvVarBasePath = "v6/service_environment_variable"
+)
+
+// ServiceEnvVarService handles environment variables applying to one service on all devices of an application.
+// Services are identified by application ID/slug and service name, as given in the docker-compose file.
+// Device service environment variables with the same name take precedence, see DeviceServVarService.
+type ServiceEnvVarService service
+
+type ServiceEnvVarResponse struct {
+	ID        int64        `json:"id,omitempty"`
+	CreatedAt string       `json:"created_at,omitempty"`
+	Service   odata.Object `json:"service,omitempty"`
+	Name      string       `json:"name,omitempty"`
+	Value     string       `json:"value,omitempty"`
+}
+
+// List lists all environment variables of the named service of the application with given ID/slug.
+func (s *ServiceEnvVarService) List(
+	ctx context.Context,
+	applicationID IDOrSlug,
+	serviceName string,
+) ([]*ServiceEnvVarResponse, error) {
+	query, err := filterQuery(serviceFilter(applicationID, serviceName))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+	return s.getWithQuery(ctx, query)
+}
+
+// Get retrieves the variable with the given name of the named service of the application with given ID/slug.
+// If no variable with such name exists, both the response and error are nil.
+func (s *ServiceEnvVarService) Get(
+	ctx context.Context,
+	applicationID IDOrSlug,
+	serviceName string,
+	name string,
+) (*ServiceEnvVarResponse, error) {
+	query, err := filterQuery(serviceFilter(applicationID, serviceName, odata.Eq("name", name)))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+	resp, err := s.getWithQuery(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) > 1 {
+		return nil, fmt.Errorf("expected 1 variable but got %d", len(resp))
+	}
+	if len(resp) == 0 {
+		return nil, nil
+	}
+	return resp[0], nil
+}
+
+// Create creates an environment variable with name=value for the named service of the application with given
+// ID/slug. An error wrapping ErrNotFound is returned if the application has no such service, and an error
+// wrapping ErrConflict if the variable already exists.
+func (s *ServiceEnvVarService) Create(
+	ctx context.Context,
+	applicationID IDOrSlug,
+	serviceName string,
+	name string,
+	value string,
+) (*ServiceEnvVarResponse, error) {
+	serviceID, err := s.resolveServiceID(ctx, applicationID, serviceName)
+	if err != nil {
+		return nil, err
+	}
+	type request struct {
+		ServiceID int64  `json:"service"`
+		Name      string `json:"name"`
+		Value     string `json:"value"`
+	}
+	req, err := s.client.NewRequest(ctx, http.MethodPost, serviceEnvVarBasePath, "", &request{
+		ServiceID: serviceID,
+		Name:      name,
+		Value:     value,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+	resp := &ServiceEnvVarResponse{}
+	err = s.client.Do(req, resp)
+	if err != nil {
+		return nil, fmt.Errorf("unable to perform request: %w", err)
+	}
+	return resp, nil
+}
+
+// Update a variable with the given name of the named service of the application with given ID/slug to the
+// specified new value. No error is returned if no variable with such name exists, unless the client uses
+// WithStrictMatching.
+func (s *ServiceEnvVarService) Update(
+	ctx context.Context,
+	applicationID IDOrSlug,
+	serviceName string,
+	name string,
+	newValue string,
+) error {
+	body := struct {
+		Value string `json:"value"`
+	}{Value: newValue}
+	filter := serviceFilter(applicationID, serviceName, odata.Eq("name", name))
+	_, err := s.client.doFilterRequest(ctx, http.MethodPatch, serviceEnvVarBasePath, filter, body, false)
+	return err
+}
+
+// Upsert sets the variable with the given name of the named service of the application with given ID/slug to
+// value, creating it if it does not exist.
+func (s *ServiceEnvVarService) Upsert(
+	ctx context.Context,
+	applicationID IDOrSlug,
+	serviceName string,
+	name string,
+	value string,
+) (*ServiceEnvVarResponse, error) {
+	v, err := s.client.upsertVariable(
+		ctx,
+		serviceEnvVarBasePath,
+		name,
+		value,
+		func() (variable, error) {
+			existing, err := s.Get(ctx, applicationID, serviceName, name)
+			if existing == nil {
+				return nil, err
+			}
+			return existing, err
+		},
+		func() (variable, error) {
+			created, err := s.Create(ctx, applicationID, serviceName, name, value)
+			if created == nil {
+				return nil, err
+			}
+			return created, err
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return v.(*ServiceEnvVarResponse), nil
+}
+
+// DeleteWithName deletes a variable with the given name of the named service of the application with given
+// ID/slug. No error is returned if no variable with such name exists, unless the client uses
+// WithStrictMatching.
+func (s *ServiceEnvVarService) DeleteWithName(
+	ctx context.Context,
+	applicationID IDOrSlug,
+	serviceName string,
+	name string,
+) error {
+	filter := serviceFilter(applicationID, serviceName, odata.Eq("name", name))
+	_, err := s.client.doFilterRequest(ctx, http.MethodDelete, serviceEnvVarBasePath, filter, nil, false)
+	return err
+}
+
+func (s *ServiceEnvVarService) getWithQuery(ctx context.Context, query string) ([]*ServiceEnvVarResponse, error) {
+	req, err := s.client.NewRequest(ctx, http.MethodGet, serviceEnvVarBasePath, query, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+	type Response struct {
+		D []*ServiceEnvVarResponse `json:"d,omitempty"`
+	}
+	resp := &Response{}
+	err = s.client.Do(req, resp)
+	if err != nil {
+		return nil, fmt.Errorf("unable to perform request: %w", err)
+	}
+	return resp.D, nil
+}
+
+func (v *ServiceEnvVarResponse) variableFields() (int64, *string) {
+	return v.ID, &v.Value
+}
+
+// resolveServiceID returns the ID of the named service of the application with given ID/slug.
+// An error wrapping ErrNotFound is returned if the application has no such service.
+func (s *ServiceEnvVarService) resolveServiceID(
+	ctx context.Context,
+	applicationID IDOrSlug,
+	serviceName string,
+) (int64, error) {
+	query, err := odata.NewQuery().
+		Filter(odata.And(applicationID.filter("application", "application/slug"), odata.Eq("service_name", serviceName))).
+		Select("id").
+		Encode()
+	if err != nil {
+		return 0, fmt.Errorf("unable to create request: %w", err)
+	}
+	req, err := s.client.NewRequest(ctx, http.MethodGet, serviceBasePath, query, nil)
+	if err != nil {
+		return 0, fmt.Errorf("unable to create request: %w", err)
+	}
+	type Response struct {
+		D []struct {
+			ID int64 `json:"id"`
+		} `json:"d,omitempty"`
+	}
+	resp := &Response{}
+	if err := s.client.Do(req, resp); err != nil {
+		return 0, fmt.Errorf("unable to resolve service %s: %w", serviceName, err)
+	}
+	if len(resp.D) == 0 {
+		return 0, fmt.Errorf("service %s of application %s: %w", serviceName, applicationID.id, ErrNotFound)
+	}
+	return resp.D[0].ID, nil
+}
+
+// listServiceNames returns the names of all services of the application with given ID/slug, keyed by service ID.
+func (s *ServiceEnvVarService) listServiceNames(ctx context.Context, applicationID IDOrSlug) (map[int64]string, error) {
+	query, err := odata.NewQuery().
+		Filter(applicationID.filter("application", "application/slug")).
+		Select("id", "service_name").
+		Encode()
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+	req, err := s.client.NewRequest(ctx, http.MethodGet, serviceBasePath, query, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+	type Response struct {
+		D []struct {
+			ID          int64  `json:"id"`
+			ServiceName string `json:"service_name"`
+		} `json:"d,omitempty"`
+	}
+	resp := &Response{}
+	if err := s.client.Do(req, resp); err != nil {
+		return nil, fmt.Errorf("unable to perform request: %w", err)
+	}
+	names := make(map[int64]string, len(resp.D))
+	for _, d := range resp.D {
+		names[d.ID] = d.ServiceName
+	}
+	return names, nil
+}
+
+// serviceFilter returns an OData filter matching variables of the named service of an application, and the
+// given filters.
+func serviceFilter(applicationID IDOrSlug, serviceName string, filters ...odata.Filter) odata.Filter {
+	return odata.And(append([]odata.Filter{
+		applicationID.filter("service/application", "service/application/slug"),
+		odata.Eq("service/service_name", serviceName),
+	}, filters...)...)
+}
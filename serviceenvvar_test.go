@@ -0,0 +1,93 @@
+package balena
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"go.einride.tech/balena/odata"
+	"gotest.tools/v3/assert"
+)
+
+func TestServiceEnvVarService_List(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/"+serviceEnvVarBasePath, func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		assert.Equal(
+			t,
+			r.URL.RawQuery,
+			"%24filter=service/application/slug+eq+%27myorg%2Fmyapp%27+and+service/service_name+eq+%27main%27",
+		)
+		fmt.Fprint(w, `{"d":[{"id":1,"service":{"__id":7},"name":"KEY","value":"value"}]}`)
+	})
+	// When
+	actual, err := client.ServiceEnvVar.List(context.Background(), ApplicationSlug("myorg/myapp"), "main")
+	// Then
+	assert.NilError(t, err)
+	assert.DeepEqual(t, actual, []*ServiceEnvVarResponse{
+		{ID: 1, Service: odata.Object{ID: 7}, Name: "KEY", Value: "value"},
+	})
+}
+
+func TestServiceEnvVarService_Create(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/"+serviceBasePath, func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodGet)
+		assert.Equal(t, r.URL.RawQuery, "%24select=id&%24filter=application+eq+%27123%27+and+service_name+eq+%27main%27")
+		fmt.Fprint(w, `{"d":[{"id":7}]}`)
+	})
+	mux.HandleFunc("/"+serviceEnvVarBasePath, func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodPost)
+		b, err := io.ReadAll(r.Body)
+		assert.NilError(t, err)
+		assert.Equal(t, string(b), `{"service":7,"name":"KEY","value":"value"}`+"\n")
+		fmt.Fprint(w, `{"id":1,"service":{"__id":7},"name":"KEY","value":"value"}`)
+	})
+	// When
+	actual, err := client.ServiceEnvVar.Create(context.Background(), ApplicationID(123), "main", "KEY", "value")
+	// Then
+	assert.NilError(t, err)
+	assert.Equal(t, actual.Service.ID, int64(7))
+}
+
+func TestServiceEnvVarService_Create_UnknownService(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/"+serviceBasePath, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"d":[]}`)
+	})
+	// When
+	_, err := client.ServiceEnvVar.Create(context.Background(), ApplicationID(123), "missing", "KEY", "value")
+	// Then
+	assert.Assert(t, errors.Is(err, ErrNotFound))
+}
+
+func TestServiceEnvVarService_Update(t *testing.T) {
+	// Given
+	client, mux, cleanup := newFixture()
+	defer cleanup()
+	mux.HandleFunc("/"+serviceEnvVarBasePath, func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, http.MethodPatch)
+		assert.Equal(
+			t,
+			r.URL.RawQuery,
+			"%24filter=service/application+eq+%27123%27+and+service/service_name+eq+%27main%27+and+name+eq+%27KEY%27",
+		)
+		b, err := io.ReadAll(r.Body)
+		assert.NilError(t, err)
+		assert.Equal(t, string(b), `{"value":"new"}`+"\n")
+		fmt.Fprint(w, "OK")
+	})
+	// When
+	err := client.ServiceEnvVar.Update(context.Background(), ApplicationID(123), "main", "KEY", "new")
+	// Then
+	assert.NilError(t, err)
+}
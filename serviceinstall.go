@@ -55,9 +55,9 @@ func (i ServiceInstalls) FindByServiceName(serviceName string) (InstallsService,
 
 // List all service installs for a particular device.
 func (s *ServiceInstallService) List(ctx context.Context, deviceID IDOrUUID) (ServiceInstalls, error) {
-	query := "%24filter=device+eq+%27" + deviceID.id + "%27"
-	if deviceID.isUUID {
-		query = "%24filter=device/uuid+eq+%27" + deviceID.id + "%27"
+	query, err := filterQuery(deviceID.filter("device", "device/uuid"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
 	}
 	query += "&%24expand=installs__service(%24select=service_name,application,created_at,id)"
 
@@ -66,12 +66,12 @@ func (s *ServiceInstallService) List(ctx context.Context, deviceID IDOrUUID) (Se
 	}
 	req, err := s.client.NewRequest(ctx, http.MethodGet, serviceInstallBasePath, query, nil)
 	if err != nil {
-		return nil, fmt.Errorf("unable to create request: %v", err)
+		return nil, fmt.Errorf("unable to create request: %w", err)
 	}
 	resp := &response{}
 	err = s.client.Do(req, resp)
 	if err != nil {
-		return nil, fmt.Errorf("unable to perform request: %v", err)
+		return nil, fmt.Errorf("unable to perform request: %w", err)
 	}
 	return resp.D, nil
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/url"
 )
 
 const supervisorv1BasePath = "v1"
@@ -29,7 +30,7 @@ func (s *SupervisorV1Service) Reboot(ctx context.Context, force bool) error {
 		&request{Force: force},
 	)
 	if err != nil {
-		return fmt.Errorf("unable to create restart service request: %v", err)
+		return fmt.Errorf("unable to create restart service request: %w", err)
 	}
 	var resp struct {
 		Data  string `json:"Data"`
@@ -37,7 +38,7 @@ func (s *SupervisorV1Service) Reboot(ctx context.Context, force bool) error {
 	}
 	err = s.client.Do(req, &resp)
 	if err != nil {
-		return fmt.Errorf("unable to reboot device: %v", err)
+		return fmt.Errorf("unable to reboot device: %w", err)
 	}
 	if resp.Data != "OK" {
 		return fmt.Errorf("unable to reboot device: %v", resp.Error)
@@ -53,7 +54,7 @@ func (s *SupervisorV1Service) newRequest(
 ) (*http.Request, error) {
 	u := urlStr
 	m := method
-	q := fmt.Sprintf("apikey=%s", s.apiKey)
+	q := "apikey=" + url.QueryEscape(s.apiKey)
 	b := body
 	if !s.local {
 		u = "supervisor/" + u
@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/url"
 )
 
 const supervisorv2BasePath = "v2"
@@ -30,12 +31,12 @@ func (s *SupervisorV2Service) RestartServiceByName(ctx context.Context, name str
 		&request{ServiceName: name},
 	)
 	if err != nil {
-		return fmt.Errorf("unable to create restart service request: %v", err)
+		return fmt.Errorf("unable to create restart service request: %w", err)
 	}
 	buf := &bytes.Buffer{}
 	err = s.client.Do(req, buf)
 	if err != nil {
-		return fmt.Errorf("unable to restart service %s: %v", name, err)
+		return fmt.Errorf("unable to restart service %s: %w", name, err)
 	}
 	return nil
 }
@@ -51,12 +52,12 @@ func (s *SupervisorV2Service) StopServiceByName(ctx context.Context, name string
 		&request{ServiceName: name},
 	)
 	if err != nil {
-		return fmt.Errorf("unable to create stop service request: %v", err)
+		return fmt.Errorf("unable to create stop service request: %w", err)
 	}
 	buf := &bytes.Buffer{}
 	err = s.client.Do(req, buf)
 	if err != nil {
-		return fmt.Errorf("unable to stop service %s: %v", name, err)
+		return fmt.Errorf("unable to stop service %s: %w", name, err)
 	}
 	return nil
 }
@@ -87,12 +88,12 @@ func (s *SupervisorV2Service) ApplicationState(ctx context.Context) (*SvAppState
 		nil,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("unable to create application state request: %v", err)
+		return nil, fmt.Errorf("unable to create application state request: %w", err)
 	}
 	resp := &SvAppStateResp{}
 	err = s.client.Do(req, resp)
 	if err != nil {
-		return nil, fmt.Errorf("unable to fetch application state: %v", err)
+		return nil, fmt.Errorf("unable to fetch application state: %w", err)
 	}
 	return resp, nil
 }
@@ -111,7 +112,7 @@ func (s *SupervisorV2Service) newRequest(
 ) (*http.Request, error) {
 	u := urlStr
 	m := method
-	q := fmt.Sprintf("apikey=%s", s.apiKey)
+	q := "apikey=" + url.QueryEscape(s.apiKey)
 	b := body
 	if !s.local {
 		u = "supervisor/" + u
@@ -227,3 +227,37 @@ func TestSupervisorV2Service_ApplicationState_Local(t *testing.T) {
 	assert.NilError(t, err)
 	assert.DeepEqual(t, expected, actual)
 }
+
+func TestNewSupervisorV2FromConfig_Invalid(t *testing.T) {
+	valid := SupervisorConfig{
+		Address:    "http://127.0.0.1:48484",
+		APIKey:     "key",
+		DeviceUUID: "uuid",
+		AppID:      1234,
+	}
+	for _, tt := range []struct {
+		name     string
+		modify   func(*SupervisorConfig)
+		expected string
+	}{
+		{
+			name:     "address",
+			modify:   func(c *SupervisorConfig) { c.Address = "127.0.0.1" },
+			expected: "invalid supervisor address",
+		},
+		{name: "api key", modify: func(c *SupervisorConfig) { c.APIKey = "" }, expected: "API key must not be empty"},
+		{name: "uuid", modify: func(c *SupervisorConfig) { c.DeviceUUID = "" }, expected: "UUID must not be empty"},
+		{name: "app id", modify: func(c *SupervisorConfig) { c.AppID = 0 }, expected: "ID must not be zero"},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			config := valid
+			tt.modify(&config)
+			// When
+			_, err := NewSupervisorV2FromConfig(nil, config)
+			// Then
+			assert.ErrorContains(t, err, tt.expected)
+		})
+	}
+}